@@ -0,0 +1,297 @@
+package defip
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// backend is a source of routing tables. Each platform lists the backends
+// compiled in for it, in order of preference, through platformBackends.
+type backend struct {
+	// name identifies the backend, such as "procfs" or "netstat".
+	name string
+
+	// probe checks whether the backend can be used on the running system.
+	probe func() error
+
+	routes    func(ctx context.Context) (NetRouteList, error)
+	rawRoutes func(ctx context.Context) ([]RawRouteMessage, error)
+
+	// defaultRoute, when set, queries the default route of a given kind
+	// without reading the whole routing table. Should it fail, routes is
+	// used instead.
+	defaultRoute func(ctx context.Context, kind NetRouteKind) (NetRoute, error)
+
+	// mu guards the outcome of the last probe: whether it succeeded, or
+	// otherwise its error and when it was obtained.
+	mu       sync.Mutex
+	probed   bool
+	err      error
+	failedAt time.Time
+}
+
+// probeRetryInterval is how long a failed probe is reported before the
+// backend is probed again.
+const probeRetryInterval = 5 * time.Second
+
+// available probes the backend on first use, so importing the package never
+// touches the system. A successful probe is retained for the lifetime of the
+// process, while a failed one is retried once probeRetryInterval elapses, so
+// backends becoming usable later on, as when netstat is installed or /proc is
+// mounted after the process starts, are picked up.
+func (b *backend) available() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.probed {
+		return nil
+	}
+	if b.err != nil && time.Since(b.failedAt) < probeRetryInterval {
+		return b.err
+	}
+	b.err = b.probe()
+	b.probed = b.err == nil
+	b.failedAt = time.Now()
+	return b.err
+}
+
+var (
+	backendsOnce sync.Once
+	backends     []*backend
+
+	// registered holds backends added through RegisterBackend.
+	registeredMu sync.Mutex
+	registered   []*backend
+
+	// lastBackend holds the name of the backend that produced the most
+	// recent routing table.
+	lastBackend atomic.Value
+)
+
+// loadBackends returns the backends compiled in for the running platform,
+// followed by those added through RegisterBackend.
+func loadBackends() []*backend {
+	registeredMu.Lock()
+	defer registeredMu.Unlock()
+	return append(slices.Clip(compiledBackends()), registered...)
+}
+
+// compiledBackends returns the backends compiled in for the running platform.
+func compiledBackends() []*backend {
+	backendsOnce.Do(func() {
+		backends = platformBackends()
+	})
+	return backends
+}
+
+// RegisterBackend adds a routing table backend named name, obtaining routes
+// through routes. This allows the package to be used on platforms it does
+// not support, such as js/wasm, where routes may instead be fetched from an
+// agent on the host. Registered backends are tried after those compiled in,
+// in the order they were registered, and may be pinned through WithBackends.
+// Raw routes obtained from them hold the String representation of each
+// route.
+//
+// Fails when name is empty or already taken by another backend.
+func RegisterBackend(name string, routes func(ctx context.Context) (NetRouteList, error)) error {
+	if name == "" {
+		return fmt.Errorf("backend name must not be empty")
+	}
+	registeredMu.Lock()
+	defer registeredMu.Unlock()
+	for _, b := range append(slices.Clip(compiledBackends()), registered...) {
+		if b.name == name {
+			return fmt.Errorf("backend `%s' already exists", name)
+		}
+	}
+
+	registered = append(registered, &backend{
+		name:   name,
+		probe:  func() error { return nil },
+		routes: routes,
+		rawRoutes: func(ctx context.Context) ([]RawRouteMessage, error) {
+			list, err := routes(ctx)
+			if err != nil {
+				return nil, err
+			}
+			result := make([]RawRouteMessage, 0, len(list))
+			for _, v := range list {
+				result = append(result, RawRouteMessage{Source: name, Data: []byte(v.String())})
+			}
+			return result, nil
+		},
+	})
+	return nil
+}
+
+// orderBackends returns the backends named by names, in that order, or every
+// backend compiled in when names is empty. Fails when a name does not match
+// any backend compiled in.
+func orderBackends(names []string) ([]*backend, error) {
+	all := loadBackends()
+	if len(names) == 0 {
+		return all, nil
+	}
+
+	result := make([]*backend, 0, len(names))
+	for _, name := range names {
+		i := slices.IndexFunc(all, func(b *backend) bool { return b.name == name })
+		if i == -1 {
+			return nil, fmt.Errorf("unknown backend `%s' for OS: %s", name, runtime.GOOS)
+		}
+		result = append(result, all[i])
+	}
+	return result, nil
+}
+
+// useBackend calls fn with the first available backend among those named by
+// names (see orderBackends), falling through to the next one should fn fail
+// because a program exited with a non-zero status, or because access was
+// denied. Each call is given a copy of ctx bounded by timeout, unless zero or
+// negative, so every backend tried gets the full timeout. Returns
+// ErrNotImplemented when no backend is compiled in or registered, or the
+// error of the first backend when none succeeds.
+func useBackend[T any](ctx context.Context, names []string, timeout time.Duration, fn func(ctx context.Context, b *backend) (T, error)) (T, error) {
+	var zero T
+	bs, err := orderBackends(names)
+	if err != nil {
+		return zero, err
+	}
+	if len(bs) == 0 {
+		return zero, &ErrNotImplemented{}
+	}
+
+	var firstErr error
+	for _, b := range bs {
+		err := b.available()
+		if err == nil {
+			var v T
+			attemptCtx, cancel := attemptContext(ctx, timeout)
+			v, err = fn(attemptCtx, b)
+			cancel()
+			if err == nil {
+				lastBackend.Store(b.name)
+				return v, nil
+			}
+			if !fallsThrough(err) {
+				return zero, err
+			}
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return zero, firstErr
+}
+
+// attemptContext returns a copy of ctx bounded by timeout, unless zero or
+// negative, along with the function releasing it.
+func attemptContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return ctx, func() {}
+}
+
+// fallsThrough returns whether a backend failing with err should be followed
+// by the next one.
+func fallsThrough(err error) bool {
+	var execErr *BackendExecError
+	var sandboxErr *ErrSandboxed
+	return errors.As(err, &execErr) || errors.As(err, &sandboxErr)
+}
+
+// BackendInfo describes a routing table backend compiled in for the running
+// platform.
+type BackendInfo struct {
+	// Name identifies the backend, such as "procfs" or "netstat".
+	Name string
+
+	// Available indicates whether the backend can be used on the running
+	// system.
+	Available bool
+
+	// Err holds why the backend cannot be used when not Available.
+	Err error
+
+	// LastUsed indicates that the backend produced the most recent routing
+	// table obtained by the package.
+	LastUsed bool
+}
+
+// Backends lists the routing table backends compiled in for the running
+// platform, followed by those added through RegisterBackend, in order of
+// preference, probing each of them anew.
+func Backends() []BackendInfo {
+	last, _ := lastBackend.Load().(string)
+	var result []BackendInfo
+	for _, b := range loadBackends() {
+		err := b.probe()
+		result = append(result, BackendInfo{
+			Name:      b.name,
+			Available: err == nil,
+			Err:       err,
+			LastUsed:  b.name == last,
+		})
+	}
+	return result
+}
+
+// DefaultMaxBackendOutput is the maximum amount of output, in bytes, buffered
+// from programs the routing table is obtained from, such as netstat, unless
+// configured otherwise through WithMaxBackendOutput.
+const DefaultMaxBackendOutput = 16 << 20
+
+type outputLimitKey struct{}
+
+// withOutputLimit returns a copy of ctx carrying limit, through which
+// backends running programs obtain it.
+func withOutputLimit(ctx context.Context, limit int64) context.Context {
+	return context.WithValue(ctx, outputLimitKey{}, limit)
+}
+
+// outputLimitFrom returns the output limit carried by ctx, or
+// DefaultMaxBackendOutput.
+func outputLimitFrom(ctx context.Context) int64 {
+	if limit, ok := ctx.Value(outputLimitKey{}).(int64); ok {
+		return limit
+	}
+	return DefaultMaxBackendOutput
+}
+
+type fibKey struct{}
+
+// withFIB returns a copy of ctx carrying the forwarding table routes are
+// obtained from, through which backends supporting multiple forwarding
+// tables obtain it. A negative fib is not carried.
+func withFIB(ctx context.Context, fib int) context.Context {
+	if fib < 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, fibKey{}, fib)
+}
+
+// fibFrom returns the forwarding table carried by ctx, and whether one is
+// carried.
+func fibFrom(ctx context.Context) (int, bool) {
+	fib, ok := ctx.Value(fibKey{}).(int)
+	return fib, ok
+}
+
+// Available reports whether any of the platform's routing table backends is
+// ready to be used, probing them if needed. It returns ErrNotImplemented on
+// unsupported platforms, ErrSandboxed when access to the routing table is
+// denied, as when running in a sandbox, or otherwise the error that prevents
+// the preferred backend from accessing it. Every other function obtaining
+// routes reports the same error.
+func Available() error {
+	_, err := useBackend(context.Background(), nil, 0, func(context.Context, *backend) (struct{}, error) {
+		return struct{}{}, nil
+	})
+	return err
+}
@@ -0,0 +1,82 @@
+package defip
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testBackends holds names of backends registered by tests, as backends
+// cannot be unregistered, and tests may run more than once.
+var testBackends sync.Map
+
+// registerTestBackend registers routes as the backend name, unless already
+// registered by a previous run.
+func registerTestBackend(t *testing.T, name string, routes func(ctx context.Context) (NetRouteList, error)) {
+	t.Helper()
+	if _, loaded := testBackends.LoadOrStore(name, true); loaded {
+		return
+	}
+	if err := RegisterBackend(name, routes); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBackendTimeoutPerAttempt(t *testing.T) {
+	const timeout = 100 * time.Millisecond
+	registerTestBackend(t, "test-timeout-fail", func(ctx context.Context) (NetRouteList, error) {
+		time.Sleep(timeout * 3 / 4)
+		return nil, &BackendExecError{Cmd: "test", ExitCode: 1}
+	})
+	registerTestBackend(t, "test-timeout-ok", func(ctx context.Context) (NetRouteList, error) {
+		deadline, ok := ctx.Deadline()
+		if !ok || time.Until(deadline) < timeout/2 {
+			return nil, errors.New("attempt not given the full timeout")
+		}
+		return NetRouteList{}, nil
+	})
+	registerTestBackend(t, "test-timeout-slow", func(ctx context.Context) (NetRouteList, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	r := NewResolver(WithBackends("test-timeout-fail", "test-timeout-ok"), WithBackendTimeout(timeout))
+	if _, err := r.FindRoutes(context.Background()); err != nil {
+		t.Errorf("falling through: %s", err)
+	}
+
+	r = NewResolver(WithBackends("test-timeout-slow", "test-timeout-ok"), WithBackendTimeout(timeout))
+	if _, err := r.FindRoutes(context.Background()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestBackendRetriesFailedProbe(t *testing.T) {
+	probes := 0
+	b := &backend{name: "test", probe: func() error {
+		probes++
+		if probes == 1 {
+			return errors.New("not yet")
+		}
+		return nil
+	}}
+
+	if err := b.available(); err == nil {
+		t.Fatal("first probe succeeded")
+	}
+	if err := b.available(); err == nil || probes != 1 {
+		t.Fatalf("failed probe retried right away (%d probes, %v)", probes, err)
+	}
+
+	b.failedAt = b.failedAt.Add(-probeRetryInterval)
+	if err := b.available(); err != nil {
+		t.Fatalf("probe not retried: %s", err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := b.available(); err != nil || probes != 2 {
+			t.Fatalf("successful probe not retained (%d probes, %v)", probes, err)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package defip
+
+import (
+	"context"
+	"net/netip"
+	"sync"
+	"time"
+)
+
+type cachedIP struct {
+	addr netip.Addr
+	at   time.Time
+}
+
+// ipLookup is a detection in progress, whose result is available once done
+// is closed.
+type ipLookup struct {
+	done chan struct{}
+	addr netip.Addr
+	err  error
+}
+
+var ipCache = struct {
+	sync.Mutex
+	entries  map[NetRouteKind]cachedIP
+	inflight map[NetRouteKind]*ipLookup
+}{entries: map[NetRouteKind]cachedIP{}, inflight: map[NetRouteKind]*ipLookup{}}
+
+// FindDefaultIPCached works like FindDefaultIP, but returns the address
+// detected by a previous call when it is not older than maxAge. The cache is
+// shared across the whole process, allowing detection to be invoked from many
+// independent call sites without probing the system each time. Failures are
+// never cached.
+//
+// Calls finding a fresh address are never blocked by detection in progress.
+// Concurrent calls with a stale or empty cache for the same kind share a
+// single detection, and its outcome.
+func FindDefaultIPCached(kind NetRouteKind, maxAge time.Duration) (netip.Addr, error) {
+	ipCache.Lock()
+	if e, ok := ipCache.entries[kind]; ok && time.Since(e.at) <= maxAge {
+		ipCache.Unlock()
+		return e.addr, nil
+	}
+	l, ok := ipCache.inflight[kind]
+	if !ok {
+		l = &ipLookup{done: make(chan struct{})}
+		ipCache.inflight[kind] = l
+	}
+	ipCache.Unlock()
+
+	if ok {
+		<-l.done
+		return l.addr, l.err
+	}
+
+	defer close(l.done)
+	l.addr, l.err = defaultResolver.FindDefaultIP(context.Background(), kind)
+
+	ipCache.Lock()
+	defer ipCache.Unlock()
+	delete(ipCache.inflight, kind)
+	if l.err == nil {
+		ipCache.entries[kind] = cachedIP{addr: l.addr, at: time.Now()}
+	}
+	return l.addr, l.err
+}
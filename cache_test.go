@@ -0,0 +1,87 @@
+package defip
+
+import (
+	"context"
+	"net/netip"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// cacheRelease and cacheCalls control and count collections by the
+// test-cache-slow backend.
+var (
+	cacheRelease chan struct{}
+	cacheCalls   atomic.Int32
+)
+
+func TestFindDefaultIPCached(t *testing.T) {
+	cacheRelease = make(chan struct{})
+	cacheCalls.Store(0)
+	registerTestBackend(t, "test-cache-slow", func(ctx context.Context) (NetRouteList, error) {
+		cacheCalls.Add(1)
+		<-cacheRelease
+		return NetRouteList{}, nil
+	})
+
+	prev := defaultResolver
+	defaultResolver = NewResolver(WithBackends("test-cache-slow"))
+	ipCache.Lock()
+	fresh := netip.MustParseAddr("192.0.2.10")
+	ipCache.entries = map[NetRouteKind]cachedIP{NetRouteKindV4: {addr: fresh, at: time.Now()}}
+	ipCache.Unlock()
+	t.Cleanup(func() {
+		defaultResolver = prev
+		ipCache.Lock()
+		clear(ipCache.entries)
+		ipCache.Unlock()
+	})
+
+	var wg, started sync.WaitGroup
+	errs := make(chan error, 5)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		started.Add(1)
+		go func() {
+			defer wg.Done()
+			started.Done()
+			_, err := FindDefaultIPCached(NetRouteKindV6, time.Minute)
+			errs <- err
+		}()
+	}
+	started.Wait()
+	for cacheCalls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// Detection for IPv6 is in progress, and must not block callers
+	// finding a fresh IPv4 address.
+	done := make(chan netip.Addr)
+	go func() {
+		addr, _ := FindDefaultIPCached(NetRouteKindV4, time.Minute)
+		done <- addr
+	}()
+	select {
+	case addr := <-done:
+		if addr != fresh {
+			t.Errorf("got %s, want %s", addr, fresh)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("fresh lookup blocked by detection in progress")
+	}
+
+	// Give every caller time to join the detection in progress.
+	time.Sleep(50 * time.Millisecond)
+	close(cacheRelease)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err == nil {
+			t.Error("got an address from an empty routing table")
+		}
+	}
+	if n := cacheCalls.Load(); n != 1 {
+		t.Errorf("routing table collected %d times by concurrent callers, want 1", n)
+	}
+}
@@ -0,0 +1,107 @@
+package defip
+
+import (
+	"net/netip"
+	"regexp"
+	"runtime"
+)
+
+// InterfaceClass classifies interfaces according to their role, allowing
+// interfaces that rarely carry the host's real connectivity to be excluded
+// from selection.
+type InterfaceClass uint8
+
+const (
+	// InterfaceClassGeneric indicates an interface not matching any other
+	// class, such as physical NICs.
+	InterfaceClassGeneric InterfaceClass = iota
+
+	// InterfaceClassVirtualSwitch indicates a host-side virtual switch used
+	// by hypervisors or container runtimes, such as Hyper-V's vEthernet
+	// adapters (WSL, Default Switch) on Windows, or docker0 and virbr0 on
+	// Linux.
+	InterfaceClassVirtualSwitch
+
+	// InterfaceClassPeerToPeer indicates an interface used for ad hoc,
+	// device-to-device links, such as awdl0 and llw0 used by AirDrop on macOS.
+	// These periodically acquire addresses without providing connectivity.
+	InterfaceClassPeerToPeer
+
+	// InterfaceClassOverlay indicates an interface attached to an overlay
+	// network, such as Tailscale or ZeroTier. See OverlayNetworks.
+	InterfaceClassOverlay
+)
+
+func (c InterfaceClass) String() string {
+	switch c {
+	case InterfaceClassVirtualSwitch:
+		return "virtual-switch"
+	case InterfaceClassPeerToPeer:
+		return "peer-to-peer"
+	case InterfaceClassOverlay:
+		return "overlay"
+	}
+	return "generic"
+}
+
+// parseInterfaceClass returns the InterfaceClass whose String is name.
+func parseInterfaceClass(name string) (InterfaceClass, bool) {
+	for _, c := range []InterfaceClass{InterfaceClassGeneric, InterfaceClassVirtualSwitch, InterfaceClassPeerToPeer, InterfaceClassOverlay} {
+		if c.String() == name {
+			return c, true
+		}
+	}
+	return 0, false
+}
+
+type interfaceClassRule struct {
+	pattern *regexp.Regexp
+	class   InterfaceClass
+}
+
+// interfaceClassRules maps GOOS values to rules classifying interfaces by
+// name. The first matching rule wins.
+var interfaceClassRules = map[string][]interfaceClassRule{
+	"windows": {
+		{regexp.MustCompile(`^vEthernet \(`), InterfaceClassVirtualSwitch},
+	},
+	"darwin": {
+		{regexp.MustCompile(`^(awdl|llw)\d+$`), InterfaceClassPeerToPeer},
+		// Internet Sharing and Personal Hotspot bridges start at bridge100,
+		// leaving lower numbers to user-configured bridges such as the
+		// Thunderbolt Bridge.
+		{regexp.MustCompile(`^bridge\d{3,}$`), InterfaceClassVirtualSwitch},
+	},
+	"linux": {
+		{regexp.MustCompile(`^(docker0|cni0|virbr\d+|podman\d+)$`), InterfaceClassVirtualSwitch},
+	},
+}
+
+// ClassifyInterface returns the class of the named interface, based on naming
+// conventions of the running platform. Overlay interfaces that can only be
+// recognised by their addresses are reported as such during selection, but
+// not by ClassifyInterface; see OverlayNetworks.
+func ClassifyInterface(name string) InterfaceClass {
+	if overlayOf(name, nil) != "" {
+		return InterfaceClassOverlay
+	}
+	return classifyInterface(runtime.GOOS, name)
+}
+
+// classifyPrefixes works like ClassifyInterface, additionally recognising
+// overlay interfaces by the addresses configured on them.
+func classifyPrefixes(name string, prefixes []netip.Prefix) InterfaceClass {
+	if overlayOf(name, prefixes) != "" {
+		return InterfaceClassOverlay
+	}
+	return classifyInterface(runtime.GOOS, name)
+}
+
+func classifyInterface(goos, name string) InterfaceClass {
+	for _, rule := range interfaceClassRules[goos] {
+		if rule.pattern.MatchString(name) {
+			return rule.class
+		}
+	}
+	return InterfaceClassGeneric
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/heyvito/defip/v2"
+)
+
+// loadConfig reads a defip.Config from the file at path, encoded as YAML
+// when its name ends in .yaml or .yml, and as JSON otherwise.
+func loadConfig(path string) (*defip.Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		v, err := parseYAML(data)
+		if err != nil {
+			return nil, &defip.ErrInvalidConfig{Path: path, Reason: err.Error()}
+		}
+		if data, err = json.Marshal(v); err != nil {
+			return nil, err
+		}
+	}
+
+	var config defip.Config
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err = dec.Decode(&config); err != nil {
+		return nil, &defip.ErrInvalidConfig{Path: path, Reason: err.Error()}
+	}
+	return &config, nil
+}
+
+// yamlLine is a significant line of a YAML document.
+type yamlLine struct {
+	num    int
+	indent int
+	text   string
+}
+
+// parseYAML decodes the subset of YAML configuration files are written in:
+// block mappings and sequences, flow sequences and mappings of scalars, and
+// plain or quoted scalars. Anchors, tags, multi-line scalars, and multiple
+// documents are not supported.
+func parseYAML(data []byte) (any, error) {
+	var lines []yamlLine
+	for i, raw := range strings.Split(string(data), "\n") {
+		text := strings.TrimRight(stripYAMLComment(strings.TrimRight(raw, "\r")), " \t")
+		trimmed := strings.TrimLeft(text, " ")
+		if trimmed == "" || (len(lines) == 0 && trimmed == "---") {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "\t") {
+			return nil, fmt.Errorf("line %d: tabs are not allowed in indentation", i+1)
+		}
+		lines = append(lines, yamlLine{num: i + 1, indent: len(text) - len(trimmed), text: trimmed})
+	}
+	if len(lines) == 0 {
+		return map[string]any{}, nil
+	}
+
+	p := &yamlParser{lines: lines}
+	v, err := p.block(lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.lines) {
+		return nil, fmt.Errorf("line %d: unexpected indentation", p.lines[p.pos].num)
+	}
+	return v, nil
+}
+
+// stripYAMLComment removes a comment from line: a # at its start, or
+// following whitespace, outside quotes.
+func stripYAMLComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else if c == '\\' && quote == '"' {
+				i++
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+// block parses the mapping or sequence starting at the current line, whose
+// entries are indented by indent spaces.
+func (p *yamlParser) block(indent int) (any, error) {
+	if isYAMLSeqItem(p.lines[p.pos].text) {
+		return p.sequence(indent)
+	}
+	return p.mapping(indent)
+}
+
+func (p *yamlParser) sequence(indent int) (any, error) {
+	items := []any{}
+	for p.pos < len(p.lines) && p.lines[p.pos].indent == indent && isYAMLSeqItem(p.lines[p.pos].text) {
+		line := p.lines[p.pos]
+		rest := strings.TrimLeft(line.text[1:], " ")
+		if rest == "" {
+			p.pos++
+			v, err := p.nested(indent, false)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, v)
+			continue
+		}
+		if _, _, ok := splitYAMLKey(rest); ok {
+			// A mapping starting on the same line as the dash, whose
+			// entries are aligned with its first key.
+			p.lines[p.pos] = yamlLine{num: line.num, indent: indent + len(line.text) - len(rest), text: rest}
+			v, err := p.mapping(p.lines[p.pos].indent)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, v)
+			continue
+		}
+		v, err := parseYAMLFlow(rest)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line.num, err)
+		}
+		items = append(items, v)
+		p.pos++
+	}
+	return items, nil
+}
+
+func (p *yamlParser) mapping(indent int) (any, error) {
+	m := map[string]any{}
+	for p.pos < len(p.lines) && p.lines[p.pos].indent == indent {
+		line := p.lines[p.pos]
+		key, rest, ok := splitYAMLKey(line.text)
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected a key", line.num)
+		}
+		if _, dup := m[key]; dup {
+			return nil, fmt.Errorf("line %d: duplicate key `%s'", line.num, key)
+		}
+		p.pos++
+		if rest == "" {
+			v, err := p.nested(indent, true)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = v
+			continue
+		}
+		v, err := parseYAMLFlow(rest)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line.num, err)
+		}
+		m[key] = v
+	}
+	if p.pos < len(p.lines) && p.lines[p.pos].indent > indent {
+		return nil, fmt.Errorf("line %d: unexpected indentation", p.lines[p.pos].num)
+	}
+	return m, nil
+}
+
+// nested parses the block following a key or dash with nothing after it,
+// which is null when absent. Sequences held by mappings may be indented as
+// much as their key, as customary.
+func (p *yamlParser) nested(indent int, inMapping bool) (any, error) {
+	if p.pos >= len(p.lines) {
+		return nil, nil
+	}
+	next := p.lines[p.pos]
+	if next.indent > indent || (inMapping && next.indent == indent && isYAMLSeqItem(next.text)) {
+		return p.block(next.indent)
+	}
+	return nil, nil
+}
+
+func isYAMLSeqItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// splitYAMLKey splits a mapping entry into its key and the value following
+// it, reporting false when text is not one.
+func splitYAMLKey(text string) (key, rest string, ok bool) {
+	end := -1
+	if text[0] == '"' || text[0] == '\'' {
+		closing := strings.IndexByte(text[1:], text[0])
+		if closing < 0 {
+			return "", "", false
+		}
+		end = closing + 2
+		if end >= len(text) || text[end] != ':' {
+			return "", "", false
+		}
+	} else {
+		end = strings.Index(text, ": ")
+		if end < 0 {
+			if !strings.HasSuffix(text, ":") {
+				return "", "", false
+			}
+			end = len(text) - 1
+		}
+	}
+
+	v, err := parseYAMLScalar(text[:end])
+	if err != nil {
+		return "", "", false
+	}
+	return fmt.Sprint(v), strings.TrimSpace(text[end+1:]), true
+}
+
+// parseYAMLFlow parses a scalar, or a flow sequence or mapping of scalars.
+func parseYAMLFlow(text string) (any, error) {
+	switch {
+	case strings.HasPrefix(text, "["):
+		if !strings.HasSuffix(text, "]") {
+			return nil, fmt.Errorf("unterminated flow sequence")
+		}
+		items := []any{}
+		for _, v := range splitYAMLFlow(text[1 : len(text)-1]) {
+			item, err := parseYAMLScalar(v)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	case strings.HasPrefix(text, "{"):
+		if !strings.HasSuffix(text, "}") {
+			return nil, fmt.Errorf("unterminated flow mapping")
+		}
+		m := map[string]any{}
+		for _, v := range splitYAMLFlow(text[1 : len(text)-1]) {
+			key, rest, ok := splitYAMLKey(v)
+			if !ok {
+				return nil, fmt.Errorf("expected a key in `%s'", v)
+			}
+			value, err := parseYAMLScalar(rest)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = value
+		}
+		return m, nil
+	}
+	return parseYAMLScalar(text)
+}
+
+// splitYAMLFlow splits the contents of a flow collection at commas outside
+// quotes, omitting empty entries.
+func splitYAMLFlow(text string) []string {
+	var parts []string
+	var quote byte
+	start := 0
+	for i := 0; i <= len(text); i++ {
+		if i < len(text) {
+			c := text[i]
+			if quote != 0 {
+				if c == quote {
+					quote = 0
+				} else if c == '\\' && quote == '"' {
+					i++
+				}
+				continue
+			}
+			if c == '"' || c == '\'' {
+				quote = c
+				continue
+			}
+			if c != ',' {
+				continue
+			}
+		}
+		if part := strings.TrimSpace(text[start:i]); part != "" {
+			parts = append(parts, part)
+		}
+		start = i + 1
+	}
+	return parts
+}
+
+// parseYAMLScalar parses a plain or quoted scalar, resolving null, booleans,
+// and numbers.
+func parseYAMLScalar(text string) (any, error) {
+	text = strings.TrimSpace(text)
+	switch {
+	case strings.HasPrefix(text, `"`):
+		var s string
+		if err := json.Unmarshal([]byte(text), &s); err != nil {
+			return nil, fmt.Errorf("malformed double-quoted string %s", text)
+		}
+		return s, nil
+	case strings.HasPrefix(text, "'"):
+		if len(text) < 2 || !strings.HasSuffix(text, "'") {
+			return nil, fmt.Errorf("malformed single-quoted string %s", text)
+		}
+		return strings.ReplaceAll(text[1:len(text)-1], "''", "'"), nil
+	}
+
+	switch text {
+	case "", "~", "null", "Null", "NULL":
+		return nil, nil
+	case "true", "True", "TRUE":
+		return true, nil
+	case "false", "False", "FALSE":
+		return false, nil
+	}
+	if (text[0] == '-' || (text[0] >= '0' && text[0] <= '9')) && json.Valid([]byte(text)) {
+		return json.Number(text), nil
+	}
+	if strings.ContainsAny(text[:1], "[]{}&*!|>%@`") {
+		return nil, fmt.Errorf("unsupported value `%s'", text)
+	}
+	return text, nil
+}
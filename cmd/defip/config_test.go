@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/heyvito/defip/v2"
+)
+
+func TestParseYAML(t *testing.T) {
+	doc := `---
+# Detection settings.
+backends: [netlink, 'procfs']
+backend_timeout: 2s   # per attempt
+excluded_interfaces:
+- docker*
+- "veth#*"
+weights: {ula: 2, private: 1, global_unicast: 1}
+nested:
+  list:
+    - name: a
+      value: -1.5
+    -
+      - x
+  empty:
+honor_os_metrics: true
+`
+	v, err := parseYAML([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"backend_timeout":"2s","backends":["netlink","procfs"],"excluded_interfaces":["docker*","veth#*"],` +
+		`"honor_os_metrics":true,"nested":{"empty":null,"list":[{"name":"a","value":-1.5},["x"]]},` +
+		`"weights":{"global_unicast":1,"private":1,"ula":2}}`
+	if string(got) != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestParseYAMLErrors(t *testing.T) {
+	for _, doc := range []string{
+		"a: 1\n  b: 2\n",
+		"a: 1\na: 2\n",
+		"a: [1, 2\n",
+		"just a scalar line\n",
+		"a: &anchor 1\n",
+	} {
+		if v, err := parseYAML([]byte(doc)); err == nil {
+			t.Errorf("%q: got %v, want an error", doc, v)
+		}
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	yamlPath := filepath.Join(dir, "defip.yaml")
+	jsonPath := filepath.Join(dir, "defip.json")
+	if err := os.WriteFile(yamlPath, []byte("backends: [test-a]\nfib: 2\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(jsonPath, []byte(`{"backends": ["test-a"], "fib": 2}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{yamlPath, jsonPath} {
+		config, err := loadConfig(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(config.Backends) != 1 || config.Backends[0] != "test-a" || config.FIB == nil || *config.FIB != 2 {
+			t.Errorf("%s: got %+v", path, config)
+		}
+	}
+
+	badPath := filepath.Join(dir, "bad.yml")
+	if err := os.WriteFile(badPath, []byte("backend: [test-a]\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	var invalid *defip.ErrInvalidConfig
+	if _, err := loadConfig(badPath); !errors.As(err, &invalid) {
+		t.Errorf("got %v, want an ErrInvalidConfig", err)
+	}
+}
+
+func TestConfigFlag(t *testing.T) {
+	registerTestBackends(t)
+	path := filepath.Join(t.TempDir(), "defip.yml")
+	if err := os.WriteFile(path, []byte("backends:\n  - test-a\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	status, stdout, stderr := runDefip("-config", path, "diagnostics")
+	if status != 0 || !strings.Contains(stdout, "198.51.100.0/24") {
+		t.Errorf("got status %d and %s%s", status, stdout, stderr)
+	}
+
+	// Flags take precedence over the configuration file.
+	status, stdout, _ = runDefip("-config", path, "-backends", "test-b", "diagnostics")
+	if status != 0 || strings.Contains(stdout, "198.51.100.0/24") {
+		t.Errorf("got status %d and %s", status, stdout)
+	}
+
+	if status, _, _ = runDefip("-config", filepath.Join(t.TempDir(), "missing.yml"), "diagnostics"); status != 1 {
+		t.Errorf("got status %d for a missing file, want 1", status)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/heyvito/defip/v2"
+)
+
+// diagnosticsReport prints defip.Diagnostics, whose JSON representation is
+// described by diagnostics.schema.json.
+type diagnosticsReport struct {
+	*defip.Diagnostics
+}
+
+func runDiagnostics(ctx context.Context, e *env, args []string) int {
+	flags := flag.NewFlagSet("diagnostics", flag.ContinueOnError)
+	flags.SetOutput(e.stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(e.stderr, "usage: defip diagnostics\n\n"+
+			"Prints the routing table, the addresses selected from it, and the\n"+
+			"environment they were obtained in. JSON and YAML output follow\n"+
+			"diagnostics.schema.json, versioned through schema_version.\n")
+	}
+	if err := flags.Parse(args); err != nil || flags.NArg() != 0 {
+		flags.Usage()
+		return 2
+	}
+
+	d := e.resolver.Diagnostics(ctx)
+	if err := e.print(diagnosticsReport{d}); err != nil {
+		return e.errorf("%s", err)
+	}
+	if d.Error != "" {
+		return e.errorf("%s", d.Error)
+	}
+	return 0
+}
+
+func (d diagnosticsReport) printTable(out io.Writer) {
+	env := d.Environment
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "Collected at\t%s\n", d.CollectedAt.Format(time.RFC3339))
+	fmt.Fprintf(w, "System\t%s/%s %s\n", env.OS, env.Arch, env.OSVersion)
+	if env.Jail != "" {
+		fmt.Fprintf(w, "Jail\t%s\n", env.Jail)
+	}
+	var backends []string
+	for _, b := range env.Backends {
+		switch {
+		case b.LastUsed:
+			backends = append(backends, b.Name+" (used)")
+		case !b.Available:
+			backends = append(backends, b.Name+" (unavailable)")
+		default:
+			backends = append(backends, b.Name)
+		}
+	}
+	fmt.Fprintf(w, "Backends\t%s\n", strings.Join(backends, ", "))
+	w.Flush()
+
+	if d.Error != "" {
+		return
+	}
+
+	fmt.Fprintln(out)
+	w = tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "KIND\tDESTINATION\tGATEWAY\tINTERFACE\tFLAGS")
+	for _, r := range d.Routes {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", r.Kind, destinationString(r), dash(r.Gateway), r.Interface, r.Flags)
+	}
+	w.Flush()
+
+	fmt.Fprintln(out)
+	w = tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "KIND\tADDRESS\tINTERFACE\tMTU\tREASON")
+	for _, s := range d.Selections {
+		if s.Error != "" {
+			fmt.Fprintf(w, "%s\t-\t-\t-\t%s\n", s.Kind, s.Error)
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n", s.Kind, s.Addr, s.Interface, s.MTU, s.Reason)
+	}
+	w.Flush()
+
+	if len(d.Warnings) > 0 {
+		fmt.Fprintf(out, "\n%d row(s) skipped:\n", len(d.Warnings))
+		for _, v := range d.Warnings {
+			fmt.Fprintf(out, "%s:%d: %q: %s\n", v.Source, v.Line, v.Row, v.Reason)
+		}
+	}
+}
+
+// destinationString returns the destination of r, along with its prefix
+// length when known.
+func destinationString(r defip.DiagnosticsRoute) string {
+	if r.PrefixLength == nil {
+		return r.Destination
+	}
+	return r.Destination + "/" + strconv.Itoa(*r.PrefixLength)
+}
+
+// dash returns s, or "-" when empty.
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
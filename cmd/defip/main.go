@@ -0,0 +1,150 @@
+// Command defip inspects the routing table as seen by package defip, and
+// checks its backends against each other.
+//
+// Usage:
+//
+//	defip [flags] command [arguments]
+//
+// The commands are:
+//
+//	diagnostics  print the routing table, selected addresses, and environment
+//	selftest     compare the routing tables obtained by every backend
+//	watch        print changes of the default IP and routing table
+//
+// The flags are:
+//
+//	-backends list
+//		comma-separated backends to use, in order, such as "netlink,procfs"
+//	-config path
+//		configuration file holding a defip.Config, as JSON, or as YAML when
+//		its name ends in .yaml or .yml
+//	-format table|json|yaml
+//		output format, table by default
+//
+// The JSON and YAML output of diagnostics is described by
+// diagnostics.schema.json, at the root of the module, and versioned through
+// its schema_version field. The output of other commands may change across
+// releases.
+//
+// Settings given through flags take precedence over the configuration file.
+// For instance, a YAML configuration file may read:
+//
+//	backends: [netlink, procfs]
+//	backend_timeout: 2s
+//	excluded_interfaces:
+//	  - docker*
+//	  - veth*
+//	weights: {ula: 2, private: 1, global_unicast: 1}
+//	honor_os_metrics: true
+//
+// watch runs actions on changes of the default IP: -webhook POSTs them as
+// JSON, and -exec runs a command, passing them through the environment
+// variables described by notify.ExecAction, such as DEFIP_CURRENT:
+//
+//	defip watch -kind ipv4 -exec "/usr/local/bin/update-firewall --reload"
+//
+// defip exits with status 1 when a command fails, and 2 on usage errors.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/heyvito/defip/v2"
+)
+
+// command is a subcommand of defip.
+type command struct {
+	name    string
+	summary string
+	run     func(ctx context.Context, e *env, args []string) int
+}
+
+var commands = []command{
+	{"diagnostics", "print the routing table, selected addresses, and environment", runDiagnostics},
+	{"selftest", "compare the routing tables obtained by every backend", runSelftest},
+	{"watch", "print changes of the default IP and routing table", runWatch},
+}
+
+// env holds what commands share: the Resolver and output format configured
+// through global flags, and where to write to.
+type env struct {
+	resolver *defip.Resolver
+	format   string
+	stdout   io.Writer
+	stderr   io.Writer
+}
+
+// errorf reports a failure on stderr, returning the status to exit with.
+func (e *env) errorf(format string, args ...any) int {
+	fmt.Fprintf(e.stderr, "defip: "+format+"\n", args...)
+	return 1
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	os.Exit(run(ctx, os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run runs defip with args, returning the status to exit with.
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("defip", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "usage: defip [flags] command [arguments]\n\ncommands:\n")
+		for _, c := range commands {
+			fmt.Fprintf(stderr, "  %-11s  %s\n", c.name, c.summary)
+		}
+		fmt.Fprintf(stderr, "\nflags:\n")
+		flags.PrintDefaults()
+	}
+	backends := flags.String("backends", "", "comma-separated `list` of backends to use, in order")
+	format := flags.String("format", formatTable, "output `format`: table, json, or yaml")
+	configPath := flags.String("config", "", "configuration file, as JSON or YAML, at `path`")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	switch *format {
+	case formatTable, formatJSON, formatYAML:
+	default:
+		fmt.Fprintf(stderr, "defip: unknown format `%s'\n", *format)
+		return 2
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	var opts []defip.Option
+	if *backends != "" {
+		opts = append(opts, defip.WithBackends(strings.Split(*backends, ",")...))
+	}
+	e := &env{format: *format, stdout: stdout, stderr: stderr}
+	if *configPath != "" {
+		config, err := loadConfig(*configPath)
+		if err != nil {
+			return e.errorf("%s", err)
+		}
+		if e.resolver, err = defip.ResolverFromConfig(config, opts...); err != nil {
+			return e.errorf("invalid configuration `%s': %s", *configPath, err)
+		}
+	} else {
+		e.resolver = defip.NewResolver(opts...)
+	}
+
+	name := flags.Arg(0)
+	for _, c := range commands {
+		if c.name == name {
+			return c.run(ctx, e, flags.Args()[1:])
+		}
+	}
+	fmt.Fprintf(stderr, "defip: unknown command `%s'\n", name)
+	flags.Usage()
+	return 2
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/netip"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/heyvito/defip/v2"
+)
+
+var registerOnce sync.Once
+
+// registerTestBackends registers backends returning fixed routing tables,
+// which "test-b" lacks a route of, and "test-c" does not.
+func registerTestBackends(t *testing.T) {
+	t.Helper()
+	registerOnce.Do(func() {
+		routes := defip.NetRouteList{
+			{
+				Kind:        defip.NetRouteKindV4,
+				Destination: netip.IPv4Unspecified(),
+				Gateway:     netip.MustParseAddr("192.0.2.1"),
+				Netif:       "sim0",
+				Flags:       "UG",
+				FlagBits:    defip.RouteFlagUp | defip.RouteFlagGateway,
+				Attrs:       map[string]string{"DestinationPrefix": "0"},
+			},
+			{
+				Kind:        defip.NetRouteKindV4,
+				Destination: netip.MustParseAddr("198.51.100.0"),
+				Netif:       "sim1",
+				Flags:       "U",
+				FlagBits:    defip.RouteFlagUp,
+				Attrs:       map[string]string{"DestinationPrefix": "24"},
+			},
+		}
+		for name, table := range map[string]defip.NetRouteList{"test-a": routes, "test-b": routes[:1], "test-c": routes} {
+			table := table
+			if err := defip.RegisterBackend(name, func(ctx context.Context) (defip.NetRouteList, error) {
+				return table, nil
+			}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	})
+}
+
+// runDefip runs defip with args, returning its status and output.
+func runDefip(args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	status := run(context.Background(), args, &stdout, &stderr)
+	return status, stdout.String(), stderr.String()
+}
+
+func TestUsage(t *testing.T) {
+	if status, _, stderr := runDefip(); status != 2 || !strings.Contains(stderr, "selftest") {
+		t.Errorf("got status %d and %q, want 2 and usage", status, stderr)
+	}
+	if status, _, _ := runDefip("nonexistent"); status != 2 {
+		t.Errorf("got status %d for an unknown command, want 2", status)
+	}
+}
+
+func TestSelftest(t *testing.T) {
+	registerTestBackends(t)
+
+	status, stdout, _ := runDefip("-backends", "test-a,test-b", "selftest")
+	if status != 1 {
+		t.Errorf("got status %d, want 1", status)
+	}
+	if !strings.Contains(stdout, "IPv4 198.51.100.0/24 dev sim1  test-a       test-b") {
+		t.Errorf("disagreement not reported:\n%s", stdout)
+	}
+
+	if status, stdout, _ = runDefip("-backends", "test-a,test-c", "selftest"); status != 0 {
+		t.Errorf("got status %d, want 0:\n%s", status, stdout)
+	}
+}
+
+func TestDiagnosticsFormats(t *testing.T) {
+	registerTestBackends(t)
+
+	status, stdout, stderr := runDefip("-backends", "test-a", "-format", "json", "diagnostics")
+	if status != 0 {
+		t.Fatalf("got status %d: %s", status, stderr)
+	}
+	var d defip.Diagnostics
+	if err := json.Unmarshal([]byte(stdout), &d); err != nil {
+		t.Fatal(err)
+	}
+	if d.SchemaVersion != defip.DiagnosticsSchemaVersion || len(d.Routes) != 2 || len(d.Selections) != 2 {
+		t.Errorf("got %+v, want version %d with 2 routes and 2 selections", d, defip.DiagnosticsSchemaVersion)
+	}
+
+	status, stdout, _ = runDefip("-backends", "test-a", "-format", "yaml", "diagnostics")
+	if status != 0 || !strings.HasPrefix(stdout, "---\nschema_version: 1\n") {
+		t.Errorf("got status %d and YAML:\n%s", status, stdout)
+	}
+
+	status, stdout, _ = runDefip("-backends", "test-a", "diagnostics")
+	if status != 0 || !strings.Contains(stdout, "IPv4  198.51.100.0/24  -") {
+		t.Errorf("got status %d and table:\n%s", status, stdout)
+	}
+
+	if status, _, _ = runDefip("-format", "xml", "diagnostics"); status != 2 {
+		t.Errorf("got status %d for an unknown format, want 2", status)
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Output formats selected through -format.
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+	formatYAML  = "yaml"
+)
+
+// tabler is implemented by command results, printing them for humans.
+type tabler interface {
+	printTable(w io.Writer)
+}
+
+// print writes v to stdout in the format selected through -format. JSON and
+// YAML output are derived from v's JSON encoding.
+func (e *env) print(v tabler) error {
+	switch e.format {
+	case formatJSON:
+		enc := json.NewEncoder(e.stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case formatYAML:
+		return writeYAML(e.stdout, v)
+	default:
+		v.printTable(e.stdout)
+		return nil
+	}
+}
+
+// yamlField is a member of an object decoded by decodeOrdered.
+type yamlField struct {
+	key   string
+	value any
+}
+
+// writeYAML writes the JSON encoding of v to w as a YAML document, keeping
+// the order of object members.
+func writeYAML(w io.Writer, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	tree, err := decodeOrdered(dec)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("---\n")
+	switch tree.(type) {
+	case []yamlField, []any:
+		if isEmptyYAML(tree) {
+			buf.WriteString(yamlScalar(tree) + "\n")
+		} else {
+			writeYAMLNode(&buf, tree, 0)
+		}
+	default:
+		buf.WriteString(yamlScalar(tree) + "\n")
+	}
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// decodeOrdered decodes the next JSON value from dec, representing objects
+// as []yamlField, arrays as []any, and numbers as json.Number.
+func decodeOrdered(dec *json.Decoder) (any, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok {
+	case json.Delim('{'):
+		fields := []yamlField{}
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeOrdered(dec)
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, yamlField{key.(string), value})
+		}
+		_, err = dec.Token()
+		return fields, err
+	case json.Delim('['):
+		items := []any{}
+		for dec.More() {
+			item, err := decodeOrdered(dec)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		_, err = dec.Token()
+		return items, err
+	}
+	return tok, nil
+}
+
+// isEmptyYAML returns whether v is an object or array without members,
+// written in flow style.
+func isEmptyYAML(v any) bool {
+	switch v := v.(type) {
+	case []yamlField:
+		return len(v) == 0
+	case []any:
+		return len(v) == 0
+	}
+	return false
+}
+
+// writeYAMLNode writes an object or array in block style, indented by
+// indent spaces.
+func writeYAMLNode(buf *bytes.Buffer, v any, indent int) {
+	pad := strings.Repeat(" ", indent)
+	switch v := v.(type) {
+	case []yamlField:
+		for _, f := range v {
+			buf.WriteString(pad + yamlString(f.key) + ":")
+			writeYAMLValue(buf, f.value, indent)
+		}
+	case []any:
+		for _, item := range v {
+			buf.WriteString(pad + "-")
+			if fields, ok := item.([]yamlField); ok && len(fields) > 0 {
+				// The first member follows the dash, and the others are
+				// aligned with it.
+				var nested bytes.Buffer
+				writeYAMLNode(&nested, fields, indent+2)
+				buf.WriteString(" ")
+				buf.Write(nested.Bytes()[indent+2:])
+				continue
+			}
+			writeYAMLValue(buf, item, indent)
+		}
+	}
+}
+
+// writeYAMLValue writes v following a key or a dash: on the same line when
+// it is a scalar or empty, and nested below it otherwise.
+func writeYAMLValue(buf *bytes.Buffer, v any, indent int) {
+	switch v.(type) {
+	case []yamlField, []any:
+		if !isEmptyYAML(v) {
+			buf.WriteString("\n")
+			writeYAMLNode(buf, v, indent+2)
+			return
+		}
+	}
+	buf.WriteString(" " + yamlScalar(v) + "\n")
+}
+
+// yamlScalar returns the YAML representation of a scalar, or of an empty
+// object or array.
+func yamlScalar(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(v)
+	case json.Number:
+		return v.String()
+	case string:
+		return yamlString(v)
+	case []yamlField:
+		return "{}"
+	case []any:
+		return "[]"
+	}
+	return fmt.Sprint(v)
+}
+
+// yamlString returns s as a plain scalar when it would be read back as the
+// same string, or double-quoted otherwise.
+func yamlString(s string) string {
+	if needsYAMLQuotes(s) {
+		quoted, _ := json.Marshal(s)
+		return string(quoted)
+	}
+	return s
+}
+
+func needsYAMLQuotes(s string) bool {
+	if s == "" || strings.TrimSpace(s) != s {
+		return true
+	}
+	switch strings.ToLower(s) {
+	case "~", "null", "true", "false", "yes", "no", "on", "off", "y", "n":
+		return true
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return true
+	}
+	if strings.ContainsAny(s[:1], "-?:,[]{}#&*!|>'\"%@`") {
+		return true
+	}
+	if strings.HasSuffix(s, ":") || strings.Contains(s, ": ") || strings.Contains(s, " #") {
+		return true
+	}
+	for _, r := range s {
+		if r < ' ' || r == 0x7f {
+			return true
+		}
+	}
+	return false
+}
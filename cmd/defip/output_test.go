@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteYAML(t *testing.T) {
+	type item struct {
+		Name  string            `json:"name"`
+		Tags  []string          `json:"tags"`
+		Attrs map[string]string `json:"attrs"`
+	}
+	v := struct {
+		Version int    `json:"version"`
+		Empty   []int  `json:"empty"`
+		Items   []item `json:"items"`
+		Nested  [][]int
+		Null    *int `json:"null"`
+	}{
+		Version: 1,
+		Empty:   []int{},
+		Items: []item{
+			{Name: "::", Tags: []string{"true", "a: b", "plain"}, Attrs: map[string]string{"Metric": "100"}},
+			{Name: "fe80::1%eth0", Attrs: map[string]string{}},
+		},
+		Nested: [][]int{{1, 2}},
+	}
+
+	var buf bytes.Buffer
+	if err := writeYAML(&buf, v); err != nil {
+		t.Fatal(err)
+	}
+	want := `---
+version: 1
+empty: []
+items:
+  - name: "::"
+    tags:
+      - "true"
+      - "a: b"
+      - plain
+    attrs:
+      Metric: "100"
+  - name: fe80::1%eth0
+    tags: null
+    attrs: {}
+Nested:
+  -
+    - 1
+    - 2
+"null": null
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/heyvito/defip/v2"
+)
+
+// probeAddrs lists destinations the kernel is asked to route towards, to
+// learn the source address it would pick for each kind. No traffic is sent.
+var probeAddrs = map[defip.NetRouteKind]netip.Addr{
+	defip.NetRouteKindV4: netip.MustParseAddr("1.1.1.1"),
+	defip.NetRouteKindV6: netip.MustParseAddr("2606:4700:4700::1111"),
+}
+
+// selftestReport holds the outcome of selftest.
+type selftestReport struct {
+	Backends      []selftestBackend      `json:"backends"`
+	Disagreements []selftestDisagreement `json:"disagreements"`
+	Probes        []selftestProbe        `json:"probes"`
+}
+
+// selftestBackend describes the routing table obtained from a backend.
+type selftestBackend struct {
+	Name   string `json:"name"`
+	Routes int    `json:"routes"`
+	Error  string `json:"error,omitempty"`
+}
+
+// selftestDisagreement describes a route not reported by every backend.
+type selftestDisagreement struct {
+	Route       string   `json:"route"`
+	ReportedBy  []string `json:"reported_by"`
+	MissingFrom []string `json:"missing_from"`
+}
+
+// selftestProbe compares the address selected for a kind with the source
+// address the kernel picks towards the wider network.
+type selftestProbe struct {
+	Kind     string `json:"kind"`
+	Selected string `json:"selected,omitempty"`
+	Source   string `json:"source,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+func runSelftest(ctx context.Context, e *env, args []string) int {
+	flags := flag.NewFlagSet("selftest", flag.ContinueOnError)
+	flags.SetOutput(e.stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(e.stderr, "usage: defip selftest\n\n"+
+			"Obtains the routing table from every backend, and reports routes they\n"+
+			"disagree on. The address selected for each kind is compared with the\n"+
+			"source address the kernel would use; differences are reported, but do\n"+
+			"not fail the test, as selection may deliberately prefer another one.\n")
+	}
+	if err := flags.Parse(args); err != nil || flags.NArg() != 0 {
+		flags.Usage()
+		return 2
+	}
+
+	reports, disagreements, err := e.resolver.CompareBackends(ctx)
+	if err != nil {
+		return e.errorf("%s", err)
+	}
+
+	report := selftestReport{Disagreements: []selftestDisagreement{}}
+	succeeded := 0
+	for _, v := range reports {
+		b := selftestBackend{Name: v.Name, Routes: len(v.Routes)}
+		if v.Err != nil {
+			b.Error = v.Err.Error()
+		} else {
+			succeeded++
+		}
+		report.Backends = append(report.Backends, b)
+	}
+	for _, v := range disagreements {
+		report.Disagreements = append(report.Disagreements, selftestDisagreement{
+			Route:       routeString(v.Route),
+			ReportedBy:  v.ReportedBy,
+			MissingFrom: v.MissingFrom,
+		})
+	}
+	for _, kind := range []defip.NetRouteKind{defip.NetRouteKindV4, defip.NetRouteKindV6} {
+		report.Probes = append(report.Probes, probe(ctx, e.resolver, kind))
+	}
+
+	if err = e.print(&report); err != nil {
+		return e.errorf("%s", err)
+	}
+	if succeeded == 0 {
+		return e.errorf("no backend could obtain the routing table")
+	}
+	if len(report.Disagreements) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// probe compares the address r selects for kind with the source address the
+// kernel picks towards probeAddrs.
+func probe(ctx context.Context, r *defip.Resolver, kind defip.NetRouteKind) selftestProbe {
+	p := selftestProbe{Kind: kind.String()}
+	sel, err := r.Select(ctx, kind)
+	if err != nil {
+		p.Error = err.Error()
+		return p
+	}
+	p.Selected = sel.Addr.WithZone("").String()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", netip.AddrPortFrom(probeAddrs[kind], 53).String())
+	if err != nil {
+		p.Error = err.Error()
+		return p
+	}
+	defer conn.Close()
+	p.Source = conn.LocalAddr().(*net.UDPAddr).AddrPort().Addr().Unmap().WithZone("").String()
+	return p
+}
+
+func (r *selftestReport) printTable(out io.Writer) {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "BACKEND\tROUTES\tERROR")
+	for _, b := range r.Backends {
+		if b.Error != "" {
+			fmt.Fprintf(w, "%s\t-\t%s\n", b.Name, b.Error)
+		} else {
+			fmt.Fprintf(w, "%s\t%d\n", b.Name, b.Routes)
+		}
+	}
+	w.Flush()
+
+	fmt.Fprintln(out)
+	if len(r.Disagreements) == 0 {
+		fmt.Fprintln(out, "Backends agree on every route.")
+	} else {
+		fmt.Fprintf(out, "%d route(s) not reported by every backend:\n", len(r.Disagreements))
+		w = tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "ROUTE\tREPORTED BY\tMISSING FROM")
+		for _, d := range r.Disagreements {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", d.Route, strings.Join(d.ReportedBy, ","), strings.Join(d.MissingFrom, ","))
+		}
+		w.Flush()
+	}
+
+	fmt.Fprintln(out)
+	for _, p := range r.Probes {
+		switch {
+		case p.Error != "":
+			fmt.Fprintf(out, "%s: not compared: %s\n", p.Kind, p.Error)
+		case p.Selected == p.Source:
+			fmt.Fprintf(out, "%s: selected %s, as the kernel would\n", p.Kind, p.Selected)
+		default:
+			fmt.Fprintf(out, "%s: selected %s, while the kernel would use %s\n", p.Kind, p.Selected, p.Source)
+		}
+	}
+}
+
+// routeString returns a single-line representation of route, including its
+// prefix length, such as "IPv4 0.0.0.0/0 via 192.0.2.1 dev eth0".
+func routeString(route defip.NetRoute) string {
+	dst := route.Destination.String()
+	if p, ok := route.Prefix(); ok {
+		dst = p.String()
+	}
+	s := route.Kind.String() + " " + dst
+	if route.Gateway.IsValid() && !route.Gateway.IsUnspecified() {
+		s += " via " + route.Gateway.String()
+	}
+	return s + " dev " + route.Netif
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/heyvito/defip/v2"
+	"github.com/heyvito/defip/v2/notify"
+)
+
+// watchEvent represents a change of the default IP, or of the routing table,
+// printed by watch.
+type watchEvent struct {
+	// Type is "default_ip" or "routes".
+	Type string    `json:"type"`
+	At   time.Time `json:"at"`
+
+	Kind           string `json:"kind,omitempty"`
+	Previous       string `json:"previous,omitempty"`
+	Current        string `json:"current,omitempty"`
+	PreviousPrefix string `json:"previous_prefix,omitempty"`
+	CurrentPrefix  string `json:"current_prefix,omitempty"`
+	Interface      string `json:"interface,omitempty"`
+
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+	Lost    int      `json:"lost,omitempty"`
+
+	Error string `json:"error,omitempty"`
+
+	// ipEvent is the event a default_ip event was obtained from, passed to
+	// actions.
+	ipEvent *defip.DefaultIPEvent
+}
+
+func defaultIPEventOf(e defip.DefaultIPEvent) watchEvent {
+	w := watchEvent{Type: "default_ip", At: e.At, Kind: e.Kind.String(), ipEvent: &e}
+	if e.Previous.IsValid() {
+		w.Previous = e.Previous.String()
+	}
+	if e.Current.IsValid() {
+		w.Current = e.Current.String()
+	}
+	if e.PreviousPrefix.IsValid() {
+		w.PreviousPrefix = e.PreviousPrefix.String()
+	}
+	if e.CurrentPrefix.IsValid() {
+		w.CurrentPrefix = e.CurrentPrefix.String()
+	}
+	if e.Selection != nil {
+		w.Interface = e.Selection.Interface.Name
+	}
+	if e.Err != nil {
+		w.Error = e.Err.Error()
+	}
+	return w
+}
+
+func routeEventOf(e defip.RouteEvent) watchEvent {
+	w := watchEvent{Type: "routes", At: e.Snapshot.CollectedAt, Lost: e.Lost}
+	if e.Err != nil {
+		w.At = time.Now()
+		w.Error = e.Err.Error()
+	}
+	for _, v := range e.Added {
+		w.Added = append(w.Added, routeString(v))
+	}
+	for _, v := range e.Removed {
+		w.Removed = append(w.Removed, routeString(v))
+	}
+	return w
+}
+
+func runWatch(ctx context.Context, e *env, args []string) int {
+	flags := flag.NewFlagSet("watch", flag.ContinueOnError)
+	flags.SetOutput(e.stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(e.stderr, "usage: defip watch [flags]\n\n"+
+			"Prints changes of the default IP, and of the routing table with -routes,\n"+
+			"until interrupted. JSON output holds an event per line, and YAML output\n"+
+			"a document per event.\n\nflags:\n")
+		flags.PrintDefaults()
+	}
+	kindName := flags.String("kind", "any", "`kind` of default IP to watch: ipv4, ipv6, or any")
+	interval := flags.Duration("interval", 5*time.Second, "how often to collect the routing table")
+	routes := flags.Bool("routes", false, "print changes of the routing table")
+	webhook := flags.String("webhook", "", "POST default IP changes as JSON to `url`")
+	execCommand := flags.String("exec", "", "run `command` on default IP changes, split at spaces,\npassing them through DEFIP_* environment variables")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 0 {
+		if err == nil {
+			flags.Usage()
+		}
+		return 2
+	}
+
+	var kinds []defip.NetRouteKind
+	switch strings.ToLower(*kindName) {
+	case "ipv4":
+		kinds = []defip.NetRouteKind{defip.NetRouteKindV4}
+	case "ipv6":
+		kinds = []defip.NetRouteKind{defip.NetRouteKindV6}
+	case "any":
+		kinds = []defip.NetRouteKind{defip.NetRouteKindV4, defip.NetRouteKindV6}
+	default:
+		fmt.Fprintf(e.stderr, "defip: unknown kind `%s'\n", *kindName)
+		return 2
+	}
+
+	var actions []notify.ChangeAction
+	if *webhook != "" {
+		actions = append(actions, notify.WebhookAction(*webhook))
+	}
+	if args := strings.Fields(*execCommand); len(args) > 0 {
+		actions = append(actions, notify.ExecAction(args[0], args[1:]...))
+	}
+
+	config := defip.WatchConfig{Interval: *interval}
+	events := make(chan watchEvent)
+	var wg sync.WaitGroup
+	for _, kind := range kinds {
+		wg.Add(1)
+		go func(ch <-chan defip.DefaultIPEvent) {
+			defer wg.Done()
+			for v := range ch {
+				events <- defaultIPEventOf(v)
+			}
+		}(e.resolver.WatchDefaultIP(ctx, kind, config))
+	}
+	if *routes {
+		wg.Add(1)
+		go func(ch <-chan defip.RouteEvent) {
+			defer wg.Done()
+			for v := range ch {
+				events <- routeEventOf(v)
+			}
+		}(e.resolver.WatchRoutes(ctx, config))
+	}
+	go func() {
+		wg.Wait()
+		close(events)
+	}()
+
+	for v := range events {
+		if err := e.printEvent(v); err != nil {
+			return e.errorf("%s", err)
+		}
+		if v.ipEvent == nil {
+			continue
+		}
+		for _, action := range actions {
+			if err := action(ctx, *v.ipEvent); err != nil && ctx.Err() == nil {
+				fmt.Fprintf(e.stderr, "defip: %s\n", err)
+			}
+		}
+	}
+	return 0
+}
+
+// printEvent prints v as print does, but on a single line when printing
+// JSON, so events can be processed as they arrive.
+func (e *env) printEvent(v watchEvent) error {
+	if e.format == formatJSON {
+		return json.NewEncoder(e.stdout).Encode(v)
+	}
+	return e.print(v)
+}
+
+func (v watchEvent) printTable(w io.Writer) {
+	at := v.At.Format(time.RFC3339)
+	if v.Type == "routes" {
+		if v.Error != "" {
+			fmt.Fprintf(w, "%s routes: %s\n", at, v.Error)
+		}
+		if v.Lost > 0 {
+			fmt.Fprintf(w, "%s routes: %d change(s) lost\n", at, v.Lost)
+		}
+		for _, r := range v.Removed {
+			fmt.Fprintf(w, "%s - %s\n", at, r)
+		}
+		for _, r := range v.Added {
+			fmt.Fprintf(w, "%s + %s\n", at, r)
+		}
+		return
+	}
+
+	current := v.Current
+	if current == "" {
+		current = "none (" + v.Error + ")"
+	} else if v.Interface != "" {
+		current += " on " + v.Interface
+	}
+	if v.Previous == "" {
+		fmt.Fprintf(w, "%s %s %s\n", at, v.Kind, current)
+		return
+	}
+	fmt.Fprintf(w, "%s %s %s -> %s\n", at, v.Kind, v.Previous, current)
+}
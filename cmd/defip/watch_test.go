@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWatch(t *testing.T) {
+	registerTestBackends(t)
+	var posted atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		posted.Add(1)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	var stdout, stderr bytes.Buffer
+	args := []string{"-backends", "test-a", "-format", "json", "watch", "-kind", "ipv4", "-interval", "10ms", "-routes", "-webhook", srv.URL}
+	if status := run(ctx, args, &stdout, &stderr); status != 0 {
+		t.Fatalf("got status %d: %s", status, stderr.String())
+	}
+
+	var ipEvents, routeEvents int
+	for _, line := range strings.Split(strings.TrimSpace(stdout.String()), "\n") {
+		var e watchEvent
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("%q: %s", line, err)
+		}
+		switch e.Type {
+		case "default_ip":
+			ipEvents++
+			// The interfaces of the test backend do not exist.
+			if e.Kind != "IPv4" || e.Error == "" {
+				t.Errorf("got %+v, want an IPv4 event reporting an error", e)
+			}
+		case "routes":
+			routeEvents++
+			if len(e.Added) != 2 {
+				t.Errorf("got %+v, want both routes added", e)
+			}
+		}
+	}
+	// Neither the routing table nor the selection outcome changes after
+	// the first events.
+	if ipEvents != 1 || routeEvents != 1 {
+		t.Errorf("got %d default IP and %d route events, want 1 each:\n%s", ipEvents, routeEvents, stdout.String())
+	}
+	if n := posted.Load(); n != 1 {
+		t.Errorf("webhook called %d times, want 1", n)
+	}
+}
+
+func TestWatchExec(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+	registerTestBackends(t)
+	dir := t.TempDir()
+	script := filepath.Join(dir, "notify.sh")
+	out := filepath.Join(dir, "out")
+	if err := os.WriteFile(script, []byte(`echo "$DEFIP_KIND $1" >> "$2"`+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	var stdout, stderr bytes.Buffer
+	args := []string{"-backends", "test-a", "watch", "-kind", "ipv4", "-interval", "10ms", "-exec", "sh " + script + " changed " + out}
+	if status := run(ctx, args, &stdout, &stderr); status != 0 {
+		t.Fatalf("got status %d: %s", status, stderr.String())
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(data); got != "IPv4 changed\n" {
+		t.Errorf("got %q, want a single IPv4 run", got)
+	}
+}
+
+func TestWatchUsage(t *testing.T) {
+	if status, _, _ := runDefip("watch", "-kind", "ipx"); status != 2 {
+		t.Errorf("got status %d for an unknown kind, want 2", status)
+	}
+}
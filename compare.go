@@ -0,0 +1,107 @@
+package defip
+
+import (
+	"context"
+	"slices"
+)
+
+// BackendReport holds the routing table obtained from a single backend by
+// CompareBackends.
+type BackendReport struct {
+	// Name identifies the backend, as listed by Backends.
+	Name string
+
+	// Routes lists the routes obtained from the backend.
+	Routes NetRouteList
+
+	// Err holds why routes could not be obtained from the backend.
+	Err error
+}
+
+// BackendDisagreement describes a route not reported by every backend
+// compared by CompareBackends.
+type BackendDisagreement struct {
+	// Route is the route, as reported by the first backend in ReportedBy.
+	Route NetRoute
+
+	// ReportedBy and MissingFrom list the backends reporting Route, and
+	// those that do not, respectively.
+	ReportedBy  []string
+	MissingFrom []string
+}
+
+// backendRouteKey identifies a route across backends.
+type backendRouteKey struct {
+	dedupeKey
+	bits int
+}
+
+func backendRouteKeyOf(r NetRoute) backendRouteKey {
+	bits := -1
+	if p, ok := r.Prefix(); ok {
+		bits = p.Bits()
+	}
+	return backendRouteKey{dedupeKeyOf(r), bits}
+}
+
+// CompareBackends obtains the routing table from each of the Resolver's
+// backends (see WithBackends), and reports routes on which backends that
+// succeeded disagree, helping catch parser bugs, such as on new operating
+// system releases. Routes are compared by kind, destination, prefix length,
+// gateway, and interface.
+func (r *Resolver) CompareBackends(ctx context.Context) ([]BackendReport, []BackendDisagreement, error) {
+	bs, err := orderBackends(r.backends)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var reports []BackendReport
+	var compared []string
+	for _, b := range bs {
+		report := BackendReport{Name: b.name}
+		if report.Err = b.available(); report.Err == nil {
+			ctx, cancel := attemptContext(r.backendContext(ctx), r.timeout)
+			report.Routes, report.Err = b.routes(ctx)
+			cancel()
+		}
+		if report.Err == nil {
+			compared = append(compared, b.name)
+		}
+		reports = append(reports, report)
+	}
+
+	var keys []backendRouteKey
+	found := map[backendRouteKey]*BackendDisagreement{}
+	for _, report := range reports {
+		if report.Err != nil {
+			continue
+		}
+		for _, route := range report.Routes {
+			key := backendRouteKeyOf(route)
+			d, ok := found[key]
+			if !ok {
+				d = &BackendDisagreement{Route: route}
+				found[key] = d
+				keys = append(keys, key)
+			}
+			if !slices.Contains(d.ReportedBy, report.Name) {
+				d.ReportedBy = append(d.ReportedBy, report.Name)
+			}
+		}
+	}
+
+	var disagreements []BackendDisagreement
+	for _, key := range keys {
+		d := found[key]
+		if len(d.ReportedBy) == len(compared) {
+			continue
+		}
+		for _, name := range compared {
+			if !slices.Contains(d.ReportedBy, name) {
+				d.MissingFrom = append(d.MissingFrom, name)
+			}
+		}
+		disagreements = append(disagreements, *d)
+	}
+	return reports, disagreements, nil
+}
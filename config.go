@@ -0,0 +1,174 @@
+package defip
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"time"
+)
+
+// Config holds Resolver settings loaded from a JSON file through
+// ResolverFromJSONConfig, allowing detection to be standardised across hosts
+// without code changes. Omitted settings keep the defaults of NewResolver.
+type Config struct {
+	// Backends lists routing table backends to use, in order. See
+	// WithBackends. As names are only checked when routes are obtained, a
+	// file shared by hosts running different platforms should leave it out.
+	Backends []string `json:"backends"`
+
+	// BackendTimeout bounds each attempt to obtain the routing table, as a
+	// duration such as "2s". See WithBackendTimeout.
+	BackendTimeout string `json:"backend_timeout"`
+
+	// MaxBackendOutput is the maximum amount of output, in bytes, buffered
+	// from programs. See WithMaxBackendOutput.
+	MaxBackendOutput *int64 `json:"max_backend_output"`
+
+	// FIB selects the forwarding table routes are obtained from on FreeBSD.
+	// See WithFIB.
+	FIB *int `json:"fib"`
+
+	// ExcludedInterfaces lists shell patterns, as used by path.Match, of
+	// interface names whose routes are ignored, such as "docker*".
+	ExcludedInterfaces []string `json:"excluded_interfaces"`
+
+	// ExcludedInterfaceClasses lists names of interface classes, such as
+	// "overlay", as returned by InterfaceClass.String. See
+	// WithExcludedInterfaceClasses.
+	ExcludedInterfaceClasses []string `json:"excluded_interface_classes"`
+
+	// Weights replaces DefaultWeights. See WithWeights.
+	Weights *Weights `json:"weights"`
+
+	HonorOSMetrics    *bool `json:"honor_os_metrics"`
+	DefaultRoutesOnly *bool `json:"default_routes_only"`
+	IgnoreLinkState   *bool `json:"ignore_link_state"`
+	LinkMembers       *bool `json:"link_members"`
+}
+
+// ErrInvalidConfig indicates a configuration file that could not be loaded.
+type ErrInvalidConfig struct {
+	// Path is the file the configuration was read from.
+	Path string
+
+	// Reason describes what is wrong with it.
+	Reason string
+}
+
+func (e *ErrInvalidConfig) Error() string {
+	return fmt.Sprintf("invalid configuration `%s': %s", e.Path, e.Reason)
+}
+
+// ResolverFromJSONConfig returns a Resolver configured by the file at path,
+// holding a Config encoded as JSON. YAML and TOML files are not supported,
+// as the package has no dependencies; convert them to JSON beforehand.
+// Unknown settings are rejected, so misspelled ones do not go unnoticed. For
+// instance:
+//
+//	{
+//		"backends": ["procfs"],
+//		"backend_timeout": "2s",
+//		"excluded_interfaces": ["docker*", "veth*"],
+//		"excluded_interface_classes": ["virtual-switch", "peer-to-peer", "overlay"],
+//		"weights": {"ula": 2, "private": 1, "global_unicast": 1},
+//		"honor_os_metrics": true
+//	}
+//
+// Options are applied after the configuration, and take precedence over it.
+func ResolverFromJSONConfig(path string, opts ...Option) (*Resolver, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var config Config
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err = dec.Decode(&config); err != nil {
+		return nil, &ErrInvalidConfig{Path: path, Reason: err.Error()}
+	}
+
+	r, err := ResolverFromConfig(&config, opts...)
+	if err != nil {
+		return nil, &ErrInvalidConfig{Path: path, Reason: err.Error()}
+	}
+	return r, nil
+}
+
+// ResolverFromConfig returns a Resolver configured by config, such as one
+// decoded by the caller from a file in another format than JSON. Fails when
+// a setting is invalid, such as a malformed duration. Options are applied
+// after the configuration, and take precedence over it.
+func ResolverFromConfig(config *Config, opts ...Option) (*Resolver, error) {
+	configOpts, err := config.options()
+	if err != nil {
+		return nil, err
+	}
+	return NewResolver(append(configOpts, opts...)...), nil
+}
+
+// options converts c into the options it stands for.
+func (c *Config) options() ([]Option, error) {
+	var opts []Option
+	if c.Backends != nil {
+		opts = append(opts, WithBackends(c.Backends...))
+	}
+	if c.BackendTimeout != "" {
+		d, err := time.ParseDuration(c.BackendTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("backend_timeout: %w", err)
+		}
+		opts = append(opts, WithBackendTimeout(d))
+	}
+	if c.MaxBackendOutput != nil {
+		opts = append(opts, WithMaxBackendOutput(*c.MaxBackendOutput))
+	}
+	if c.FIB != nil {
+		opts = append(opts, WithFIB(*c.FIB))
+	}
+	if c.ExcludedInterfaces != nil {
+		for _, pattern := range c.ExcludedInterfaces {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("excluded_interfaces: `%s': %w", pattern, err)
+			}
+		}
+		patterns := c.ExcludedInterfaces
+		opts = append(opts, WithRouteFilter(func(route NetRoute) bool {
+			for _, pattern := range patterns {
+				if ok, _ := path.Match(pattern, route.Netif); ok {
+					return false
+				}
+			}
+			return DefaultRouteFilter(route)
+		}))
+	}
+	if c.ExcludedInterfaceClasses != nil {
+		classes := []InterfaceClass{}
+		for _, name := range c.ExcludedInterfaceClasses {
+			class, ok := parseInterfaceClass(name)
+			if !ok {
+				return nil, fmt.Errorf("excluded_interface_classes: unknown class `%s'", name)
+			}
+			classes = append(classes, class)
+		}
+		opts = append(opts, WithExcludedInterfaceClasses(classes...))
+	}
+	if c.Weights != nil {
+		opts = append(opts, WithWeights(*c.Weights))
+	}
+	if c.HonorOSMetrics != nil {
+		opts = append(opts, WithHonorOSMetrics(*c.HonorOSMetrics))
+	}
+	if c.DefaultRoutesOnly != nil {
+		opts = append(opts, WithDefaultRoutesOnly(*c.DefaultRoutesOnly))
+	}
+	if c.IgnoreLinkState != nil {
+		opts = append(opts, WithIgnoreLinkState(*c.IgnoreLinkState))
+	}
+	if c.LinkMembers != nil {
+		opts = append(opts, WithLinkMembers(*c.LinkMembers))
+	}
+	return opts, nil
+}
@@ -2,11 +2,16 @@ package defip
 
 import (
 	"cmp"
+	"context"
+	"errors"
 	"fmt"
+	"math/bits"
 	"net"
 	"net/netip"
 	"slices"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type NetRouteKind uint8
@@ -14,6 +19,9 @@ type NetRouteKind uint8
 const (
 	NetRouteKindV4 NetRouteKind = iota + 1
 	NetRouteKindV6
+
+	// NetRouteKindAny matches both IPv4 and IPv6 routes and addresses.
+	NetRouteKindAny
 )
 
 func (n NetRouteKind) String() string {
@@ -22,18 +30,191 @@ func (n NetRouteKind) String() string {
 		return "IPv4"
 	case NetRouteKindV6:
 		return "IPv6"
+	case NetRouteKindAny:
+		return "Any"
 	}
 	panic("Invalid NetRouteKind")
 }
 
+// kindOf returns the NetRouteKind matching the family of addr.
+func kindOf(addr netip.Addr) NetRouteKind {
+	if addr.Is4() {
+		return NetRouteKindV4
+	}
+	return NetRouteKindV6
+}
+
+// Matches returns whether a route of kind other is matched by n.
+func (n NetRouteKind) Matches(other NetRouteKind) bool {
+	return n == other || n == NetRouteKindAny
+}
+
+// MatchesAddr returns whether the family of addr is matched by n.
+func (n NetRouteKind) MatchesAddr(addr netip.Addr) bool {
+	return (n.Matches(NetRouteKindV4) && addr.Is4()) ||
+		(n.Matches(NetRouteKindV6) && addr.Is6())
+}
+
 type NetRoute struct {
 	Kind        NetRouteKind
 	Destination netip.Addr
 	Flags       string
 	Netif       string
 	Gateway     netip.Addr
+
+	// FlagBits holds Flags translated into a platform-independent bitmask.
+	FlagBits RouteFlag
+
+	// Attrs holds backend-specific attributes not represented by other fields,
+	// such as Refs and Use on BSD systems, or Metric and MTU on Linux. Routes
+	// parsed from netstat carry a Table attribute numbering the table they were
+	// listed in, starting from 1. Expire, when set, holds the remaining
+	// lifetime of the route in seconds.
+	Attrs map[string]string
+
+	// GatewayHardware holds the hardware address of a directly attached
+	// neighbor, for routes whose gateway is listed as such, in which case
+	// Gateway is the zero Addr. These are host routes learned from ARP or NDP
+	// on BSD systems, and never lead to default gateways.
+	GatewayHardware net.HardwareAddr
+}
+
+// String returns a compact, single-line representation of the route, such as
+// "IPv4 0.0.0.0 via 192.168.0.1 dev en0 flags UGSc". The "via" clause is
+// omitted for routes without a gateway address, and replaced by "lladdr" for
+// those whose gateway is a hardware address.
+func (n NetRoute) String() string {
+	if n.GatewayHardware != nil {
+		return fmt.Sprintf("%s %s lladdr %s dev %s flags %s", n.Kind, n.Destination, n.GatewayHardware, n.Netif, n.Flags)
+	}
+	if !n.Gateway.IsValid() {
+		return fmt.Sprintf("%s %s dev %s flags %s", n.Kind, n.Destination, n.Netif, n.Flags)
+	}
+	return fmt.Sprintf("%s %s via %s dev %s flags %s", n.Kind, n.Destination, n.Gateway, n.Netif, n.Flags)
+}
+
+// Metric returns the metric of the route as reported by the operating system,
+// and whether it is known.
+func (n NetRoute) Metric() (int, bool) {
+	v, ok := n.Attrs["Metric"]
+	if !ok {
+		return 0, false
+	}
+	metric, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, false
+	}
+	return metric, true
+}
+
+// MTU returns the MTU set on the route, and whether one is set. Routes without
+// an explicit MTU use the MTU of their interface.
+func (n NetRoute) MTU() (int, bool) {
+	for _, key := range []string{"MTU", "Mtu"} {
+		if v, ok := n.Attrs[key]; ok {
+			mtu, err := strconv.Atoi(v)
+			if err != nil || mtu <= 0 {
+				return 0, false
+			}
+			return mtu, true
+		}
+	}
+	return 0, false
+}
+
+// Age returns how long ago the route was installed, and whether it is known.
+// Only the Windows backend reports it, from MIB_IPFORWARD_ROW2. Linux does
+// not record when routes are installed; the netlink backend instead reports
+// how long ago cached routes were last used, in seconds, as the LastUse
+// attribute.
+func (n NetRoute) Age() (time.Duration, bool) {
+	v, ok := n.Attrs["Age"]
+	if !ok {
+		return 0, false
+	}
+	age, err := strconv.ParseUint(v, 10, 32)
+	if err != nil {
+		return 0, false
+	}
+	return time.Duration(age) * time.Second, true
+}
+
+// maskAttrs lists attributes holding the netmask of a route's destination,
+// either as hexadecimal, as in /proc/net/route, or in dotted form, as printed
+// by netstat variants showing masks.
+var maskAttrs = []string{"Mask", "Netmask", "Genmask"}
+
+// prefixLen returns the prefix length of the route's destination, when
+// reported by the backend.
+func (n NetRoute) prefixLen() (int, bool) {
+	if v, ok := n.Attrs["DestinationPrefix"]; ok {
+		l, err := strconv.Atoi(v)
+		return l, err == nil
+	}
+	for _, key := range maskAttrs {
+		if v, ok := n.Attrs[key]; ok {
+			return maskLen(v)
+		}
+	}
+	return 0, false
+}
+
+// maskLen returns the number of bits set in an IPv4 netmask expressed either
+// as hexadecimal or in dotted form.
+func maskLen(v string) (int, bool) {
+	mask, ok := ip4FromHex(v)
+	if !ok {
+		var err error
+		if mask, err = netip.ParseAddr(v); err != nil || !mask.Is4() {
+			return 0, false
+		}
+	}
+	l := 0
+	for _, b := range mask.As4() {
+		l += bits.OnesCount8(b)
+	}
+	return l, true
+}
+
+// Prefix returns the route's destination along with its prefix length, such
+// as 0.0.0.0/0 for default routes, and whether the prefix length was reported
+// by the backend. The destination's zone is dropped, as netip.Prefix cannot
+// hold one.
+func (n NetRoute) Prefix() (netip.Prefix, bool) {
+	l, ok := n.prefixLen()
+	if !ok {
+		return netip.Prefix{}, false
+	}
+	return netip.PrefixFrom(n.Destination.WithZone(""), l), true
+}
+
+// IsDefault returns whether the route is a true default route, covering the
+// whole address space of its family (0.0.0.0/0 or ::/0). Routes covering only
+// part of it, such as the 0.0.0.0/1 and 128.0.0.0/1 pairs installed by some
+// VPN clients, are not considered default routes. Routes whose prefix length
+// is unknown are considered default routes when their destination is
+// unspecified.
+func (n NetRoute) IsDefault() bool {
+	if !n.Destination.IsUnspecified() {
+		return false
+	}
+	p, ok := n.Prefix()
+	return !ok || p.Bits() == 0
+}
+
+// IsV4Mapped returns whether the route is an IPv6 route whose destination or
+// gateway is an IPv4-mapped address (::ffff:0:0/96), as some systems list in
+// their IPv6 tables for IPv4 traffic sent through IPv6 sockets. Such routes
+// carry IPv4 traffic, and never lead to IPv6 default gateways.
+func (n NetRoute) IsV4Mapped() bool {
+	return n.Kind == NetRouteKindV6 && (n.Destination.Is4In6() || n.Gateway.Is4In6())
 }
 
+// HasFlags returns whether every provided string is contained in the route's
+// platform-specific Flags.
+//
+// Deprecated: HasFlags performs substring matching against Flags, whose
+// alphabet varies across platforms. Use HasAllFlags or HasAnyFlags instead.
 func (n NetRoute) HasFlags(flags ...string) bool {
 	for _, v := range flags {
 		if !strings.Contains(n.Flags, v) {
@@ -44,18 +225,89 @@ func (n NetRoute) HasFlags(flags ...string) bool {
 	return true
 }
 
+// IsOnLink returns whether the route has no gateway (represented as an
+// unspecified address such as 0.0.0.0 or ::, or the zero netip.Addr),
+// meaning its destination is reached directly through its interface.
+func (n NetRoute) IsOnLink() bool {
+	return !n.Gateway.IsValid() || n.Gateway.IsUnspecified()
+}
+
+// withLinkZone returns addr scoped to netif when it is a link-local IPv6
+// address lacking a zone, as such addresses are only meaningful along with the
+// interface they are reached through.
+func withLinkZone(addr netip.Addr, netif string) netip.Addr {
+	if addr.Is6() && addr.Zone() == "" && netif != "" &&
+		(addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast()) {
+		return addr.WithZone(netif)
+	}
+	return addr
+}
+
 type NetRouteList []NetRoute
 
-var filterRoute = func(r *NetRoute) bool {
-	return r.HasFlags("U", "G") &&
-		!r.HasFlags("H")
+// DefaultRouteFilter is the predicate used to determine whether a route leads
+// to a default gateway: it must be up (U), go through a gateway (G), and must
+// not be a host route (H). Host routes are excluded as they only reach a single
+// destination through the gateway (e.g. redirects or VPN endpoint pins), and
+// therefore say nothing about which interface carries general traffic.
+//
+// On-link default routes (see NetRoute.IsOnLink), which carry no gateway flag
+// as the whole network is reachable directly through the interface, are also
+// accepted. These are common on cloud VMs, such as on Azure. IPv4-mapped
+// routes (see NetRoute.IsV4Mapped) are rejected.
+//
+// This is the single predicate used by NetRouteList.FindDefaults, and by
+// FindDefaultIP to determine candidate interfaces, so both always consider the
+// same set of routes. FindDefaultIP additionally restricts candidates to true
+// default routes unless disabled through WithDefaultRoutesOnly.
+func DefaultRouteFilter(r NetRoute) bool {
+	if !r.HasAllFlags(RouteFlagUp) || r.HasAnyFlags(RouteFlagHost|RouteFlagReject) || r.IsV4Mapped() {
+		return false
+	}
+
+	return r.HasAllFlags(RouteFlagGateway) || (r.IsOnLink() && r.IsDefault())
 }
 
+// FindDefaults returns routes of a given kind accepted by DefaultRouteFilter.
+// Duplicate routes, which DHCP and static configurations of the same default
+// may yield, are only returned once; see Deduplicate.
 func (n NetRouteList) FindDefaults(kind NetRouteKind) []NetRoute {
+	return NetRouteList(n.FindDefaultsFunc(kind, DefaultRouteFilter)).Deduplicate()
+}
+
+type dedupeKey struct {
+	kind        NetRouteKind
+	destination netip.Addr
+	gateway     netip.Addr
+	netif       string
+}
+
+func dedupeKeyOf(r NetRoute) dedupeKey {
+	return dedupeKey{r.Kind, r.Destination, r.Gateway, r.Netif}
+}
+
+// Deduplicate returns a copy of the list retaining only the first of routes
+// sharing the same kind, destination, gateway, and interface.
+func (n NetRouteList) Deduplicate() NetRouteList {
+	seen := map[dedupeKey]bool{}
+	var result NetRouteList
+	for _, v := range n {
+		if seen[dedupeKeyOf(v)] {
+			continue
+		}
+		seen[dedupeKeyOf(v)] = true
+		result = append(result, v)
+	}
+
+	return result
+}
+
+// FindDefaultsFunc returns routes of a given kind accepted by fn.
+func (n NetRouteList) FindDefaultsFunc(kind NetRouteKind, fn func(NetRoute) bool) []NetRoute {
 	var result []NetRoute
 
 	for _, v := range n {
-		if v.Kind == kind && filterRoute(&v) {
+		if kind.Matches(v.Kind) && fn(v) {
 			result = append(result, v)
 		}
 	}
@@ -63,11 +315,11 @@ func (n NetRouteList) FindDefaults(kind NetRouteKind) []NetRoute {
 	return result
 }
 
-var getRoutes func() (NetRouteList, error) = nil
-
 // FindRoutes returns a list of detected routes to default gateways
+//
+// Deprecated: Use Resolver.FindRoutes.
 func FindRoutes() (NetRouteList, error) {
-	return getRoutes()
+	return defaultResolver.FindRoutes(context.Background())
 }
 
 func filter[S interface{ ~[]E }, E any](set S, fn func(i E) bool) S {
@@ -82,63 +334,67 @@ func filter[S interface{ ~[]E }, E any](set S, fn func(i E) bool) S {
 }
 
 // FindDefaultIP attempts to find an IP of given NetRouteKind that's most likely
-// connected to wider network. Returns ErrNoIP in case no IP with the given kind
-// can be detected.
+// connected to wider network. When kind is NetRouteKindAny, addresses of both
+// families are considered. Returns ErrNoIP itself in case no IP with the
+// given kind can be detected, so callers comparing errors with == keep
+// working.
+//
+// Deprecated: Use Resolver.FindDefaultIP, which returns the address by value.
 func FindDefaultIP(kind NetRouteKind) (*netip.Addr, error) {
-	routes, err := FindRoutes()
+	ip, err := defaultResolver.FindDefaultIP(context.Background(), kind)
 	if err != nil {
-		panic(err)
+		return nil, compatErr(err)
 	}
 
-	routes = filter(routes, func(i NetRoute) bool {
-		return i.HasFlags("U", "G")
-	})
+	return &ip, nil
+}
 
-	ifaces := map[string]bool{}
-	for _, v := range routes {
-		ifaces[v.Netif] = true
+// compatErr returns ErrNoIP itself for errors wrapping it, as returned by
+// deprecated functions predating wrapped errors.
+func compatErr(err error) error {
+	if errors.Is(err, ErrNoIP) {
+		return ErrNoIP
 	}
+	return err
+}
 
-	var addrs []netip.Addr
-	for name := range ifaces {
-		iface, err := net.InterfaceByName(name)
-		if err != nil {
-			return nil, fmt.Errorf("could not get interface `%s': %w", name, err)
-		}
+// Weights configures the score given to candidate addresses during selection.
+// Addresses with higher scores are preferred. Each matching category adds its
+// weight to the address' score.
+type Weights struct {
+	// ULA is added to IPv6 Unique Local Addresses (fd00::/8).
+	ULA int `json:"ula"`
 
-		ips, err := iface.Addrs()
-		if err != nil {
-			return nil, fmt.Errorf("could not get IPs for interface `%s': %w", name, err)
-		}
+	// Private is added to private addresses (RFC 1918 and RFC 4193).
+	Private int `json:"private"`
 
-		for _, v := range ips {
-			rawAdd, ok := v.(*net.IPNet)
-			if !ok {
-				continue
-			}
+	// GlobalUnicast is added to global unicast addresses.
+	GlobalUnicast int `json:"global_unicast"`
+}
 
-			var add netip.Addr
-			if v4 := rawAdd.IP.To4(); v4 != nil {
-				if kind != NetRouteKindV4 {
-					continue
-				}
-				add = netip.AddrFrom4([4]byte(v4))
-			} else {
-				if kind != NetRouteKindV6 {
-					continue
-				}
-				add = netip.AddrFrom16([16]byte(rawAdd.IP))
-			}
-			add = add.WithZone(name)
-			addrs = append(addrs, add)
-		}
-	}
+// DefaultWeights holds the weights used unless configured otherwise through
+// WithWeights.
+var DefaultWeights = Weights{
+	ULA:           2,
+	Private:       1,
+	GlobalUnicast: 1,
+}
 
-	if ip := selectIP(kind, addrs); ip != nil {
-		return ip, nil
+// score returns the score of addr according to w.
+func (w Weights) score(addr netip.Addr) int {
+	weight := 0
+
+	if isULA(addr) {
+		weight += w.ULA
 	}
 
-	return nil, ErrNoIP
+	if addr.IsPrivate() {
+		weight += w.Private
+	}
+	if addr.IsGlobalUnicast() {
+		weight += w.GlobalUnicast
+	}
+	return weight
 }
 
 type ipWeight struct {
@@ -153,31 +409,24 @@ func isULA(addr netip.Addr) bool {
 	return addr.Compare(ulaStart) >= 0 && addr.Compare(ulaEnd) <= 0
 }
 
-func sortWeighted(list []netip.Addr) {
+func sortWeighted(list []netip.Addr, score func(netip.Addr) int) {
 	if len(list) == 0 {
 		return
 	}
 
 	weightList := make([]ipWeight, len(list))
 	for i, v := range list {
-		weight := 0
-
-		if isULA(v) {
-			weight += 2
-		}
-
-		if v.IsPrivate() {
-			weight += 1
-		}
-		if v.IsGlobalUnicast() {
-			weight += 1
-		}
-		weightList[i].weight = weight
+		weightList[i].weight = score(v)
 		weightList[i].addr = v
 	}
 
 	slices.SortFunc(weightList, func(a, b ipWeight) int {
-		return cmp.Compare(b.weight, a.weight)
+		if c := cmp.Compare(b.weight, a.weight); c != 0 {
+			return c
+		}
+		// Break ties by address so the result does not depend on the order
+		// in which interfaces were enumerated.
+		return a.addr.Compare(b.addr)
 	})
 
 	for i, v := range weightList {
@@ -186,16 +435,18 @@ func sortWeighted(list []netip.Addr) {
 
 }
 
-func selectIP(kind NetRouteKind, list []netip.Addr) *netip.Addr {
-	if len(list) == 0 {
-		return nil
-	}
+// selectIP returns the preferred address of a given kind from list, and
+// whether one could be found. Loopback and unspecified addresses are never
+// selected.
+func selectIP(kind NetRouteKind, list []netip.Addr, score func(netip.Addr) int) (netip.Addr, bool) {
 	list = filter(list, func(i netip.Addr) bool {
-		return (kind == NetRouteKindV6 && i.Is6()) ||
-			(kind == NetRouteKindV4 && i.Is4())
+		return kind.MatchesAddr(i) && !i.IsLoopback() && !i.IsUnspecified()
 	})
+	if len(list) == 0 {
+		return netip.Addr{}, false
+	}
 
-	sortWeighted(list)
+	sortWeighted(list, score)
 
-	return &list[0]
+	return list[0], true
 }
@@ -1,17 +1,120 @@
 package defip
 
-func init() {
-	getRoutes = func() (NetRouteList, error) {
-		ip6List, err := getRoutesIPv6(routeV6)
-		if err != nil {
-			return nil, err
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// osVersion returns the running kernel release, such as "6.8.0-45-generic".
+func osVersion() string {
+	v, err := os.ReadFile("/proc/sys/kernel/osrelease")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(v))
+}
+
+func platformRequirements() []Requirement {
+	reqs := []Requirement{
+		// Routing tables are read from procfs should netlink be denied, where
+		// either table suffices; see probeProcfs.
+		{Kind: RequirementNetlinkSocket, Target: "NETLINK_ROUTE", Feature: "routes", Optional: true},
+		{Kind: RequirementReadFile, Target: routeV4, Feature: "IPv4 routes", Optional: true},
+		{Kind: RequirementReadFile, Target: routeV6, Feature: "IPv6 routes", Optional: true},
+		{Kind: RequirementExec, Target: "ip", Feature: "routes", Optional: true},
+		{Kind: RequirementReadFile, Target: "/sys/class/net", Feature: "link state, bond and bridge members, interface metadata", Optional: true},
+	}
+	for _, v := range dhcpLeaseGlobs {
+		reqs = append(reqs, Requirement{Kind: RequirementReadFile, Target: v, Feature: "DHCP classless static routes, Resolver.DefaultOrigin", Optional: true})
+	}
+	reqs = append(reqs, Requirement{Kind: RequirementReadFile, Target: networkdLeaseGlob, Feature: "Resolver.DefaultOrigin", Optional: true})
+	return append(reqs, commonRequirements...)
+}
+
+func platformBackends() []*backend {
+	procfs := &backend{name: "procfs", probe: probeProcfs, routes: procfsRoutes, rawRoutes: procfsRawRoutes}
+	netlink := &backend{name: "netlink", probe: probeNetlink, routes: netlinkRoutes, rawRoutes: netlinkRawRoutes}
+	iproute2 := &backend{name: "iproute2", probe: probeIPRoute, routes: ipRouteRoutes, rawRoutes: ipRouteRawRoutes}
+
+	// Netlink reports metrics and protocols for both families, which
+	// /proc/net/route lacks, and remains permitted on Android, which denies
+	// apps access to procfs routing tables on recent API levels. ip is a
+	// last resort, for sandboxes denying both but allowing ip to run.
+	return []*backend{netlink, procfs, iproute2}
+}
+
+// probeProcfs checks whether either routing table can be opened.
+func probeProcfs() error {
+	var denied []error
+	for _, source := range []string{routeV4, routeV6} {
+		f, err := os.Open(source)
+		if err == nil {
+			f.Close()
+			return nil
+		}
+		if !isSandboxErr(err) {
+			return fmt.Errorf("could not access routing table: %w", err)
 		}
+		denied = append(denied, err)
+	}
+	return &ErrSandboxed{Causes: denied}
+}
 
-		ip4List, err := getRoutesIPv4(routeV4)
+func procfsRoutes(ctx context.Context) (NetRouteList, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// Either table may be missing, such as when IPv6 is disabled, or
+	// denied by a sandbox. Degrade to the other one, failing only when
+	// both are inaccessible.
+	var denied []error
+	log := parseLogFrom(ctx)
+	ip6List, err := getRoutesIPv6(routeV6, log)
+	if isSandboxErr(err) {
+		denied = append(denied, err)
+	} else if err != nil {
+		return nil, err
+	}
+
+	ip4List, err := getRoutesIPv4(routeV4, log)
+	if isSandboxErr(err) {
+		denied = append(denied, err)
+	} else if err != nil {
+		return nil, err
+	}
+
+	if len(denied) == 2 {
+		return nil, &ErrSandboxed{Causes: denied}
+	}
+
+	markClasslessRoutes(ip4List)
+
+	return append(ip4List, ip6List...), nil
+}
+
+func procfsRawRoutes(ctx context.Context) ([]RawRouteMessage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var result []RawRouteMessage
+	for _, source := range []string{routeV4, routeV6} {
+		f, err := os.ReadFile(source)
 		if err != nil {
+			if isSandboxErr(err) {
+				continue
+			}
 			return nil, err
 		}
-
-		return append(ip4List, ip6List...), nil
+		for _, line := range strings.Split(string(f), "\n") {
+			if len(strings.TrimSpace(line)) == 0 {
+				continue
+			}
+			result = append(result, RawRouteMessage{Source: source, Data: []byte(line)})
+		}
 	}
+	return result, nil
 }
@@ -0,0 +1,13 @@
+package defip
+
+import "testing"
+
+func TestNetRouteAge(t *testing.T) {
+	age, ok := NetRoute{Attrs: map[string]string{"Age": "3600"}}.Age()
+	if !ok || age.Hours() != 1 {
+		t.Errorf("got %s (%v), want 1h0m0s", age, ok)
+	}
+	if _, ok := (NetRoute{Attrs: map[string]string{"Age": "-1"}}).Age(); ok {
+		t.Error("malformed age reported as known")
+	}
+}
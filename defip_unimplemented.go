@@ -1,9 +1,9 @@
-//go:build !(darwin || linux)
+//go:build !(aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris || windows)
 
 package defip
 
-func init() {
-	getRoutes = func() (NetRouteList, error) {
-		return nil, ErrNotImplemented{}
-	}
-}
+func osVersion() string { return "" }
+
+func platformRequirements() []Requirement { return nil }
+
+func platformBackends() []*backend { return nil }
@@ -0,0 +1,285 @@
+package defip
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"strconv"
+	"syscall"
+	"unsafe"
+)
+
+// ipForwardSource identifies routes obtained from the IP Helper API in raw
+// routes and errors.
+const ipForwardSource = "GetIpForwardTable2"
+
+var (
+	modIPHlpAPI             = syscall.NewLazyDLL("iphlpapi.dll")
+	procGetIPForwardTable2  = modIPHlpAPI.NewProc("GetIpForwardTable2")
+	procGetIPInterfaceEntry = modIPHlpAPI.NewProc("GetIpInterfaceEntry")
+	procFreeMibTable        = modIPHlpAPI.NewProc("FreeMibTable")
+)
+
+// rawSockaddrInet mirrors SOCKADDR_INET. Data holds the address at offset 0
+// for AF_INET, and at offset 4 for AF_INET6, followed by its scope ID.
+type rawSockaddrInet struct {
+	Family uint16
+	Port   uint16
+	Data   [24]byte
+}
+
+func (s *rawSockaddrInet) addr() netip.Addr {
+	switch s.Family {
+	case syscall.AF_INET:
+		return netip.AddrFrom4([4]byte(s.Data[0:4]))
+	case syscall.AF_INET6:
+		return netip.AddrFrom16([16]byte(s.Data[4:20]))
+	}
+	return netip.Addr{}
+}
+
+// mibIPForwardRow2 mirrors MIB_IPFORWARD_ROW2, whose padding is made
+// explicit.
+type mibIPForwardRow2 struct {
+	InterfaceLuid        uint64
+	InterfaceIndex       uint32
+	DestinationPrefix    rawSockaddrInet
+	DestinationPrefixLen uint8
+	_                    [3]byte
+	NextHop              rawSockaddrInet
+	SitePrefixLength     uint8
+	_                    [3]byte
+	ValidLifetime        uint32
+	PreferredLifetime    uint32
+	Metric               uint32
+	Protocol             uint32
+	Loopback             uint8
+	AutoconfigureAddress uint8
+	Publish              uint8
+	Immortal             uint8
+	Age                  uint32
+	Origin               uint32
+}
+
+// mibIPForwardTableRows is the offset of the rows within
+// MIB_IPFORWARD_TABLE2, following its entry count and aligned to NET_LUID.
+const mibIPForwardTableRows = 8
+
+// mibIPInterfaceRow mirrors MIB_IPINTERFACE_ROW, keeping only the fields
+// needed by this package.
+type mibIPInterfaceRow struct {
+	Family         uint16
+	_              [6]byte
+	InterfaceLuid  uint64
+	InterfaceIndex uint32
+	_              [128]byte // MaxReassemblySize through SitePrefixLength
+	Metric         uint32
+	_              [16]byte // NlMtu through DisableDefaultRoutes
+}
+
+// Values of NL_ROUTE_PROTOCOL.
+const (
+	routeProtocolLocal   = 2
+	routeProtocolNetMgmt = 3
+	routeProtocolICMP    = 4
+)
+
+// routeOrigins names values of NL_ROUTE_ORIGIN.
+var routeOrigins = map[uint32]string{
+	0: "manual",
+	1: "wellknown",
+	2: "dhcp",
+	3: "ra",
+	4: "6to4",
+}
+
+// ipForwardRows returns a copy of the rows of the system's routing table.
+func ipForwardRows() ([]mibIPForwardRow2, error) {
+	var table unsafe.Pointer
+	r, _, _ := procGetIPForwardTable2.Call(syscall.AF_UNSPEC, uintptr(unsafe.Pointer(&table)))
+	if r != 0 {
+		return nil, fmt.Errorf("%s: %w", ipForwardSource, syscall.Errno(r))
+	}
+	defer procFreeMibTable.Call(uintptr(table))
+
+	n := *(*uint32)(table)
+	rows := unsafe.Slice((*mibIPForwardRow2)(unsafe.Add(table, mibIPForwardTableRows)), n)
+	return append([]mibIPForwardRow2(nil), rows...), nil
+}
+
+// interfaceMetric returns the metric of an interface for a given family, which
+// Windows adds to route metrics to obtain the metric routes are compared by.
+func interfaceMetric(family uint16, index uint32) (uint32, bool) {
+	row := mibIPInterfaceRow{Family: family, InterfaceIndex: index}
+	r, _, _ := procGetIPInterfaceEntry.Call(uintptr(unsafe.Pointer(&row)))
+	if r != 0 {
+		return 0, false
+	}
+	return row.Metric, true
+}
+
+// osVersion returns the Windows version, such as "10.0.19045".
+func osVersion() string {
+	v, err := syscall.GetVersion()
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%d.%d.%d", byte(v), byte(v>>8), v>>16)
+}
+
+func platformRequirements() []Requirement {
+	return commonRequirements
+}
+
+func platformBackends() []*backend {
+	return []*backend{
+		{name: "iphlpapi", probe: probeIPHlpAPI, routes: ipHlpAPIRoutes, rawRoutes: ipHlpAPIRawRoutes},
+	}
+}
+
+// probeIPHlpAPI checks whether the IP Helper API functions used are
+// available.
+func probeIPHlpAPI() error {
+	for _, proc := range []*syscall.LazyProc{procGetIPForwardTable2, procGetIPInterfaceEntry, procFreeMibTable} {
+		if err := proc.Find(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func ipHlpAPIRoutes(ctx context.Context) (NetRouteList, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err := ipForwardRows()
+	if err != nil {
+		return nil, err
+	}
+
+	names, err := interfaceNames()
+	if err != nil {
+		return nil, err
+	}
+
+	log := parseLogFrom(ctx)
+	metrics := map[[2]uint32]uint32{}
+	var routes NetRouteList
+	for i := range rows {
+		route, ok := routeFromRow(&rows[i], names, metrics)
+		if !ok {
+			log.skip(ipForwardSource, i+1, fmt.Sprintf("%x", rows[i].InterfaceLuid), "unknown address family, or interface not found")
+			continue
+		}
+		log.printf(ipForwardSource, "accepted row %d: %s", i+1, route)
+		routes = append(routes, route)
+	}
+	return routes, nil
+}
+
+func ipHlpAPIRawRoutes(ctx context.Context) ([]RawRouteMessage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err := ipForwardRows()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]RawRouteMessage, 0, len(rows))
+	for i := range rows {
+		data := unsafe.Slice((*byte)(unsafe.Pointer(&rows[i])), unsafe.Sizeof(rows[i]))
+		result = append(result, RawRouteMessage{Source: ipForwardSource, Data: append([]byte(nil), data...)})
+	}
+	return result, nil
+}
+
+// interfaceNames maps interface indexes to names. Interfaces are listed once
+// per table read, as looking each up through net.InterfaceByIndex lists every
+// adapter anew.
+func interfaceNames() (map[uint32]string, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[uint32]string, len(ifaces))
+	for _, v := range ifaces {
+		names[uint32(v.Index)] = v.Name
+	}
+	return names, nil
+}
+
+// routeFromRow converts row into a NetRoute, naming its interface through
+// names, as returned by interfaceNames. metrics caches interface metrics by
+// family and interface index.
+func routeFromRow(row *mibIPForwardRow2, names map[uint32]string, metrics map[[2]uint32]uint32) (NetRoute, bool) {
+	var kind NetRouteKind
+	switch row.DestinationPrefix.Family {
+	case syscall.AF_INET:
+		kind = NetRouteKindV4
+	case syscall.AF_INET6:
+		kind = NetRouteKindV6
+	default:
+		return NetRoute{}, false
+	}
+
+	name, ok := names[row.InterfaceIndex]
+	if !ok {
+		return NetRoute{}, false
+	}
+
+	dst := row.DestinationPrefix.addr()
+	gateway := row.NextHop.addr()
+	flags := RouteFlagUp
+	if gateway.IsValid() && !gateway.IsUnspecified() {
+		flags |= RouteFlagGateway
+	}
+	if int(row.DestinationPrefixLen) == dst.BitLen() {
+		flags |= RouteFlagHost
+	}
+	switch row.Protocol {
+	case routeProtocolLocal:
+		flags |= RouteFlagLocal
+	case routeProtocolNetMgmt:
+		flags |= RouteFlagStatic
+	case routeProtocolICMP:
+		flags |= RouteFlagDynamic
+	}
+
+	attrs := map[string]string{
+		"DestinationPrefix": strconv.Itoa(int(row.DestinationPrefixLen)),
+		"RouteMetric":       strconv.FormatUint(uint64(row.Metric), 10),
+		"Protocol":          strconv.FormatUint(uint64(row.Protocol), 10),
+		"Age":               strconv.FormatUint(uint64(row.Age), 10),
+	}
+	if origin, ok := routeOrigins[row.Origin]; ok {
+		attrs["Origin"] = origin
+	}
+
+	key := [2]uint32{uint32(row.DestinationPrefix.Family), row.InterfaceIndex}
+	metric, ok := metrics[key]
+	if !ok {
+		if metric, ok = interfaceMetric(row.DestinationPrefix.Family, row.InterfaceIndex); ok {
+			metrics[key] = metric
+		}
+	}
+	if ok {
+		// As shown by `route print', routes are compared by the sum of their
+		// own metric and their interface's.
+		attrs["InterfaceMetric"] = strconv.FormatUint(uint64(metric), 10)
+		attrs["Metric"] = strconv.FormatUint(uint64(row.Metric)+uint64(metric), 10)
+	}
+
+	return NetRoute{
+		Kind:        kind,
+		Destination: withLinkZone(dst, name),
+		Flags:       flags.String(),
+		FlagBits:    flags,
+		Netif:       name,
+		Gateway:     withLinkZone(gateway, name),
+		Attrs:       attrs,
+	}, true
+}
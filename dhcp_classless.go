@@ -0,0 +1,185 @@
+package defip
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// dhcpLeaseGlobs lists locations of dhclient lease files, in which classless
+// static routes received through DHCP options 121 (RFC 3442) and 249
+// (Microsoft's pre-standard variant) are recorded.
+var dhcpLeaseGlobs = []string{
+	"/var/lib/dhcp/dhclient*.leases",
+	"/var/lib/dhclient/*.lease",
+	"/var/lib/dhclient/*.leases",
+	"/var/lib/NetworkManager/dhclient-*.lease",
+}
+
+// dhcpClasslessOptions maps dhclient option names to their DHCP option codes.
+var dhcpClasslessOptions = map[string]string{
+	"rfc3442-classless-static-routes": "121",
+	"ms-classless-static-routes":      "249",
+}
+
+// AttrDHCPOption is the NetRoute.Attrs key set on routes matching a classless
+// static route received through DHCP. Its value is the option code that
+// delivered the route ("121" or "249"). Such routes frequently override the
+// gateway users expect, explaining surprising selections.
+const AttrDHCPOption = "DHCPOption"
+
+type classlessRoute struct {
+	prefix  netip.Prefix
+	gateway netip.Addr
+}
+
+// parseClasslessRoutes decodes the dhclient representation of a classless
+// static routes option, a comma-separated list of octets in which each route
+// is encoded as its prefix length, the significant octets of its destination,
+// and the four octets of its gateway:
+//
+//	24,10,0,0,192,168,1,1,0,192,168,1,1
+func parseClasslessRoutes(value string) ([]classlessRoute, bool) {
+	var octets []byte
+	for _, v := range strings.Split(value, ",") {
+		o, err := strconv.ParseUint(strings.TrimSpace(v), 10, 8)
+		if err != nil {
+			return nil, false
+		}
+		octets = append(octets, byte(o))
+	}
+
+	var routes []classlessRoute
+	for len(octets) > 0 {
+		width := int(octets[0])
+		if width > 32 {
+			return nil, false
+		}
+		significant := (width + 7) / 8
+		if len(octets) < 1+significant+4 {
+			return nil, false
+		}
+
+		var dst [4]byte
+		copy(dst[:], octets[1:1+significant])
+		gw := [4]byte(octets[1+significant : 1+significant+4])
+		routes = append(routes, classlessRoute{
+			prefix:  netip.PrefixFrom(netip.AddrFrom4(dst), width),
+			gateway: netip.AddrFrom4(gw),
+		})
+		octets = octets[1+significant+4:]
+	}
+
+	return routes, true
+}
+
+// dhcpLeases caches the classless static routes read from each lease file,
+// along with the modification time and size of the file they were read
+// from, as lease files only change on renewals, while routes are marked
+// whenever the routing table is read.
+var dhcpLeases struct {
+	sync.Mutex
+	byPath map[string]dhcpLease
+}
+
+type dhcpLease struct {
+	modTime time.Time
+	size    int64
+	routes  map[classlessRoute]string
+}
+
+// readClasslessRoutes collects classless static routes recorded in lease
+// files, keyed by the DHCP option code that delivered them. Lease files
+// accumulate past leases; all of them are considered. Files are only read
+// again once modified.
+func readClasslessRoutes() map[classlessRoute]string {
+	dhcpLeases.Lock()
+	defer dhcpLeases.Unlock()
+
+	seen := map[string]dhcpLease{}
+	result := map[classlessRoute]string{}
+	for _, pattern := range dhcpLeaseGlobs {
+		paths, _ := filepath.Glob(pattern)
+		for _, path := range paths {
+			info, err := os.Stat(path)
+			if err != nil {
+				continue
+			}
+			lease, ok := dhcpLeases.byPath[path]
+			if !ok || !lease.modTime.Equal(info.ModTime()) || lease.size != info.Size() {
+				data, err := os.ReadFile(path)
+				if err != nil {
+					continue
+				}
+				lease = dhcpLease{modTime: info.ModTime(), size: info.Size(), routes: parseLeaseFile(string(data))}
+			}
+			seen[path] = lease
+			for r, code := range lease.routes {
+				result[r] = code
+			}
+		}
+	}
+
+	// Leases of removed files are forgotten.
+	dhcpLeases.byPath = seen
+	return result
+}
+
+// parseLeaseFile collects the classless static routes recorded in a lease
+// file, keyed by the DHCP option code that delivered them.
+func parseLeaseFile(data string) map[classlessRoute]string {
+	result := map[classlessRoute]string{}
+	for _, line := range strings.Split(data, "\n") {
+		fields := strings.Fields(strings.TrimSuffix(strings.TrimSpace(line), ";"))
+		if len(fields) != 3 || fields[0] != "option" {
+			continue
+		}
+		code, ok := dhcpClasslessOptions[fields[1]]
+		if !ok {
+			continue
+		}
+		routes, ok := parseClasslessRoutes(fields[2])
+		if !ok {
+			continue
+		}
+		for _, r := range routes {
+			result[r] = code
+		}
+	}
+	return result
+}
+
+// markClasslessRoutes sets AttrDHCPOption on IPv4 routes matching classless
+// static routes received through DHCP.
+func markClasslessRoutes(routes NetRouteList) {
+	classless := readClasslessRoutes()
+	if len(classless) == 0 {
+		return
+	}
+
+	for i, v := range routes {
+		if v.Kind != NetRouteKindV4 {
+			continue
+		}
+		bits, ok := v.prefixLen()
+		if !ok {
+			continue
+		}
+		key := classlessRoute{
+			prefix:  netip.PrefixFrom(v.Destination, bits),
+			gateway: v.Gateway,
+		}
+		code, ok := classless[key]
+		if !ok {
+			continue
+		}
+		if routes[i].Attrs == nil {
+			routes[i].Attrs = map[string]string{}
+		}
+		routes[i].Attrs[AttrDHCPOption] = code
+	}
+}
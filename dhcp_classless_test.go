@@ -0,0 +1,51 @@
+package defip
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReadClasslessRoutesModified(t *testing.T) {
+	dir := t.TempDir()
+	saved := dhcpLeaseGlobs
+	dhcpLeaseGlobs = []string{filepath.Join(dir, "*.leases")}
+	t.Cleanup(func() { dhcpLeaseGlobs = saved })
+
+	path := filepath.Join(dir, "dhclient.eth0.leases")
+	write := func(option string, modTime time.Time) {
+		t.Helper()
+		lease := "lease {\n  interface \"eth0\";\n  option " + option + " 24,10,0,0,192,168,1,1;\n}\n"
+		if err := os.WriteFile(path, []byte(lease), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	route := classlessRoute{
+		prefix:  netip.MustParsePrefix("10.0.0.0/24"),
+		gateway: netip.MustParseAddr("192.168.1.1"),
+	}
+
+	at := time.Now().Add(-time.Hour)
+	write("rfc3442-classless-static-routes", at)
+	if got := readClasslessRoutes()[route]; got != "121" {
+		t.Fatalf("got option %q, want 121", got)
+	}
+
+	// Renewals rewrite the file, which is then read again.
+	write("ms-classless-static-routes", at.Add(time.Minute))
+	if got := readClasslessRoutes()[route]; got != "249" {
+		t.Errorf("got option %q after renewal, want 249", got)
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if got := readClasslessRoutes(); len(got) != 0 {
+		t.Errorf("got %v after removal, want no routes", got)
+	}
+}
@@ -0,0 +1,207 @@
+package defip
+
+import (
+	"context"
+	"runtime"
+	"time"
+)
+
+// DiagnosticsSchemaVersion is the version of the JSON representation of
+// Diagnostics, described by diagnostics.schema.json. It is increased whenever
+// a field is removed or changes meaning; fields may be added within a version.
+const DiagnosticsSchemaVersion = 1
+
+// Diagnostics gathers the routing table, the addresses selected from it, and
+// the environment they were obtained in, in a form meant to be encoded as
+// JSON for support tooling and automation.
+type Diagnostics struct {
+	SchemaVersion int                    `json:"schema_version"`
+	CollectedAt   time.Time              `json:"collected_at"`
+	Environment   DiagnosticsEnvironment `json:"environment"`
+	Routes        []DiagnosticsRoute     `json:"routes"`
+	Interfaces    []DiagnosticsInterface `json:"interfaces"`
+	Selections    []DiagnosticsSelection `json:"selections"`
+	Warnings      []ParseWarning         `json:"warnings"`
+
+	// Error is set when the routing table could not be obtained.
+	Error string `json:"error,omitempty"`
+}
+
+// DiagnosticsEnvironment describes the system diagnostics were collected on.
+type DiagnosticsEnvironment struct {
+	OS        string               `json:"os"`
+	Arch      string               `json:"arch"`
+	OSVersion string               `json:"os_version"`
+	Backends  []DiagnosticsBackend `json:"backends"`
+
+	// Jail describes the FreeBSD jail diagnostics were collected in, as
+	// JailShared or JailVNET, and is empty otherwise.
+	Jail string `json:"jail,omitempty"`
+}
+
+const (
+	// JailShared denotes a FreeBSD jail sharing the host's network stack,
+	// in which the routing table only lists routes through addresses
+	// assigned to the jail.
+	JailShared = "shared"
+
+	// JailVNET denotes a FreeBSD jail with its own network stack (VNET),
+	// and routing table.
+	JailVNET = "vnet"
+)
+
+// jailState describes the jail the process runs in, as JailShared or
+// JailVNET, or returns an empty string when not running in one. Replaced on
+// FreeBSD.
+var jailState = func() string {
+	return ""
+}
+
+// DiagnosticsBackend represents a BackendInfo.
+type DiagnosticsBackend struct {
+	Name      string `json:"name"`
+	Available bool   `json:"available"`
+	Error     string `json:"error,omitempty"`
+	LastUsed  bool   `json:"last_used"`
+}
+
+// DiagnosticsRoute represents a NetRoute. PrefixLength is omitted when
+// unknown, and Gateway for routes without a gateway address.
+type DiagnosticsRoute struct {
+	Kind            string            `json:"kind"`
+	Destination     string            `json:"destination"`
+	PrefixLength    *int              `json:"prefix_length,omitempty"`
+	Gateway         string            `json:"gateway,omitempty"`
+	GatewayHardware string            `json:"gateway_hardware,omitempty"`
+	Interface       string            `json:"interface"`
+	Flags           string            `json:"flags"`
+	Default         bool              `json:"default"`
+	Attrs           map[string]string `json:"attrs,omitempty"`
+}
+
+// DiagnosticsInterface represents the InterfaceInfo of an interface routes
+// go through, including the driver behind it.
+type DiagnosticsInterface struct {
+	Name              string `json:"name"`
+	Type              string `json:"type,omitempty"`
+	Parent            string `json:"parent,omitempty"`
+	Class             string `json:"class"`
+	SRIOV             string `json:"sriov"`
+	Driver            string `json:"driver,omitempty"`
+	DriverVersion     string `json:"driver_version,omitempty"`
+	FirmwareVersion   string `json:"firmware_version,omitempty"`
+	BusInfo           string `json:"bus_info,omitempty"`
+	DriverDescription string `json:"driver_description,omitempty"`
+}
+
+func diagnosticsInterfaceOf(info InterfaceInfo) DiagnosticsInterface {
+	return DiagnosticsInterface{
+		Name:              info.Name,
+		Type:              info.Type,
+		Parent:            info.Parent,
+		Class:             info.Class.String(),
+		SRIOV:             info.SRIOV.String(),
+		Driver:            info.Driver.Name,
+		DriverVersion:     info.Driver.Version,
+		FirmwareVersion:   info.Driver.Firmware,
+		BusInfo:           info.Driver.BusInfo,
+		DriverDescription: info.Driver.Description,
+	}
+}
+
+// DiagnosticsSelection represents the outcome of selecting an address of a
+// given kind: either a Selection, or the error selection failed with.
+type DiagnosticsSelection struct {
+	Kind       string            `json:"kind"`
+	Addr       string            `json:"addr,omitempty"`
+	Interface  string            `json:"interface,omitempty"`
+	Route      *DiagnosticsRoute `json:"route,omitempty"`
+	Reason     string            `json:"reason,omitempty"`
+	MTU        int               `json:"mtu,omitempty"`
+	Unnumbered bool              `json:"unnumbered,omitempty"`
+	Error      string            `json:"error,omitempty"`
+}
+
+func diagnosticsRouteOf(r NetRoute) DiagnosticsRoute {
+	d := DiagnosticsRoute{
+		Kind:        r.Kind.String(),
+		Destination: r.Destination.String(),
+		Interface:   r.Netif,
+		Flags:       r.FlagBits.String(),
+		Default:     r.IsDefault(),
+		Attrs:       r.Attrs,
+	}
+	if p, ok := r.Prefix(); ok {
+		bits := p.Bits()
+		d.PrefixLength = &bits
+	}
+	if r.Gateway.IsValid() {
+		d.Gateway = r.Gateway.String()
+	}
+	if r.GatewayHardware != nil {
+		d.GatewayHardware = r.GatewayHardware.String()
+	}
+	return d
+}
+
+// Diagnostics collects the routing table once, and selects an IPv4 and an
+// IPv6 address from it. Failures are recorded in the returned Diagnostics
+// rather than returned, so it always describes as much as could be obtained.
+func (r *Resolver) Diagnostics(ctx context.Context) *Diagnostics {
+	d := &Diagnostics{
+		SchemaVersion: DiagnosticsSchemaVersion,
+		Environment: DiagnosticsEnvironment{
+			OS:        runtime.GOOS,
+			Arch:      runtime.GOARCH,
+			OSVersion: osVersion(),
+			Jail:      jailState(),
+			Backends:  []DiagnosticsBackend{},
+		},
+		Routes:     []DiagnosticsRoute{},
+		Interfaces: []DiagnosticsInterface{},
+		Selections: []DiagnosticsSelection{},
+		Warnings:   []ParseWarning{},
+	}
+
+	snapshot, err := r.Snapshot(ctx)
+	d.CollectedAt = snapshot.CollectedAt
+	for _, b := range Backends() {
+		backend := DiagnosticsBackend{Name: b.Name, Available: b.Available, LastUsed: b.LastUsed}
+		if b.Err != nil {
+			backend.Error = b.Err.Error()
+		}
+		d.Environment.Backends = append(d.Environment.Backends, backend)
+	}
+	if err != nil {
+		d.CollectedAt = time.Now()
+		d.Error = err.Error()
+		return d
+	}
+
+	seen := map[string]bool{}
+	for _, v := range snapshot.Routes {
+		d.Routes = append(d.Routes, diagnosticsRouteOf(v))
+		if v.Netif != "" && !seen[v.Netif] {
+			seen[v.Netif] = true
+			d.Interfaces = append(d.Interfaces, diagnosticsInterfaceOf(LookupInterfaceInfo(v.Netif)))
+		}
+	}
+	d.Warnings = append(d.Warnings, snapshot.Warnings()...)
+	for _, kind := range []NetRouteKind{NetRouteKindV4, NetRouteKindV6} {
+		sel := DiagnosticsSelection{Kind: kind.String()}
+		s, err := r.selectFrom(kind, snapshot.Routes, snapshot.CollectedAt, nil)
+		if err != nil {
+			sel.Error = err.Error()
+		} else {
+			route := diagnosticsRouteOf(s.Route)
+			sel.Addr = s.Addr.String()
+			sel.Interface = s.Interface.Name
+			sel.Route = &route
+			sel.Reason = s.Reason
+			sel.MTU = s.MTU
+			sel.Unnumbered = s.Unnumbered
+		}
+		d.Selections = append(d.Selections, sel)
+	}
+	return d
+}
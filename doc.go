@@ -0,0 +1,22 @@
+// Package defip detects default gateways and the local IP addresses most
+// likely connected to the wider network.
+//
+// The primary API is the Resolver, obtained through NewResolver, whose
+// methods take a context and return addresses by value:
+//
+//	r := defip.NewResolver()
+//	ip, err := r.FindDefaultIP(ctx, defip.NetRouteKindV4)
+//
+// Package-level functions such as FindDefaultIP and FindRoutes are kept as
+// thin wrappers around a default Resolver for code migrating from v1, and are
+// deprecated.
+//
+// Hosts need not be dual-stack: on IPv4-only or IPv6-only hosts, selecting
+// an address of the missing family fails right away with an error matching
+// ErrNoIP. Resolver.SelectDual selects an address of each family from a
+// single routing table snapshot.
+//
+// Importing the package does not probe the system: the platform's routing
+// table backend is set up on first use. Available may be used to check
+// whether it can be used beforehand.
+package defip
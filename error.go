@@ -1,37 +1,179 @@
 package defip
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
+	"os/exec"
 	"runtime"
+	"strings"
 )
 
 // ErrCantParse is returned if the route table is garbage.
-type ErrCantParse struct{}
+type ErrCantParse struct {
+	// Source identifies the routing table source, such as
+	// "/proc/net/route" or "netstat -rn". Empty when unknown.
+	Source string
+
+	// Line is the 1-based number of the line parsing stopped at.
+	// Zero when unknown.
+	Line int
+}
 
 // ErrNotImplemented is returned if your operating system
-// is not supported by this package. Please raise an issue
+// is not supported by this package, and no backend was
+// added through RegisterBackend. Please raise an issue
 // to request support.
 type ErrNotImplemented struct{}
 
-// ErrInvalidRouteFileFormat is returned if the format
-// of /proc/net/route is unexpected on Linux systems.
-// Please raise an issue.
+// ErrInvalidRouteFileFormat describes a routing table row
+// that could not be parsed. It wraps an ErrCantParse locating
+// the row, so both may be checked for through errors.As.
+//
+// Deprecated: Rows that cannot be parsed are skipped
+// instead of failing the whole parse, and reported through
+// RouteSnapshot.Warnings.
 type ErrInvalidRouteFileFormat struct {
 	row string
+
+	// Err locates the row within the routing table.
+	Err *ErrCantParse
+}
+
+// ErrSandboxed is returned when every strategy available to obtain the
+// routing table was denied or found missing, as typical of sandboxes such as
+// Flatpak or hardened systemd units, which may hide /proc or forbid executing
+// other programs.
+type ErrSandboxed struct {
+	// Causes lists the error each strategy failed with.
+	Causes []error
+}
+
+// ErrRawSocketDenied is returned by Resolver.RouterDNS when the raw ICMPv6
+// socket Router Solicitations are sent through cannot be opened or written
+// to, as the process lacks the privileges needed (root, or CAP_NET_RAW on
+// Linux), or runs within a sandbox forbidding it. Routers advertising no DNS
+// configuration are reported through an empty RouterDNS instead.
+type ErrRawSocketDenied struct {
+	// Err holds the underlying error.
+	Err error
+}
+
+// BackendExecError is returned when a program the routing table is obtained
+// from, such as netstat, exits with a non-zero status. Its output is not
+// parsed, as it may be partial.
+type BackendExecError struct {
+	// Cmd is the command line that was run, such as "netstat -rn".
+	Cmd string
+
+	// ExitCode is the status the program exited with, or -1 when it was
+	// terminated by a signal.
+	ExitCode int
+
+	// Stderr holds what the program wrote to its standard error, with
+	// surrounding whitespace removed.
+	Stderr string
+
+	// Err holds the underlying error, usually an *exec.ExitError.
+	Err error
 }
 
-func (*ErrCantParse) Error() string {
-	return "can't parse route table"
+// ErrOutputTooLarge is returned when a program the routing table is obtained
+// from, such as netstat, prints more output than allowed through
+// WithMaxBackendOutput, in which case it is terminated and its output
+// discarded.
+type ErrOutputTooLarge struct {
+	// Cmd is the command line that was run, such as "netstat -rn".
+	Cmd string
+
+	// Limit is the maximum amount of output allowed, in bytes.
+	Limit int64
+}
+
+func (e *ErrCantParse) Error() string {
+	switch {
+	case e.Source == "" && e.Line == 0:
+		return "can't parse route table"
+	case e.Source == "":
+		return fmt.Sprintf("can't parse route table at line %d", e.Line)
+	case e.Line == 0:
+		return "can't parse route table from " + e.Source
+	}
+	return fmt.Sprintf("can't parse route table from %s at line %d", e.Source, e.Line)
 }
 
 func (*ErrNotImplemented) Error() string {
 	return "not implemented for OS: " + runtime.GOOS
 }
 
+func (e *ErrSandboxed) Error() string {
+	causes := make([]string, len(e.Causes))
+	for i, v := range e.Causes {
+		causes[i] = v.Error()
+	}
+	return "routing table is not accessible, possibly due to a sandbox (" +
+		strings.Join(causes, "; ") +
+		"); grant read access to the files or permission to execute the programs listed"
+}
+
+func (e *ErrRawSocketDenied) Error() string {
+	return "could not open raw ICMPv6 socket, which requires root or CAP_NET_RAW: " + e.Err.Error()
+}
+
+func (e *ErrRawSocketDenied) Unwrap() error {
+	return e.Err
+}
+
+func (e *BackendExecError) Error() string {
+	msg := fmt.Sprintf("`%s' exited with status %d", e.Cmd, e.ExitCode)
+	if e.Stderr != "" {
+		msg += ": " + e.Stderr
+	}
+	return msg
+}
+
+func (e *BackendExecError) Unwrap() error {
+	return e.Err
+}
+
+func (e *ErrOutputTooLarge) Error() string {
+	return fmt.Sprintf("`%s' printed more than %d bytes", e.Cmd, e.Limit)
+}
+
+func (e *ErrSandboxed) Unwrap() []error {
+	return e.Causes
+}
+
+// isSandboxErr returns whether err denotes a resource that is missing or
+// denied, as a sandbox would cause.
+func isSandboxErr(err error) bool {
+	return errors.Is(err, fs.ErrPermission) ||
+		errors.Is(err, fs.ErrNotExist) ||
+		errors.Is(err, exec.ErrNotFound)
+}
+
 func (e *ErrInvalidRouteFileFormat) Error() string {
-	return fmt.Sprintf("invalid row %q in route file", e.row)
+	if e.Err == nil {
+		return fmt.Sprintf("invalid row %q in route file", e.row)
+	}
+	return fmt.Sprintf("invalid row %q in route file: %s", e.row, e.Err)
+}
+
+func (e *ErrInvalidRouteFileFormat) Unwrap() error {
+	if e.Err == nil {
+		return nil
+	}
+	return e.Err
 }
 
 // ErrNoIP indicates that the library could not obtain an IP matching the
 // provided kind.
 var ErrNoIP = fmt.Errorf("could not find IP matching provided kind")
+
+// ErrNoNodeIP indicates that the library could not obtain the node IP
+// advertised by the kubelet matching the provided kind.
+var ErrNoNodeIP = fmt.Errorf("could not find kubernetes node IP matching provided kind")
+
+// ErrNoDefaultRoute indicates that no default route matching the provided kind
+// could be found.
+var ErrNoDefaultRoute = fmt.Errorf("could not find default route matching provided kind")
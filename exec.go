@@ -0,0 +1,67 @@
+package defip
+
+import (
+	"bytes"
+	"context"
+	"os/exec"
+	"strings"
+)
+
+// runBackendCommand runs name with args on behalf of the backend identified
+// by source, returning what it printed to its standard output and standard
+// error, which are kept apart. Should it exit with a non-zero status, a
+// BackendExecError including its standard error is returned, unless ctx was
+// done, in which case ctx.Err() is.
+//
+// Output past the limit carried by ctx is not buffered, and makes
+// runBackendCommand return ErrOutputTooLarge; the program is then terminated
+// once writing to the closed pipe.
+func runBackendCommand(ctx context.Context, source, name string, args ...string) (string, string, error) {
+	limit := outputLimitFrom(ctx)
+	stdout := &limitedBuffer{source: source, limit: limit}
+	stderr := &limitedBuffer{source: source, limit: limit}
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	err := cmd.Run()
+	if stdout.exceeded || stderr.exceeded {
+		return "", "", &ErrOutputTooLarge{Cmd: source, Limit: limit}
+	}
+	if err != nil && ctx.Err() != nil {
+		// The program was killed as ctx was cancelled or expired, which
+		// must not be mistaken for a failure of the program itself.
+		return "", "", ctx.Err()
+	}
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return "", "", &BackendExecError{
+			Cmd:      source,
+			ExitCode: exitErr.ExitCode(),
+			Stderr:   strings.TrimSpace(stderr.String()),
+			Err:      exitErr,
+		}
+	}
+	if err != nil {
+		if isSandboxErr(err) {
+			return "", "", &ErrSandboxed{Causes: []error{err}}
+		}
+		return "", "", err
+	}
+	return stdout.String(), stderr.String(), nil
+}
+
+// limitedBuffer is a bytes.Buffer refusing writes past limit, unless limit
+// is zero or negative.
+type limitedBuffer struct {
+	bytes.Buffer
+	source   string
+	limit    int64
+	exceeded bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.limit > 0 && int64(b.Len()+len(p)) > b.limit {
+		b.exceeded = true
+		return 0, &ErrOutputTooLarge{Cmd: b.source, Limit: b.limit}
+	}
+	return b.Buffer.Write(p)
+}
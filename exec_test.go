@@ -0,0 +1,42 @@
+package defip
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestRunBackendCommandExitStatus(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+	_, _, err := runBackendCommand(context.Background(), "sh", "sh", "-c", "echo failed >&2; exit 3")
+	var execErr *BackendExecError
+	if !errors.As(err, &execErr) {
+		t.Fatalf("got %v, want a BackendExecError", err)
+	}
+	if execErr.ExitCode != 3 || execErr.Stderr != "failed" {
+		t.Errorf("got status %d and stderr %q, want 3 and %q", execErr.ExitCode, execErr.Stderr, "failed")
+	}
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		t.Errorf("%v does not unwrap to an *exec.ExitError", err)
+	}
+}
+
+func TestRunBackendCommandDeadline(t *testing.T) {
+	if _, err := exec.LookPath("sleep"); err != nil {
+		t.Skip("sleep not available")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, _, err := runBackendCommand(ctx, "sleep", "sleep", "5")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want context.DeadlineExceeded", err)
+	}
+	if fallsThrough(err) {
+		t.Errorf("%v falls through to the next backend", err)
+	}
+}
@@ -1,5 +1,7 @@
 package defip
 
+import "slices"
+
 type fieldSet []string
 
 func (f fieldSet) fieldIdx(name string) int {
@@ -11,3 +13,17 @@ func (f fieldSet) fieldIdx(name string) int {
 
 	return -1
 }
+
+// attrs returns a map of field names to their respective values, skipping
+// fields listed in skip and those without a value.
+func (f fieldSet) attrs(values []string, skip ...string) map[string]string {
+	result := map[string]string{}
+	for i, field := range f {
+		if i >= len(values) || slices.Contains(skip, field) {
+			continue
+		}
+		result[field] = values[i]
+	}
+
+	return result
+}
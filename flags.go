@@ -0,0 +1,169 @@
+package defip
+
+import "strings"
+
+// RouteFlag is a platform-independent bitmask of route flags. Backends
+// translate their native flags into RouteFlag values through per-platform
+// tables, so the same query works regardless of how each system spells its
+// flags.
+type RouteFlag uint32
+
+const (
+	// RouteFlagUp indicates the route is usable.
+	RouteFlagUp RouteFlag = 1 << iota
+
+	// RouteFlagGateway indicates the destination is reached through a
+	// gateway.
+	RouteFlagGateway
+
+	// RouteFlagHost indicates a host-specific route.
+	RouteFlagHost
+
+	// RouteFlagReject indicates the route rejects matching traffic.
+	RouteFlagReject
+
+	// RouteFlagDynamic indicates the route was created dynamically, typically
+	// by a redirect.
+	RouteFlagDynamic
+
+	// RouteFlagModified indicates the route was modified dynamically,
+	// typically by a redirect.
+	RouteFlagModified
+
+	// RouteFlagStatic indicates the route was added manually.
+	RouteFlagStatic
+
+	// RouteFlagBlackhole indicates matching traffic is silently discarded.
+	RouteFlagBlackhole
+
+	// RouteFlagBroadcast indicates a route to a broadcast address.
+	RouteFlagBroadcast
+
+	// RouteFlagMulticast indicates a route to a multicast address.
+	RouteFlagMulticast
+
+	// RouteFlagCloning indicates a route that generates new routes on use.
+	RouteFlagCloning
+
+	// RouteFlagLocal indicates a route to a local address.
+	RouteFlagLocal
+
+	// RouteFlagDefault indicates a default route learned through Neighbor
+	// Discovery.
+	RouteFlagDefault
+
+	// RouteFlagAddrConf indicates a route configured through Router
+	// Advertisements.
+	RouteFlagAddrConf
+
+	// RouteFlagCache indicates a cached route.
+	RouteFlagCache
+
+	// RouteFlagExpires indicates a route with limited lifetime.
+	RouteFlagExpires
+)
+
+// routeFlagLetters lists the letter used to represent each RouteFlag, in the
+// order they are presented by String.
+var routeFlagLetters = []struct {
+	flag   RouteFlag
+	letter rune
+}{
+	{RouteFlagUp, 'U'},
+	{RouteFlagGateway, 'G'},
+	{RouteFlagHost, 'H'},
+	{RouteFlagReject, 'R'},
+	{RouteFlagDynamic, 'D'},
+	{RouteFlagModified, 'M'},
+	{RouteFlagStatic, 'S'},
+	{RouteFlagBlackhole, 'B'},
+	{RouteFlagBroadcast, 'b'},
+	{RouteFlagMulticast, 'm'},
+	{RouteFlagCloning, 'C'},
+	{RouteFlagLocal, 'l'},
+	{RouteFlagDefault, 'd'},
+	{RouteFlagAddrConf, 'a'},
+	{RouteFlagCache, 'c'},
+	{RouteFlagExpires, 'e'},
+}
+
+func (f RouteFlag) String() string {
+	var sb strings.Builder
+	for _, v := range routeFlagLetters {
+		if f&v.flag == v.flag {
+			sb.WriteRune(v.letter)
+		}
+	}
+	return sb.String()
+}
+
+// bsdFlagTable translates netstat flag letters used by BSD-derived systems.
+// Profiles using other alphabets translate into it through
+// NetstatProfile.FlagAlphabet.
+var bsdFlagTable = map[rune]RouteFlag{
+	'U': RouteFlagUp,
+	'G': RouteFlagGateway,
+	'H': RouteFlagHost,
+	'R': RouteFlagReject,
+	'D': RouteFlagDynamic,
+	'M': RouteFlagModified,
+	'S': RouteFlagStatic,
+	'B': RouteFlagBlackhole,
+	'b': RouteFlagBroadcast,
+	'm': RouteFlagMulticast,
+	'C': RouteFlagCloning,
+}
+
+// linuxFlagTable translates flags reported by the Linux kernel.
+var linuxFlagTable = map[routeTableFlag]RouteFlag{
+	rtfUp:       RouteFlagUp,
+	rtfGateway:  RouteFlagGateway,
+	rtfHost:     RouteFlagHost,
+	rtfReject:   RouteFlagReject,
+	rtfDynamic:  RouteFlagDynamic,
+	rtfModified: RouteFlagModified,
+	rtfLocal:    RouteFlagLocal,
+	rtfDefault:  RouteFlagDefault,
+	rtfAddrConf: RouteFlagAddrConf,
+	rtfCache:    RouteFlagCache,
+	rtfExpires:  RouteFlagExpires,
+}
+
+func bsdRouteFlags(flags string) RouteFlag {
+	var result RouteFlag
+	for _, r := range flags {
+		result |= bsdFlagTable[r]
+	}
+	return result
+}
+
+func linuxRouteFlags(flags routeTableFlag) RouteFlag {
+	var result RouteFlag
+	for native, flag := range linuxFlagTable {
+		if flags.Is(native) {
+			result |= flag
+		}
+	}
+	return result
+}
+
+// routeFlags returns the route's flags as a bitmask. Routes not created by a
+// backend, and therefore without FlagBits set, have their flags derived from
+// Flags using the BSD alphabet.
+func (n NetRoute) routeFlags() RouteFlag {
+	if n.FlagBits != 0 {
+		return n.FlagBits
+	}
+	return bsdRouteFlags(n.Flags)
+}
+
+// HasAllFlags returns whether all flags set in flags are set on the route.
+func (n NetRoute) HasAllFlags(flags RouteFlag) bool {
+	return n.routeFlags()&flags == flags
+}
+
+// HasAnyFlags returns whether at least one flag set in flags is set on the
+// route.
+func (n NetRoute) HasAnyFlags(flags RouteFlag) bool {
+	return n.routeFlags()&flags != 0
+}
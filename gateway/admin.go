@@ -0,0 +1,86 @@
+// Package gateway provides helpers concerning the default gateway found by a
+// defip.Resolver, kept apart so programs only detecting addresses do not
+// depend on them.
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/heyvito/defip/v2"
+)
+
+// adminProbeTimeout bounds how long AdminURL waits for the gateway to
+// accept connections when the provided context has no deadline.
+var adminProbeTimeout = 2 * time.Second
+
+// adminPorts lists ports commonly serving router administration pages, in
+// order of preference.
+var adminPorts = []struct {
+	scheme string
+	port   uint16
+}{
+	{"https", 443},
+	{"http", 80},
+	{"https", 8443},
+	{"http", 8080},
+}
+
+// AdminURL returns a best-guess URL for the administration page of the
+// default gateway of a given kind, as found by r, determined by probing common
+// HTTP(S) ports on the gateway with TCP connections. No HTTP requests are
+// made, so the returned URL may not actually serve an administration page.
+// When ctx has no deadline, waits at most two seconds. The gateway is found through
+// Resolver.DefaultGateway, returning defip.ErrNoDefaultRoute when no default
+// route through a gateway exists.
+func AdminURL(ctx context.Context, r *defip.Resolver, kind defip.NetRouteKind) (*url.URL, error) {
+	route, err := r.DefaultGateway(ctx, kind)
+	if err != nil {
+		return nil, err
+	}
+
+	gateway := route.Gateway
+	if gateway.Is6() && gateway.IsLinkLocalUnicast() {
+		gateway = gateway.WithZone(route.Netif)
+	}
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, adminProbeTimeout)
+		defer cancel()
+	}
+
+	open := make([]chan bool, len(adminPorts))
+	for i, p := range adminPorts {
+		open[i] = make(chan bool, 1)
+		go func(result chan<- bool, port uint16) {
+			var d net.Dialer
+			conn, err := d.DialContext(ctx, "tcp", netip.AddrPortFrom(gateway, port).String())
+			if err == nil {
+				conn.Close()
+			}
+			result <- err == nil
+		}(open[i], p.port)
+	}
+
+	// Wait for each port in order of preference, so a less preferred port
+	// answering first does not win.
+	for i, p := range adminPorts {
+		if !<-open[i] {
+			continue
+		}
+		host := netip.AddrPortFrom(gateway, p.port).String()
+		if p.port == 443 || p.port == 80 {
+			// Omit ports implied by the scheme.
+			host = host[:strings.LastIndexByte(host, ':')]
+		}
+		return &url.URL{Scheme: p.scheme, Host: host}, nil
+	}
+
+	return nil, fmt.Errorf("gateway `%s' does not accept connections on common administration ports", gateway)
+}
@@ -0,0 +1,17 @@
+package defip
+
+import (
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// osVersion returns the AIX level, such as "7.3.0.0". As AIX offers no
+// sysctl, it is obtained from oslevel once.
+var osVersion = sync.OnceValue(func() string {
+	v, err := exec.Command("oslevel").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(v))
+})
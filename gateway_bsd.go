@@ -0,0 +1,121 @@
+//go:build aix || darwin || dragonfly || freebsd || netbsd || openbsd || solaris
+
+package defip
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// netstatFIB returns the forwarding table netstat is to list routes of,
+// given the one requested through ctx, if any, and whether the platform
+// supports multiple forwarding tables. Replaced on FreeBSD.
+var netstatFIB = func(ctx context.Context) (int, bool) {
+	return 0, false
+}
+
+// runNetstat runs netstat, returning the lines it printed to its standard
+// output. Its standard error is kept apart, as warnings interleaved into the
+// table would derail the parser, and recorded as warnings instead. Failures
+// within a FreeBSD jail are reported as ErrSandboxed. See runBackendCommand.
+func runNetstat(ctx context.Context) ([]string, error) {
+	args := []string{"-rn"}
+	if fib, ok := netstatFIB(ctx); ok {
+		args = append(args, "-F", strconv.Itoa(fib))
+	}
+	stdout, stderr, err := runBackendCommand(ctx, netstatSource, "netstat", args...)
+	if err != nil {
+		var execErr *BackendExecError
+		if errors.As(err, &execErr) && jailState() != "" {
+			// Jails may be denied access to the routing table.
+			return nil, &ErrSandboxed{Causes: []error{err}}
+		}
+		return nil, err
+	}
+
+	log := parseLogFrom(ctx)
+	for i, line := range strings.Split(stderr, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			log.skip(netstatSource+" (stderr)", i+1, line, "written to standard error")
+		}
+	}
+	return strings.Split(stdout, "\n"), nil
+}
+
+func platformRequirements() []Requirement {
+	// On Darwin, netstat is only run should the sysctl backend fail.
+	darwin := runtime.GOOS == "darwin" || runtime.GOOS == "ios"
+	reqs := []Requirement{
+		{Kind: RequirementExec, Target: "netstat", Feature: "routes", Optional: darwin},
+	}
+	switch {
+	case darwin:
+		reqs = append(reqs,
+			Requirement{Kind: RequirementRoutingSocket, Feature: "Resolver.DefaultGateway, default routes", Optional: true},
+			Requirement{Kind: RequirementExec, Target: "route", Feature: "Resolver.DefaultGateway", Optional: true},
+			Requirement{Kind: RequirementExec, Target: "networksetup", Feature: "interface descriptions", Optional: true},
+		)
+	case runtime.GOOS == "aix":
+		reqs = append(reqs, Requirement{Kind: RequirementExec, Target: "oslevel", Feature: "netstat profile selection", Optional: true})
+	case runtime.GOOS == "illumos" || runtime.GOOS == "solaris":
+		reqs = append(reqs, Requirement{Kind: RequirementExec, Target: "uname", Feature: "netstat profile selection", Optional: true})
+	default:
+		reqs = append(reqs, Requirement{Kind: RequirementRoutingSocket, Feature: "Resolver.DefaultGateway", Optional: true})
+	}
+	return append(reqs, commonRequirements...)
+}
+
+func netstatBackend() *backend {
+	return &backend{name: "netstat", probe: probeNetstat, routes: netstatRoutes, rawRoutes: netstatRawRoutes}
+}
+
+// probeNetstat checks whether netstat can be found.
+func probeNetstat() error {
+	if _, err := exec.LookPath("netstat"); err != nil {
+		if isSandboxErr(err) {
+			return &ErrSandboxed{Causes: []error{err}}
+		}
+		return err
+	}
+	return nil
+}
+
+func netstatRoutes(ctx context.Context) (NetRouteList, error) {
+	lines, err := runNetstat(ctx)
+	if err != nil {
+		return nil, err
+	}
+	parser := newNetstatParserProfile(currentNetstatProfile())
+	parser.log = parseLogFrom(ctx)
+	for _, line := range lines {
+		if err = parser.feed(line); err != nil {
+			return nil, err
+		}
+	}
+	parser.finish()
+	if fib, ok := netstatFIB(ctx); ok {
+		for _, v := range parser.netData {
+			v.Attrs["FIB"] = strconv.Itoa(fib)
+		}
+	}
+	return parser.netData, nil
+}
+
+func netstatRawRoutes(ctx context.Context) ([]RawRouteMessage, error) {
+	lines, err := runNetstat(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var result []RawRouteMessage
+	for _, line := range lines {
+		if len(strings.TrimSpace(line)) == 0 {
+			continue
+		}
+		result = append(result, RawRouteMessage{Source: netstatSource, Data: []byte(line)})
+	}
+	return result, nil
+}
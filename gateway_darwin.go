@@ -1,23 +1,25 @@
 package defip
 
-import (
-	"os/exec"
-	"strings"
-)
+import "syscall"
 
-func init() {
-	getRoutes = func() (NetRouteList, error) {
-		cmd := exec.Command("netstat", "-rn")
-		output, err := cmd.CombinedOutput()
-		if err != nil {
-			return nil, err
-		}
-		parser := newNetstatParser()
-		for _, line := range strings.Split(string(output), "\n") {
-			if err = parser.feed(line); err != nil {
-				return nil, err
-			}
-		}
-		return parser.netData, nil
+// osVersion returns the macOS product version, such as "14.2.1".
+func osVersion() string {
+	v, err := syscall.Sysctl("kern.osproductversion")
+	if err != nil {
+		return ""
 	}
+	return v
+}
+
+func platformBackends() []*backend {
+	rib := &backend{name: "sysctl", probe: probeRIB, routes: ribRoutes, rawRoutes: ribRawRoutes, defaultRoute: rtsockDefaultRoute}
+	netstat := netstatBackend()
+	netstat.defaultRoute = routeGetDefault
+	rtsock := &backend{name: "rtsock", probe: probeRouteSocket, routes: rtsockRoutes, rawRoutes: rtsockRawRoutes, defaultRoute: rtsockDefaultRoute}
+
+	// Dumping the routing table through sysctl avoids spawning netstat,
+	// which iOS apps and sandboxed macOS apps cannot do. Routing sockets
+	// are only queried for default routes, so they come last, as a source
+	// of default routes should neither be usable.
+	return []*backend{rib, netstat, rtsock}
 }
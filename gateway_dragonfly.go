@@ -0,0 +1,37 @@
+package defip
+
+import (
+	"strconv"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// osVersion returns the DragonFly release, such as "6.4-RELEASE".
+func osVersion() string {
+	v, err := syscall.Sysctl("kern.osrelease")
+	if err != nil {
+		return ""
+	}
+	return v
+}
+
+// ribAlign is the size socket addresses following routing message headers
+// are padded to: that of a long.
+const ribAlign = int(unsafe.Sizeof(uintptr(0)))
+
+// ribHeaderLen returns the length of the header of a routing message.
+func ribHeaderLen(hdr *syscall.RtMsghdr) int {
+	return syscall.SizeofRtMsghdr
+}
+
+// ribHeaderAttrs returns the Attrs of the route described by hdr.
+func ribHeaderAttrs(hdr *syscall.RtMsghdr) map[string]string {
+	attrs := map[string]string{
+		"Use": strconv.Itoa(int(hdr.Use)),
+	}
+	if hdr.Rmx.Expire != 0 {
+		attrs["Expire"] = strconv.FormatInt(ribExpire(int64(hdr.Rmx.Expire), time.Now()), 10)
+	}
+	return attrs
+}
@@ -0,0 +1,84 @@
+package defip
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+func init() {
+	netstatFIB = freebsdFIB
+	jailState = freebsdJailState
+	prepareRouteSocket = freebsdRouteSocketFIB
+}
+
+// osVersion returns the FreeBSD release, such as "14.1-RELEASE".
+func osVersion() string {
+	v, err := syscall.Sysctl("kern.osrelease")
+	if err != nil {
+		return ""
+	}
+	return v
+}
+
+// freebsdFIB returns the forwarding table requested through ctx, or
+// otherwise the one of the calling process. Returns false should the latter
+// not be readable, as sysctl access may be restricted in jails, in which
+// case netstat lists the table of the calling process, left unrecorded.
+func freebsdFIB(ctx context.Context) (int, bool) {
+	if fib, ok := fibFrom(ctx); ok {
+		return fib, true
+	}
+	fib, err := syscall.SysctlUint32("net.my_fibnum")
+	if err != nil {
+		return 0, false
+	}
+	return int(fib), true
+}
+
+// freebsdRouteSocketFIB has routing socket s query the forwarding table
+// requested through ctx, if any, rather than that of the calling process.
+func freebsdRouteSocketFIB(ctx context.Context, s int) error {
+	fib, ok := fibFrom(ctx)
+	if !ok {
+		return nil
+	}
+	if err := syscall.SetsockoptInt(s, syscall.SOL_SOCKET, syscall.SO_SETFIB, fib); err != nil {
+		return fmt.Errorf("could not select forwarding table %d: %w", fib, err)
+	}
+	return nil
+}
+
+// freebsdJailState describes the jail the process runs in, if any, through
+// security.jail.jailed and security.jail.vnet. Unreadable values are taken as
+// not being in a jail.
+func freebsdJailState() string {
+	if jailed, err := syscall.SysctlUint32("security.jail.jailed"); err != nil || jailed == 0 {
+		return ""
+	}
+	if vnet, err := syscall.SysctlUint32("security.jail.vnet"); err == nil && vnet != 0 {
+		return JailVNET
+	}
+	return JailShared
+}
+
+// ribAlign is the size socket addresses following routing message headers
+// are padded to: that of a long.
+const ribAlign = int(unsafe.Sizeof(uintptr(0)))
+
+// ribHeaderLen returns the length of the header of a routing message.
+func ribHeaderLen(hdr *syscall.RtMsghdr) int {
+	return syscall.SizeofRtMsghdr
+}
+
+// ribHeaderAttrs returns the Attrs of the route described by hdr.
+func ribHeaderAttrs(hdr *syscall.RtMsghdr) map[string]string {
+	attrs := map[string]string{}
+	if hdr.Rmx.Expire != 0 {
+		attrs["Expire"] = strconv.FormatInt(ribExpire(int64(hdr.Rmx.Expire), time.Now()), 10)
+	}
+	return attrs
+}
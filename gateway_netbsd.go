@@ -0,0 +1,36 @@
+package defip
+
+import (
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// osVersion returns the NetBSD release, such as "10.0".
+func osVersion() string {
+	v, err := syscall.Sysctl("kern.osrelease")
+	if err != nil {
+		return ""
+	}
+	return v
+}
+
+// ribAlign is the size socket addresses following routing message headers
+// are padded to, being that of a uint64_t since NetBSD 8.
+const ribAlign = 8
+
+// ribHeaderLen returns the length of the header of a routing message.
+func ribHeaderLen(hdr *syscall.RtMsghdr) int {
+	return syscall.SizeofRtMsghdr
+}
+
+// ribHeaderAttrs returns the Attrs of the route described by hdr.
+func ribHeaderAttrs(hdr *syscall.RtMsghdr) map[string]string {
+	attrs := map[string]string{
+		"Use": strconv.Itoa(int(hdr.Use)),
+	}
+	if hdr.Rmx.Expire != 0 {
+		attrs["Expire"] = strconv.FormatInt(ribExpire(int64(hdr.Rmx.Expire), time.Now()), 10)
+	}
+	return attrs
+}
@@ -0,0 +1,17 @@
+//go:build aix || dragonfly || freebsd || netbsd || openbsd || solaris
+
+package defip
+
+import "context"
+
+// netstatDefaultRoute, when set, queries the default route of a given kind
+// for the netstat backend. Set on BSDs, which answer RTM_GET queries on
+// routing sockets (see rtsock_bsd.go), while the routing table itself is
+// only obtained from netstat, as sysctl dumps are not supported yet.
+var netstatDefaultRoute func(ctx context.Context, kind NetRouteKind) (NetRoute, error)
+
+func platformBackends() []*backend {
+	netstat := netstatBackend()
+	netstat.defaultRoute = netstatDefaultRoute
+	return []*backend{netstat}
+}
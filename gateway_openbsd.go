@@ -0,0 +1,36 @@
+package defip
+
+import (
+	"strconv"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// osVersion returns the OpenBSD release, such as "7.5".
+func osVersion() string {
+	v, err := syscall.Sysctl("kern.osrelease")
+	if err != nil {
+		return ""
+	}
+	return v
+}
+
+// ribAlign is the size socket addresses following routing message headers
+// are padded to: that of a long.
+const ribAlign = int(unsafe.Sizeof(uintptr(0)))
+
+// ribHeaderLen returns the length of the header of a routing message, which
+// OpenBSD records in it, so it may grow across releases.
+func ribHeaderLen(hdr *syscall.RtMsghdr) int {
+	return int(hdr.Hdrlen)
+}
+
+// ribHeaderAttrs returns the Attrs of the route described by hdr.
+func ribHeaderAttrs(hdr *syscall.RtMsghdr) map[string]string {
+	attrs := map[string]string{}
+	if hdr.Rmx.Expire != 0 {
+		attrs["Expire"] = strconv.FormatInt(ribExpire(int64(hdr.Rmx.Expire), time.Now()), 10)
+	}
+	return attrs
+}
@@ -0,0 +1,18 @@
+package defip
+
+import (
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// osVersion returns the kernel version, such as "11.4.42.111.0" on Solaris,
+// or the build, such as "joyent_20240516T000420Z" on illumos distributions.
+// As neither offers sysctl, it is obtained from uname once.
+var osVersion = sync.OnceValue(func() string {
+	v, err := exec.Command("uname", "-v").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(v))
+})
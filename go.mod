@@ -1,3 +1,3 @@
-module github.com/heyvito/defip
+module github.com/heyvito/defip/v2
 
 go 1.21
@@ -0,0 +1,281 @@
+//go:build integration
+
+// Integration tests build Linux network namespaces connected through veth
+// pairs, and check the answers of each backend against them. They require
+// root and ip(8), and are run through:
+//
+//	go test -tags integration -run Integration .
+
+package defip
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// integrationIterations is how many times the helper repeats each query,
+// requiring the same answers every time.
+const integrationIterations = 50
+
+// integrationReport holds the answers obtained by the helper within a
+// namespace.
+type integrationReport struct {
+	// Defaults lists routes returned by FindDefaults, as "destination/bits
+	// via gateway dev netif metric n".
+	Defaults []string
+
+	// Selected, SelectedByMetric, and Gateway hold the address selected by a
+	// default Resolver, the one selected by a Resolver honouring route
+	// metrics, and the gateway returned by DefaultGateway, or the error
+	// obtained instead.
+	Selected         string
+	SelectedByMetric string
+	Gateway          string
+}
+
+// netns is a network namespace created for a test, and removed once it
+// completes.
+type netns string
+
+func newNetns(t *testing.T, role string) netns {
+	t.Helper()
+	n := netns(fmt.Sprintf("defip-%s-%d", role, os.Getpid()))
+	run(t, "ip", "netns", "add", string(n))
+	t.Cleanup(func() {
+		_ = exec.Command("ip", "netns", "del", string(n)).Run()
+	})
+	n.ip(t, "link", "set", "lo", "up")
+	return n
+}
+
+// ip runs ip(8) within n.
+func (n netns) ip(t *testing.T, args ...string) {
+	t.Helper()
+	run(t, "ip", append([]string{"-n", string(n)}, args...)...)
+}
+
+func run(t *testing.T, name string, args ...string) {
+	t.Helper()
+	if out, err := exec.Command(name, args...).CombinedOutput(); err != nil {
+		t.Fatalf("%s %s: %s: %s", name, strings.Join(args, " "), err, out)
+	}
+}
+
+// veth connects host to peer through a veth pair named netif on the host
+// side, configured with addrs.
+func veth(t *testing.T, host, peer netns, netif string, addrs ...string) {
+	t.Helper()
+	run(t, "ip", "link", "add", netif, "netns", string(host), "type", "veth",
+		"peer", "name", "p-"+netif, "netns", string(peer))
+	for _, a := range addrs {
+		args := []string{"addr", "add", a, "dev", netif}
+		if strings.Contains(a, ":") {
+			args = append(args, "nodad")
+		}
+		host.ip(t, args...)
+	}
+	host.ip(t, "link", "set", netif, "up")
+	peer.ip(t, "link", "set", "p-"+netif, "up")
+}
+
+// query runs the helper within host using backend, returning its report.
+func query(t *testing.T, host netns, backend string, kind NetRouteKind) integrationReport {
+	t.Helper()
+	out := filepath.Join(t.TempDir(), "report.json")
+	cmd := exec.Command("ip", "netns", "exec", string(host), os.Args[0], "-test.run=^TestIntegrationHelper$")
+	cmd.Env = append(os.Environ(),
+		"DEFIP_INTEGRATION_BACKEND="+backend,
+		"DEFIP_INTEGRATION_KIND="+strconv.Itoa(int(kind)),
+		"DEFIP_INTEGRATION_OUT="+out,
+	)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("helper: %s: %s", err, output)
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report integrationReport
+	if err = json.Unmarshal(data, &report); err != nil {
+		t.Fatal(err)
+	}
+	return report
+}
+
+// TestIntegrationHelper answers queries on behalf of query, from within the
+// namespace it is run in. It does nothing when run directly.
+func TestIntegrationHelper(t *testing.T) {
+	out := os.Getenv("DEFIP_INTEGRATION_OUT")
+	if out == "" {
+		t.Skip("only run by integration tests")
+	}
+	backend := os.Getenv("DEFIP_INTEGRATION_BACKEND")
+	kindN, _ := strconv.Atoi(os.Getenv("DEFIP_INTEGRATION_KIND"))
+	kind := NetRouteKind(kindN)
+
+	ctx := context.Background()
+	r := NewResolver(WithBackends(backend))
+	byMetric := NewResolver(WithBackends(backend), WithHonorOSMetrics(true))
+
+	var first integrationReport
+	for i := 0; i < integrationIterations; i++ {
+		var report integrationReport
+		defaults, err := r.FindDefaults(ctx, kind)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, v := range defaults {
+			p, _ := v.Prefix()
+			metric, _ := v.Metric()
+			report.Defaults = append(report.Defaults, fmt.Sprintf("%s via %s dev %s metric %d", p, v.Gateway.WithZone(""), v.Netif, metric))
+		}
+		report.Selected = selected(r.Select(ctx, kind))
+		report.SelectedByMetric = selected(byMetric.Select(ctx, kind))
+		if gw, err := r.DefaultGateway(ctx, kind); err != nil {
+			report.Gateway = err.Error()
+		} else {
+			report.Gateway = gw.Gateway.WithZone("").String()
+		}
+
+		if i == 0 {
+			first = report
+		} else if fmt.Sprint(report) != fmt.Sprint(first) {
+			t.Fatalf("answers changed on iteration %d: got %+v, first got %+v", i, report, first)
+		}
+	}
+
+	data, err := json.Marshal(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(out, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func selected(sel *Selection, err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return sel.Addr.WithZone("").String() + " on " + sel.Interface.Name
+}
+
+// integrationBackends returns the backends available to be tested.
+func integrationBackends(t *testing.T) []string {
+	if os.Geteuid() != 0 {
+		t.Skip("network namespaces require root")
+	}
+	if _, err := exec.LookPath("ip"); err != nil {
+		t.Skip("ip(8) is not available")
+	}
+	return []string{"procfs", "netlink", "iproute2"}
+}
+
+func TestIntegrationMultipleDefaults(t *testing.T) {
+	for _, backend := range integrationBackends(t) {
+		t.Run(backend, func(t *testing.T) {
+			host, peer := newNetns(t, "host"), newNetns(t, "peer")
+			veth(t, host, peer, "eth0", "192.0.2.10/24")
+			veth(t, host, peer, "eth1", "198.51.100.10/24")
+			host.ip(t, "route", "add", "default", "via", "192.0.2.1", "dev", "eth0", "metric", "200")
+			host.ip(t, "route", "add", "default", "via", "198.51.100.1", "dev", "eth1", "metric", "100")
+
+			report := query(t, host, backend, NetRouteKindV4)
+			wantDefaults := []string{
+				"0.0.0.0/0 via 192.0.2.1 dev eth0 metric 200",
+				"0.0.0.0/0 via 198.51.100.1 dev eth1 metric 100",
+			}
+			if !sameElements(report.Defaults, wantDefaults) {
+				t.Errorf("got defaults %q, want %q", report.Defaults, wantDefaults)
+			}
+			if want := "198.51.100.10 on eth1"; report.SelectedByMetric != want {
+				t.Errorf("got %s selected by metric, want %s", report.SelectedByMetric, want)
+			}
+			if want := "198.51.100.1"; report.Gateway != want {
+				t.Errorf("got gateway %s, want %s", report.Gateway, want)
+			}
+		})
+	}
+}
+
+func TestIntegrationVPNCoveringRoutes(t *testing.T) {
+	for _, backend := range integrationBackends(t) {
+		t.Run(backend, func(t *testing.T) {
+			host, peer := newNetns(t, "host"), newNetns(t, "peer")
+			veth(t, host, peer, "eth0", "192.0.2.10/24")
+			veth(t, host, peer, "vpn0", "10.8.0.2/24")
+			host.ip(t, "route", "add", "default", "via", "192.0.2.1", "dev", "eth0", "metric", "100")
+			host.ip(t, "route", "add", "0.0.0.0/1", "via", "10.8.0.1", "dev", "vpn0")
+			host.ip(t, "route", "add", "128.0.0.0/1", "via", "10.8.0.1", "dev", "vpn0")
+
+			// Covering routes lead to a gateway, and are reported, but do
+			// not make their interface a candidate.
+			report := query(t, host, backend, NetRouteKindV4)
+			wantDefaults := []string{
+				"0.0.0.0/0 via 192.0.2.1 dev eth0 metric 100",
+				"0.0.0.0/1 via 10.8.0.1 dev vpn0 metric 0",
+				"128.0.0.0/1 via 10.8.0.1 dev vpn0 metric 0",
+			}
+			if !sameElements(report.Defaults, wantDefaults) {
+				t.Errorf("got defaults %q, want %q", report.Defaults, wantDefaults)
+			}
+			if want := "192.0.2.10 on eth0"; report.Selected != want {
+				t.Errorf("got %s selected, want %s", report.Selected, want)
+			}
+			if want := "192.0.2.1"; report.Gateway != want {
+				t.Errorf("got gateway %s, want %s", report.Gateway, want)
+			}
+		})
+	}
+}
+
+func TestIntegrationIPv6Weights(t *testing.T) {
+	for _, backend := range integrationBackends(t) {
+		t.Run(backend, func(t *testing.T) {
+			host, peer := newNetns(t, "host"), newNetns(t, "peer")
+			veth(t, host, peer, "eth0", "2001:db8::10/64")
+			veth(t, host, peer, "eth1", "fd00:1::10/64")
+			host.ip(t, "-6", "route", "add", "default", "via", "2001:db8::1", "dev", "eth0", "metric", "100")
+			host.ip(t, "-6", "route", "add", "default", "via", "fd00:1::1", "dev", "eth1", "metric", "200")
+
+			// Unique local addresses outweigh global ones, unless metrics
+			// are honoured.
+			report := query(t, host, backend, NetRouteKindV6)
+			if want := "fd00:1::10 on eth1"; report.Selected != want {
+				t.Errorf("got %s selected, want %s", report.Selected, want)
+			}
+			if want := "2001:db8::10 on eth0"; report.SelectedByMetric != want {
+				t.Errorf("got %s selected by metric, want %s", report.SelectedByMetric, want)
+			}
+			if want := "2001:db8::1"; report.Gateway != want {
+				t.Errorf("got gateway %s, want %s", report.Gateway, want)
+			}
+		})
+	}
+}
+
+// sameElements returns whether a and b hold the same strings, regardless of
+// their order.
+func sameElements(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	seen := map[string]int{}
+	for _, v := range a {
+		seen[v]++
+	}
+	for _, v := range b {
+		if seen[v]--; seen[v] < 0 {
+			return false
+		}
+	}
+	return true
+}
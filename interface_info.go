@@ -0,0 +1,117 @@
+package defip
+
+// InterfaceInfo holds metadata about a network interface not provided by
+// net.Interface.
+type InterfaceInfo struct {
+	// Name is the name of the interface.
+	Name string
+
+	// Type indicates the kind of interface as reported by the platform, such
+	// as "vlan", "bond", "bridge", or "wlan" on Linux (from the kernel's
+	// DEVTYPE). Empty for plain Ethernet interfaces, or when the platform does
+	// not report it.
+	Type string
+
+	// Parent is the name of the interface this one is stacked on, such as
+	// the physical NIC a macvlan or ipvlan interface rides on. Empty when
+	// there is no parent, or when it lives in another network namespace.
+	Parent string
+
+	// Children lists the interfaces stacked on this one.
+	Children []string
+
+	// SRIOV indicates the interface's role in SR-IOV, if any.
+	SRIOV SRIOVRole
+
+	// Class indicates the role of the interface, as determined by
+	// ClassifyInterface.
+	Class InterfaceClass
+
+	// Driver describes the hardware and driver behind the interface. Only
+	// filled by LookupInterfaceInfo and Resolver.Diagnostics, as obtaining
+	// it may require running programs.
+	Driver InterfaceDriver
+}
+
+// InterfaceDriver describes the hardware and driver behind a network
+// interface, helping tell which NIC an interface name refers to. Fields the
+// platform does not report are left empty: Linux reports the driver, its
+// version, the firmware version and the bus address through ethtool's
+// GDRVINFO; macOS the hardware port, such as "Wi-Fi" or "Thunderbolt
+// Ethernet Slot 1", as listed by networksetup; Windows the adapter
+// description, such as "Intel(R) Ethernet Connection I219-V".
+type InterfaceDriver struct {
+	// Name is the name of the driver, such as "e1000e" or "mlx5_core".
+	Name string
+
+	// Version is the version of the driver.
+	Version string
+
+	// Firmware is the version of the firmware running on the device.
+	Firmware string
+
+	// BusInfo is the address of the device on its bus, such as
+	// "0000:00:1f.6" for PCI devices.
+	BusInfo string
+
+	// Description names the device or the port it is attached to.
+	Description string
+}
+
+// SRIOVRole indicates whether an interface is an SR-IOV physical or virtual
+// function.
+type SRIOVRole uint8
+
+const (
+	// SRIOVNone indicates the interface does not take part in SR-IOV, or that
+	// the platform cannot determine it.
+	SRIOVNone SRIOVRole = iota
+
+	// SRIOVPhysicalFunction indicates an SR-IOV capable physical function.
+	SRIOVPhysicalFunction
+
+	// SRIOVVirtualFunction indicates a virtual function exposed by a physical
+	// function.
+	SRIOVVirtualFunction
+)
+
+func (s SRIOVRole) String() string {
+	switch s {
+	case SRIOVPhysicalFunction:
+		return "PF"
+	case SRIOVVirtualFunction:
+		return "VF"
+	}
+	return "none"
+}
+
+// lookupInterfaceInfo fills platform-specific metadata for the named
+// interface. Platforms without such metadata only report the name.
+var lookupInterfaceInfo = func(name string) InterfaceInfo {
+	return InterfaceInfo{Name: name, Class: ClassifyInterface(name)}
+}
+
+// lookupInterfaceDriver describes the driver behind the named interface.
+// Platforms without such metadata report nothing.
+var lookupInterfaceDriver = func(name string) InterfaceDriver {
+	return InterfaceDriver{}
+}
+
+// LookupInterfaceInfo returns metadata about the named interface, such as the
+// parent/child relationships of macvlan and ipvlan interfaces, and the
+// driver behind it.
+func LookupInterfaceInfo(name string) InterfaceInfo {
+	info := lookupInterfaceInfo(name)
+	info.Driver = lookupInterfaceDriver(name)
+	return info
+}
+
+// cString returns the NUL-terminated string held by b.
+func cString(b []byte) string {
+	for i, c := range b {
+		if c == 0 {
+			return string(b[:i])
+		}
+	}
+	return string(b)
+}
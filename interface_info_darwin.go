@@ -0,0 +1,64 @@
+package defip
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// hardwarePortsSource identifies the listing of hardware ports in errors.
+const hardwarePortsSource = "networksetup -listallhardwareports"
+
+// hardwarePortsTTL bounds how long hardware ports are cached, as they only
+// change when adapters are plugged in or removed.
+const hardwarePortsTTL = time.Minute
+
+var hardwarePorts struct {
+	sync.Mutex
+	byDevice map[string]string
+	at       time.Time
+}
+
+func init() {
+	lookupInterfaceDriver = func(name string) InterfaceDriver {
+		hardwarePorts.Lock()
+		defer hardwarePorts.Unlock()
+		if hardwarePorts.byDevice == nil || time.Since(hardwarePorts.at) > hardwarePortsTTL {
+			hardwarePorts.byDevice = listHardwarePorts()
+			hardwarePorts.at = time.Now()
+		}
+		return InterfaceDriver{Description: hardwarePorts.byDevice[name]}
+	}
+}
+
+// listHardwarePorts returns the hardware port of each device listed by
+// networksetup, such as "Wi-Fi" for en0, which macOS derives from IOKit.
+// Returns an empty map when networksetup cannot be run, as on iOS.
+func listHardwarePorts() map[string]string {
+	ports := map[string]string{}
+	stdout, _, err := runBackendCommand(context.Background(), hardwarePortsSource, "networksetup", "-listallhardwareports")
+	if err != nil {
+		return ports
+	}
+
+	// Ports are listed as blocks of "Key: value" lines, each naming its
+	// port before its device.
+	var port string
+	for _, line := range strings.Split(stdout, "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		switch strings.TrimSpace(key) {
+		case "Hardware Port":
+			port = strings.TrimSpace(value)
+		case "Device":
+			if port != "" {
+				ports[strings.TrimSpace(value)] = port
+			}
+			port = ""
+		}
+	}
+	return ports
+}
@@ -0,0 +1,100 @@
+package defip
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+func init() {
+	lookupInterfaceDriver = ethtoolDriverInfo
+	lookupInterfaceInfo = func(name string) InterfaceInfo {
+		base := filepath.Join("/sys/class/net", name)
+		info := InterfaceInfo{Name: name, Class: ClassifyInterface(name)}
+
+		if uevent, err := os.ReadFile(filepath.Join(base, "uevent")); err == nil {
+			for _, line := range strings.Split(string(uevent), "\n") {
+				if v, ok := strings.CutPrefix(line, "DEVTYPE="); ok {
+					info.Type = v
+				}
+			}
+		}
+
+		// Virtual functions link back to their physical function, which in
+		// turn exposes how many virtual functions it supports.
+		if _, err := os.Lstat(filepath.Join(base, "device", "physfn")); err == nil {
+			info.SRIOV = SRIOVVirtualFunction
+		} else if _, err := os.Stat(filepath.Join(base, "device", "sriov_totalvfs")); err == nil {
+			info.SRIOV = SRIOVPhysicalFunction
+		}
+
+		// Stacked devices are linked through lower_<name> and upper_<name>
+		// entries in each other's directories.
+		entries, err := os.ReadDir(base)
+		if err != nil {
+			return info
+		}
+		for _, e := range entries {
+			if v, ok := strings.CutPrefix(e.Name(), "lower_"); ok && info.Parent == "" {
+				info.Parent = v
+			}
+			if v, ok := strings.CutPrefix(e.Name(), "upper_"); ok {
+				info.Children = append(info.Children, v)
+			}
+		}
+
+		return info
+	}
+}
+
+// SIOCETHTOOL and ETHTOOL_GDRVINFO, missing from package syscall.
+const (
+	siocEthtool     = 0x8946
+	ethtoolGDrvInfo = 0x00000003
+)
+
+// ethtoolDrvInfo mirrors struct ethtool_drvinfo.
+type ethtoolDrvInfo struct {
+	cmd         uint32
+	driver      [32]byte
+	version     [32]byte
+	fwVersion   [32]byte
+	busInfo     [32]byte
+	eromVersion [32]byte
+	_           [12]byte
+	_           [5]uint32 // n_priv_flags through regdump_len
+}
+
+// ethtoolDriverInfo describes the driver behind the named interface through
+// ethtool's ETHTOOL_GDRVINFO. Virtual interfaces report their driver too,
+// such as "veth" or "bridge", but usually no bus address.
+func ethtoolDriverInfo(name string) InterfaceDriver {
+	s, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_DGRAM|syscall.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return InterfaceDriver{}
+	}
+	defer syscall.Close(s)
+
+	info := ethtoolDrvInfo{cmd: ethtoolGDrvInfo}
+	var req struct {
+		name [syscall.IFNAMSIZ]byte
+		data unsafe.Pointer
+		_    [16]byte
+	}
+	if len(name) >= len(req.name) {
+		return InterfaceDriver{}
+	}
+	copy(req.name[:], name)
+	req.data = unsafe.Pointer(&info)
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(s), siocEthtool, uintptr(unsafe.Pointer(&req))); errno != 0 {
+		return InterfaceDriver{}
+	}
+	return InterfaceDriver{
+		Name:     cString(info.driver[:]),
+		Version:  cString(info.version[:]),
+		Firmware: cString(info.fwVersion[:]),
+		BusInfo:  cString(info.busInfo[:]),
+	}
+}
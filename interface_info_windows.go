@@ -0,0 +1,42 @@
+package defip
+
+import (
+	"net"
+	"syscall"
+	"unsafe"
+)
+
+func init() {
+	lookupInterfaceDriver = adapterDriverInfo
+}
+
+// adapterDriverInfo describes the adapter behind the named interface through
+// GetAdaptersInfo, matching it by index, as net.Interface names adapters by
+// their friendly name rather than the GUID GetAdaptersInfo reports. Adapters
+// without IPv4 enabled are not listed, and yield no description.
+func adapterDriverInfo(name string) InterfaceDriver {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return InterfaceDriver{}
+	}
+
+	size := uint32(unsafe.Sizeof(syscall.IpAdapterInfo{}) * 16)
+	var buf []byte
+	for {
+		buf = make([]byte, size)
+		err = syscall.GetAdaptersInfo((*syscall.IpAdapterInfo)(unsafe.Pointer(&buf[0])), &size)
+		if err != syscall.ERROR_BUFFER_OVERFLOW {
+			break
+		}
+	}
+	if err != nil {
+		return InterfaceDriver{}
+	}
+
+	for ai := (*syscall.IpAdapterInfo)(unsafe.Pointer(&buf[0])); ai != nil; ai = ai.Next {
+		if int(ai.Index) == iface.Index {
+			return InterfaceDriver{Description: cString(ai.Description[:])}
+		}
+	}
+	return InterfaceDriver{}
+}
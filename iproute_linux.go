@@ -0,0 +1,303 @@
+package defip
+
+import (
+	"context"
+	"encoding/json"
+	"net/netip"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// ipRouteArgs lists the arguments passed to ip to list the main routing
+// table of each family.
+var ipRouteArgs = map[NetRouteKind][]string{
+	NetRouteKindV4: {"-j", "route", "show", "table", "main"},
+	NetRouteKindV6: {"-j", "-6", "route", "show", "table", "main"},
+}
+
+// ipRouteSource returns the name identifying the routing table of a given
+// kind listed by ip, in raw routes and logs.
+func ipRouteSource(kind NetRouteKind) string {
+	return "ip " + strings.Join(ipRouteArgs[kind], " ")
+}
+
+// ipRoute is a route as listed by `ip -j route'.
+type ipRoute struct {
+	Type     string      `json:"type"`
+	Dst      string      `json:"dst"`
+	Gateway  string      `json:"gateway"`
+	Via      *ipRouteVia `json:"via"`
+	Dev      string      `json:"dev"`
+	Protocol string      `json:"protocol"`
+	Scope    string      `json:"scope"`
+	Metric   *uint32     `json:"metric"`
+	Prefsrc  string      `json:"prefsrc"`
+	Metrics  []ipMetrics `json:"metrics"`
+	Nexthops []ipNexthop `json:"nexthops"`
+}
+
+// ipRouteVia holds the gateway of routes through a gateway of another
+// family, such as IPv4 routes through IPv6 gateways.
+type ipRouteVia struct {
+	Host string `json:"host"`
+}
+
+type ipMetrics struct {
+	MTU *uint32 `json:"mtu"`
+}
+
+// ipNexthop is a gateway and device of a multipath route.
+type ipNexthop struct {
+	Gateway string      `json:"gateway"`
+	Via     *ipRouteVia `json:"via"`
+	Dev     string      `json:"dev"`
+}
+
+// ipRouteTypes maps the names ip gives to route types to their values. ip
+// omits the type of unicast routes.
+var ipRouteTypes = map[string]uint8{
+	"":            syscall.RTN_UNICAST,
+	"unicast":     syscall.RTN_UNICAST,
+	"local":       syscall.RTN_LOCAL,
+	"broadcast":   syscall.RTN_BROADCAST,
+	"anycast":     syscall.RTN_ANYCAST,
+	"multicast":   syscall.RTN_MULTICAST,
+	"blackhole":   syscall.RTN_BLACKHOLE,
+	"unreachable": syscall.RTN_UNREACHABLE,
+	"prohibit":    syscall.RTN_PROHIBIT,
+	"throw":       syscall.RTN_THROW,
+	"nat":         syscall.RTN_NAT,
+}
+
+// ipRouteProtocols maps the names ip gives to route protocols to their
+// values, as reported by the netlink backend. See /etc/iproute2/rt_protos.
+var ipRouteProtocols = map[string]int{
+	"redirect":   1,
+	"kernel":     2,
+	"boot":       3,
+	"static":     4,
+	"gated":      8,
+	"ra":         9,
+	"mrt":        10,
+	"zebra":      11,
+	"bird":       12,
+	"dnrouted":   13,
+	"xorp":       14,
+	"ntk":        15,
+	"dhcp":       16,
+	"keepalived": 18,
+	"babel":      42,
+	"openr":      99,
+	"bgp":        186,
+	"isis":       187,
+	"ospf":       188,
+	"rip":        189,
+	"eigrp":      192,
+}
+
+// parseIPRouteProtocol returns the value of a route protocol named by ip,
+// which omits it for routes installed at boot, and prints unnamed ones as
+// numbers.
+func parseIPRouteProtocol(name string) (int, bool) {
+	if name == "" {
+		return 3, true
+	}
+	if v, ok := ipRouteProtocols[name]; ok {
+		return v, true
+	}
+	v, err := strconv.Atoi(name)
+	return v, err == nil && v >= 0 && v <= 255
+}
+
+func (h ipNexthop) gateway() string {
+	if h.Via != nil {
+		return h.Via.Host
+	}
+	return h.Gateway
+}
+
+// probeIPRoute checks whether ip can be found.
+func probeIPRoute() error {
+	if _, err := exec.LookPath("ip"); err != nil {
+		if isSandboxErr(err) {
+			return &ErrSandboxed{Causes: []error{err}}
+		}
+		return err
+	}
+	return nil
+}
+
+// runIPRoute runs ip to list the main routing table of a given kind,
+// returning each route as the JSON object representing it.
+func runIPRoute(ctx context.Context, kind NetRouteKind) ([]json.RawMessage, error) {
+	source := ipRouteSource(kind)
+	stdout, stderr, err := runBackendCommand(ctx, source, "ip", ipRouteArgs[kind]...)
+	if err != nil {
+		return nil, err
+	}
+
+	log := parseLogFrom(ctx)
+	for i, line := range strings.Split(stderr, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			log.skip(source+" (stderr)", i+1, line, "written to standard error")
+		}
+	}
+
+	var routes []json.RawMessage
+	if err = json.Unmarshal([]byte(stdout), &routes); err != nil {
+		return nil, &ErrCantParse{Source: source}
+	}
+	return routes, nil
+}
+
+func ipRouteRoutes(ctx context.Context) (NetRouteList, error) {
+	log := parseLogFrom(ctx)
+	var routes NetRouteList
+	for _, kind := range []NetRouteKind{NetRouteKindV4, NetRouteKindV6} {
+		raw, err := runIPRoute(ctx, kind)
+		if err != nil {
+			return nil, err
+		}
+		source := ipRouteSource(kind)
+		for i, v := range raw {
+			parsed, reason := parseIPRoute(kind, v)
+			if reason != "" {
+				log.skip(source, i+1, string(v), reason)
+				continue
+			}
+			for _, route := range parsed {
+				log.printf(source, "accepted route %d: %s", i+1, route)
+			}
+			routes = append(routes, parsed...)
+		}
+	}
+	markClasslessRoutes(routes)
+	return routes, nil
+}
+
+func ipRouteRawRoutes(ctx context.Context) ([]RawRouteMessage, error) {
+	var result []RawRouteMessage
+	for _, kind := range []NetRouteKind{NetRouteKindV4, NetRouteKindV6} {
+		raw, err := runIPRoute(ctx, kind)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range raw {
+			result = append(result, RawRouteMessage{Source: ipRouteSource(kind), Data: v})
+		}
+	}
+	return result, nil
+}
+
+// parseIPRoute converts a route listed by ip into one route per nexthop.
+// Returns a reason when the route is malformed.
+func parseIPRoute(kind NetRouteKind, raw json.RawMessage) (NetRouteList, string) {
+	var r ipRoute
+	if err := json.Unmarshal(raw, &r); err != nil {
+		return nil, "malformed route"
+	}
+
+	dst, bits, ok := parseIPRouteDst(kind, r.Dst)
+	if !ok {
+		return nil, "malformed destination"
+	}
+
+	proto, ok := parseIPRouteProtocol(r.Protocol)
+	if !ok {
+		return nil, "unknown protocol"
+	}
+
+	// Flags are derived as for routes read through netlink.
+	rtType, ok := ipRouteTypes[r.Type]
+	if !ok {
+		return nil, "unknown type"
+	}
+	flags := netlinkRouteFlags(&syscall.RtMsg{Type: rtType, Protocol: uint8(proto), Dst_len: uint8(bits)}, dst)
+
+	attrs := map[string]string{
+		"DestinationPrefix": strconv.Itoa(bits),
+		"Metric":            "0",
+		"Protocol":          strconv.Itoa(proto),
+		"Table":             strconv.Itoa(syscall.RT_TABLE_MAIN),
+	}
+	if r.Metric != nil {
+		attrs["Metric"] = strconv.FormatUint(uint64(*r.Metric), 10)
+	}
+	if r.Scope != "" {
+		attrs["Scope"] = r.Scope
+	}
+	if r.Prefsrc != "" {
+		attrs["PrefSrc"] = r.Prefsrc
+	}
+	for _, m := range r.Metrics {
+		if m.MTU != nil {
+			attrs["MTU"] = strconv.FormatUint(uint64(*m.MTU), 10)
+		}
+	}
+
+	hops := r.Nexthops
+	if len(hops) == 0 {
+		hops = []ipNexthop{{Gateway: r.Gateway, Via: r.Via, Dev: r.Dev}}
+	}
+
+	var routes NetRouteList
+	for _, h := range hops {
+		if h.Dev == "" {
+			return nil, "no device"
+		}
+
+		hopFlags := flags
+		gateway := netip.IPv4Unspecified()
+		if kind == NetRouteKindV6 {
+			gateway = netip.IPv6Unspecified()
+		}
+		if s := h.gateway(); s != "" {
+			addr, err := netip.ParseAddr(s)
+			if err != nil {
+				return nil, "malformed gateway"
+			}
+			gateway = addr
+			hopFlags |= rtfGateway
+		}
+
+		a := make(map[string]string, len(attrs))
+		for k, v := range attrs {
+			a[k] = v
+		}
+		routes = append(routes, NetRoute{
+			Kind:        kind,
+			Destination: withLinkZone(dst, h.Dev),
+			Flags:       hopFlags.String(),
+			FlagBits:    linuxRouteFlags(hopFlags),
+			Netif:       h.Dev,
+			Gateway:     withLinkZone(gateway, h.Dev),
+			Attrs:       a,
+		})
+	}
+	return routes, ""
+}
+
+// parseIPRouteDst parses the destination of a route listed by ip: "default",
+// a prefix, or an address, which denotes a host route.
+func parseIPRouteDst(kind NetRouteKind, dst string) (netip.Addr, int, bool) {
+	if dst == "default" {
+		if kind == NetRouteKindV6 {
+			return netip.IPv6Unspecified(), 0, true
+		}
+		return netip.IPv4Unspecified(), 0, true
+	}
+	if strings.Contains(dst, "/") {
+		p, err := netip.ParsePrefix(dst)
+		if err != nil {
+			return netip.Addr{}, 0, false
+		}
+		return p.Addr(), p.Bits(), true
+	}
+	addr, err := netip.ParseAddr(dst)
+	if err != nil {
+		return netip.Addr{}, 0, false
+	}
+	return addr, addr.BitLen(), true
+}
@@ -0,0 +1,125 @@
+package defip
+
+import (
+	"context"
+	"net/netip"
+	"os"
+	"strings"
+)
+
+// kubeNodeIPEnvVars lists environment variables commonly populated through
+// the downward API (status.hostIP) in pod specs.
+var kubeNodeIPEnvVars = []string{"NODE_IP", "HOST_IP", "KUBE_NODE_IP"}
+
+// kubeletFlagFiles lists files commonly used to provide extra flags to the
+// kubelet, in which `--node-ip' may be set.
+var kubeletFlagFiles = []string{
+	"/var/lib/kubelet/kubeadm-flags.env",
+	"/etc/default/kubelet",
+	"/etc/sysconfig/kubelet",
+}
+
+// KubernetesNodeCheck represents the result of reconciling the IP detected by
+// this package with the one advertised by the kubelet.
+type KubernetesNodeCheck struct {
+	// NodeIP is the IP advertised by the kubelet as the node's InternalIP.
+	NodeIP netip.Addr
+
+	// Source indicates where NodeIP was obtained from; either the name of an
+	// environment variable, or the path of a kubelet flags file.
+	Source string
+
+	// Detected is the IP detected by FindDefaultIP.
+	Detected netip.Addr
+
+	// Mismatch indicates whether NodeIP and Detected differ.
+	Mismatch bool
+}
+
+// CheckKubernetesNodeIP compares the IP detected by FindDefaultIP for a given
+// kind with the node IP advertised by the kubelet, obtained either from the
+// downward API (NODE_IP, HOST_IP, or KUBE_NODE_IP environment variables) or
+// from the kubelet's `--node-ip' flag. Returns ErrNoNodeIP in case no node IP
+// matching the provided kind can be found.
+//
+// Deprecated: Use Resolver.CheckKubernetesNodeIP.
+func CheckKubernetesNodeIP(kind NetRouteKind) (*KubernetesNodeCheck, error) {
+	return defaultResolver.CheckKubernetesNodeIP(context.Background(), kind)
+}
+
+// CheckKubernetesNodeIP compares the IP detected by the Resolver for a given
+// kind with the node IP advertised by the kubelet. See the package-level
+// CheckKubernetesNodeIP for details on how the node IP is obtained.
+func (r *Resolver) CheckKubernetesNodeIP(ctx context.Context, kind NetRouteKind) (*KubernetesNodeCheck, error) {
+	nodeIP, source, ok := findKubernetesNodeIP(kind)
+	if !ok {
+		return nil, ErrNoNodeIP
+	}
+
+	detected, err := r.FindDefaultIP(ctx, kind)
+	if err != nil {
+		return nil, err
+	}
+
+	return &KubernetesNodeCheck{
+		NodeIP:   nodeIP,
+		Source:   source,
+		Detected: detected,
+		Mismatch: nodeIP != detected.WithZone(""),
+	}, nil
+}
+
+func findKubernetesNodeIP(kind NetRouteKind) (netip.Addr, string, bool) {
+	for _, name := range kubeNodeIPEnvVars {
+		if ip, ok := matchNodeIP(kind, os.Getenv(name)); ok {
+			return ip, name, true
+		}
+	}
+
+	for _, path := range kubeletFlagFiles {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		if ip, ok := matchNodeIP(kind, kubeletNodeIPFlag(string(data))); ok {
+			return ip, path, true
+		}
+	}
+
+	return netip.Addr{}, "", false
+}
+
+// kubeletNodeIPFlag extracts the value of `--node-ip' from a kubelet flags
+// file, given either as `--node-ip=X' or `--node-ip X', such as:
+//
+//	KUBELET_KUBEADM_ARGS="--container-runtime-endpoint=... --node-ip=10.0.0.4"
+func kubeletNodeIPFlag(data string) string {
+	fields := strings.Fields(data)
+	for i, field := range fields {
+		field = strings.Trim(field, `"'`)
+		if j := strings.Index(field, "--node-ip="); j != -1 {
+			return strings.Trim(field[j+len("--node-ip="):], `"'`)
+		}
+		if strings.HasSuffix(field, "--node-ip") && i+1 < len(fields) {
+			return strings.Trim(fields[i+1], `"'`)
+		}
+	}
+
+	return ""
+}
+
+// matchNodeIP parses a node IP value, which may contain a comma-separated
+// dual-stack pair, returning the address matching the provided kind.
+func matchNodeIP(kind NetRouteKind, value string) (netip.Addr, bool) {
+	for _, v := range strings.Split(value, ",") {
+		ip, err := netip.ParseAddr(strings.TrimSpace(v))
+		if err != nil {
+			continue
+		}
+		if kind.MatchesAddr(ip) {
+			return ip, true
+		}
+	}
+
+	return netip.Addr{}, false
+}
@@ -0,0 +1,22 @@
+package defip
+
+import "testing"
+
+func TestKubeletNodeIPFlag(t *testing.T) {
+	tests := []struct {
+		data string
+		want string
+	}{
+		{`KUBELET_KUBEADM_ARGS="--container-runtime-endpoint=unix:///run/containerd/containerd.sock --node-ip=10.0.0.4"`, "10.0.0.4"},
+		{`KUBELET_KUBEADM_ARGS="--container-runtime-endpoint=unix:///run/containerd/containerd.sock --node-ip 10.0.0.4"`, "10.0.0.4"},
+		{`KUBELET_EXTRA_ARGS="--node-ip 10.0.0.4,fd00::4 --v=2"`, "10.0.0.4,fd00::4"},
+		{`KUBELET_EXTRA_ARGS=--node-ip=fd00::4`, "fd00::4"},
+		{`KUBELET_EXTRA_ARGS="--v=2"`, ""},
+		{`KUBELET_EXTRA_ARGS="--node-ip"`, ""},
+	}
+	for _, tt := range tests {
+		if got := kubeletNodeIPFlag(tt.data); got != tt.want {
+			t.Errorf("kubeletNodeIPFlag(%q) = %q, want %q", tt.data, got, tt.want)
+		}
+	}
+}
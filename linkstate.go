@@ -0,0 +1,10 @@
+package defip
+
+import "net"
+
+// linkUp returns whether iface is operationally up, that is, administratively
+// up and with carrier. Platforms without a more precise source rely on the
+// interface's running flag.
+var linkUp = func(iface *net.Interface) bool {
+	return iface.Flags&net.FlagUp != 0 && iface.Flags&net.FlagRunning != 0
+}
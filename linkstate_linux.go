@@ -0,0 +1,28 @@
+package defip
+
+import (
+	"net"
+	"os"
+	"strings"
+)
+
+func init() {
+	linkUp = func(iface *net.Interface) bool {
+		if iface.Flags&net.FlagUp == 0 {
+			return false
+		}
+
+		state, err := os.ReadFile("/sys/class/net/" + iface.Name + "/operstate")
+		if err != nil {
+			return iface.Flags&net.FlagRunning != 0
+		}
+
+		// Virtual interfaces such as tunnels often report "unknown", as they
+		// have no notion of carrier.
+		switch strings.TrimSpace(string(state)) {
+		case "up", "unknown":
+			return true
+		}
+		return false
+	}
+}
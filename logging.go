@@ -0,0 +1,69 @@
+package defip
+
+import (
+	"log/slog"
+	"slices"
+	"strconv"
+)
+
+// LogValue implements slog.LogValuer, emitting the route as a group of
+// attributes.
+func (n NetRoute) LogValue() slog.Value {
+	attrs := []slog.Attr{
+		slog.String("kind", n.Kind.String()),
+		slog.String("destination", n.Destination.String()),
+		slog.String("gateway", n.Gateway.String()),
+		slog.String("netif", n.Netif),
+		slog.String("flags", n.Flags),
+	}
+	if n.GatewayHardware != nil {
+		attrs = append(attrs, slog.String("gateway_hardware", n.GatewayHardware.String()))
+	}
+	if len(n.Attrs) > 0 {
+		// Attributes are sorted by key, so records are reproducible.
+		keys := make([]string, 0, len(n.Attrs))
+		for k := range n.Attrs {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+		extra := make([]any, 0, len(keys))
+		for _, k := range keys {
+			extra = append(extra, slog.String(k, n.Attrs[k]))
+		}
+		attrs = append(attrs, slog.Group("attrs", extra...))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// LogValue implements slog.LogValuer, emitting the list as a group indexed by
+// the position of each route.
+func (n NetRouteList) LogValue() slog.Value {
+	attrs := make([]slog.Attr, 0, len(n)+1)
+	attrs = append(attrs, slog.Int("count", len(n)))
+	for i, v := range n {
+		attrs = append(attrs, slog.Any(strconv.Itoa(i), v))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// LogValue implements slog.LogValuer, emitting the snapshot metadata along
+// with its routes.
+func (r RouteSnapshot) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Time("collected_at", r.CollectedAt),
+		slog.Int("changes", r.Changes),
+		slog.Bool("unstable", r.Unstable),
+		slog.Int("warnings", len(r.warnings)),
+		slog.Any("routes", r.Routes),
+	)
+}
+
+// LogValue implements slog.LogValuer, emitting the result of the check.
+func (k KubernetesNodeCheck) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("node_ip", k.NodeIP.String()),
+		slog.String("source", k.Source),
+		slog.String("detected", k.Detected.String()),
+		slog.Bool("mismatch", k.Mismatch),
+	)
+}
@@ -0,0 +1,36 @@
+package defip
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestNetRouteLogValueSortsAttrs(t *testing.T) {
+	route := NetRoute{Kind: NetRouteKindV4, Netif: "sim0", Attrs: map[string]string{}}
+	for _, k := range []string{"Use", "Table", "Refs", "Metric", "Expire", "DestinationPrefix"} {
+		route.Attrs[k] = "1"
+	}
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) == 0 && (a.Key == slog.TimeKey || a.Key == slog.LevelKey || a.Key == slog.MessageKey) {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+	logger.Info("", "route", route)
+
+	out := buf.String()
+	var last int
+	for _, k := range []string{"DestinationPrefix", "Expire", "Metric", "Refs", "Table", "Use"} {
+		i := strings.Index(out, "route.attrs."+k+"=")
+		if i < 0 || i < last {
+			t.Fatalf("attributes not sorted by key: %s", out)
+		}
+		last = i
+	}
+}
@@ -0,0 +1,24 @@
+package defip
+
+// LinkMember represents an interface enslaved to a bond or bridge.
+type LinkMember struct {
+	// Name is the name of the member interface.
+	Name string
+
+	// Active indicates whether the member currently carries traffic. For
+	// bonds in active-backup mode, only the active slave is marked; for other
+	// aggregations, every member that is up is.
+	Active bool
+
+	// Up indicates whether the member is operationally up.
+	Up bool
+}
+
+// isEnslaved returns whether the named interface is a member of a bond or
+// bridge, in which case addresses configured on it are not used for
+// traffic. Platforms without a way to determine it report false.
+var isEnslaved = func(name string) bool { return false }
+
+// linkMembers returns the members of the named bond or bridge, or nil
+// in case it is not an aggregation, or the platform cannot determine it.
+var linkMembers = func(name string) []LinkMember { return nil }
@@ -0,0 +1,53 @@
+package defip
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func init() {
+	isEnslaved = func(name string) bool {
+		_, err := os.Lstat(filepath.Join("/sys/class/net", name, "master"))
+		return err == nil
+	}
+
+	linkMembers = func(name string) []LinkMember {
+		base := filepath.Join("/sys/class/net", name)
+
+		// Bridges list their ports under brif, while bonds list their slaves
+		// in bonding/slaves.
+		var names []string
+		if entries, err := os.ReadDir(filepath.Join(base, "brif")); err == nil {
+			for _, e := range entries {
+				names = append(names, e.Name())
+			}
+		} else if slaves, err := os.ReadFile(filepath.Join(base, "bonding", "slaves")); err == nil {
+			names = strings.Fields(string(slaves))
+		}
+		if len(names) == 0 {
+			return nil
+		}
+
+		activeSlave := ""
+		if v, err := os.ReadFile(filepath.Join(base, "bonding", "active_slave")); err == nil {
+			activeSlave = strings.TrimSpace(string(v))
+		}
+
+		members := make([]LinkMember, 0, len(names))
+		for _, n := range names {
+			m := LinkMember{Name: n}
+			if iface, err := net.InterfaceByName(n); err == nil {
+				m.Up = linkUp(iface)
+			}
+			if activeSlave != "" {
+				m.Active = n == activeSlave
+			} else {
+				m.Active = m.Up
+			}
+			members = append(members, m)
+		}
+		return members
+	}
+}
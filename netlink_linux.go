@@ -0,0 +1,472 @@
+package defip
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+	"runtime"
+	"strconv"
+	"syscall"
+	"unsafe"
+)
+
+// netlinkSource identifies routes obtained through netlink in raw routes and
+// logs.
+const netlinkSource = "netlink RTM_GETROUTE"
+
+// Attributes of route messages missing from package syscall.
+const (
+	rtaTable = 0xf
+	rtaVia   = 0x12
+)
+
+// rtProtRA is RTPROT_RA, the protocol of routes learned from Router
+// Advertisements.
+const rtProtRA = 9
+
+// probeNetlink checks whether a netlink route socket can be opened.
+func probeNetlink() error {
+	s, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, syscall.NETLINK_ROUTE)
+	if err != nil {
+		if isSandboxErr(err) {
+			return &ErrSandboxed{Causes: []error{err}}
+		}
+		return fmt.Errorf("could not open netlink socket: %w", err)
+	}
+	syscall.Close(s)
+	return nil
+}
+
+// netlinkDump requests a dump of every routing table through netlink,
+// returning the messages received. Unlike syscall.NetlinkRIB, the socket is
+// never bound explicitly, as Android denies bind(2) on netlink sockets to
+// apps while still permitting route dumps.
+func netlinkDump(ctx context.Context) ([]syscall.NetlinkMessage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, syscall.NETLINK_ROUTE)
+	if err != nil {
+		if isSandboxErr(err) {
+			return nil, &ErrSandboxed{Causes: []error{err}}
+		}
+		return nil, err
+	}
+	defer syscall.Close(s)
+
+	const seq = 1
+	req := make([]byte, syscall.NLMSG_HDRLEN+syscall.SizeofRtGenmsg)
+	binary.NativeEndian.PutUint32(req[0:4], uint32(len(req)))
+	binary.NativeEndian.PutUint16(req[4:6], syscall.RTM_GETROUTE)
+	binary.NativeEndian.PutUint16(req[6:8], syscall.NLM_F_REQUEST|syscall.NLM_F_DUMP)
+	binary.NativeEndian.PutUint32(req[8:12], seq)
+	req[syscall.NLMSG_HDRLEN] = syscall.AF_UNSPEC
+	if err := syscall.Sendto(s, req, 0, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}); err != nil {
+		if isSandboxErr(err) {
+			return nil, &ErrSandboxed{Causes: []error{err}}
+		}
+		return nil, err
+	}
+
+	limit := outputLimitFrom(ctx)
+	buf := make([]byte, syscall.Getpagesize()*8)
+	var result []syscall.NetlinkMessage
+	var read int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		n, _, err := syscall.Recvfrom(s, buf, 0)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if read += int64(n); limit > 0 && read > limit {
+			return nil, &ErrOutputTooLarge{Cmd: netlinkSource, Limit: limit}
+		}
+		msgs, err := syscall.ParseNetlinkMessage(append([]byte(nil), buf[:n]...))
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range msgs {
+			if m.Header.Seq != seq {
+				continue
+			}
+			switch m.Header.Type {
+			case syscall.NLMSG_DONE:
+				return result, nil
+			case syscall.NLMSG_ERROR:
+				if len(m.Data) >= 4 {
+					if errno := -int32(binary.NativeEndian.Uint32(m.Data[0:4])); errno != 0 {
+						err := syscall.Errno(errno)
+						if isSandboxErr(err) {
+							return nil, &ErrSandboxed{Causes: []error{err}}
+						}
+						return nil, fmt.Errorf("%s: %w", netlinkSource, err)
+					}
+				}
+				return nil, fmt.Errorf("%s: malformed error message", netlinkSource)
+			case syscall.RTM_NEWROUTE:
+				result = append(result, m)
+			}
+		}
+	}
+}
+
+func netlinkRoutes(ctx context.Context) (NetRouteList, error) {
+	msgs, err := netlinkDump(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	log := parseLogFrom(ctx)
+	names := map[int32]string{}
+	var routes NetRouteList
+	for i := range msgs {
+		m := &msgs[i]
+		parsed, reason := parseNetlinkRoute(m, names)
+		if reason != "" {
+			log.skip(netlinkSource, i+1, fmt.Sprintf("%x", m.Data), reason)
+			continue
+		}
+		for _, route := range parsed {
+			log.printf(netlinkSource, "accepted message %d: %s", i+1, route)
+		}
+		routes = append(routes, parsed...)
+	}
+	markClasslessRoutes(routes)
+	return routes, nil
+}
+
+func netlinkRawRoutes(ctx context.Context) ([]RawRouteMessage, error) {
+	msgs, err := netlinkDump(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]RawRouteMessage, 0, len(msgs))
+	for _, m := range msgs {
+		result = append(result, RawRouteMessage{Source: netlinkSource, Data: m.Data})
+	}
+	return result, nil
+}
+
+// netlinkKeepsTable returns whether routes of a given table are reported.
+// Only the main table is reported, as with /proc/net/route, except on
+// Android, which places the routes of each network in a table of its own,
+// leaving the main table nearly empty.
+func netlinkKeepsTable(table uint32) bool {
+	if runtime.GOOS == "android" {
+		return table != syscall.RT_TABLE_LOCAL
+	}
+	return table == syscall.RT_TABLE_MAIN
+}
+
+// netlinkNexthop is a gateway and output interface of a route, of which
+// multipath routes carry several.
+type netlinkNexthop struct {
+	gateway netip.Addr
+	ifindex int32
+}
+
+// parseNetlinkRoute converts an RTM_NEWROUTE message into one
+// route per nexthop, resolving interface names through names, which caches
+// them by index. Returns a reason when the message is malformed; routes of
+// unreported tables yield neither routes nor a reason.
+func parseNetlinkRoute(m *syscall.NetlinkMessage, names map[int32]string) (NetRouteList, string) {
+	if len(m.Data) < syscall.SizeofRtMsg {
+		return nil, "truncated message"
+	}
+	msg := (*syscall.RtMsg)(unsafe.Pointer(&m.Data[0]))
+	if msg.Flags&syscall.RTM_F_CLONED != 0 {
+		return nil, ""
+	}
+
+	var kind NetRouteKind
+	var dst netip.Addr
+	switch msg.Family {
+	case syscall.AF_INET:
+		kind, dst = NetRouteKindV4, netip.IPv4Unspecified()
+	case syscall.AF_INET6:
+		kind, dst = NetRouteKindV6, netip.IPv6Unspecified()
+	default:
+		return nil, ""
+	}
+
+	attrs, err := syscall.ParseNetlinkRouteAttr(m)
+	if err != nil {
+		return nil, "malformed attributes"
+	}
+
+	table := uint32(msg.Table)
+	hop := netlinkNexthop{}
+	var hops []netlinkNexthop
+	routeAttrs := map[string]string{
+		"DestinationPrefix": strconv.Itoa(int(msg.Dst_len)),
+		"Protocol":          strconv.Itoa(int(msg.Protocol)),
+		"Metric":            "0",
+	}
+	if msg.Tos != 0 {
+		routeAttrs["TOS"] = strconv.Itoa(int(msg.Tos))
+	}
+	for _, a := range attrs {
+		switch a.Attr.Type {
+		case syscall.RTA_DST:
+			addr, ok := netip.AddrFromSlice(a.Value)
+			if !ok {
+				return nil, "malformed destination"
+			}
+			dst = addr
+		case syscall.RTA_GATEWAY:
+			addr, ok := netip.AddrFromSlice(a.Value)
+			if !ok {
+				return nil, "malformed gateway"
+			}
+			hop.gateway = addr
+		case rtaVia:
+			addr, ok := parseRtVia(a.Value)
+			if !ok {
+				return nil, "malformed gateway"
+			}
+			hop.gateway = addr
+		case syscall.RTA_OIF:
+			if len(a.Value) < 4 {
+				return nil, "malformed interface"
+			}
+			hop.ifindex = int32(binary.NativeEndian.Uint32(a.Value))
+		case syscall.RTA_PRIORITY:
+			if len(a.Value) < 4 {
+				return nil, "malformed metric"
+			}
+			routeAttrs["Metric"] = strconv.FormatUint(uint64(binary.NativeEndian.Uint32(a.Value)), 10)
+		case syscall.RTA_PREFSRC:
+			if addr, ok := netip.AddrFromSlice(a.Value); ok {
+				routeAttrs["PrefSrc"] = addr.String()
+			}
+		case syscall.RTA_FLOW:
+			if len(a.Value) < 4 {
+				return nil, "malformed realms"
+			}
+			routeAttrs["Realms"] = formatRealms(binary.NativeEndian.Uint32(a.Value))
+		case syscall.RTA_CACHEINFO:
+			lastUse, expires, ok := parseRtCacheInfo(a.Value)
+			if !ok {
+				return nil, "malformed cache info"
+			}
+			if lastUse > 0 {
+				routeAttrs["LastUse"] = strconv.FormatUint(uint64(lastUse), 10)
+			}
+			if expires > 0 {
+				routeAttrs["Expire"] = strconv.FormatInt(int64(expires), 10)
+			}
+		case syscall.RTA_METRICS:
+			if mtu, ok := parseRtMTU(a.Value); ok {
+				routeAttrs["MTU"] = strconv.FormatUint(uint64(mtu), 10)
+			}
+		case rtaTable:
+			if len(a.Value) < 4 {
+				return nil, "malformed table"
+			}
+			table = binary.NativeEndian.Uint32(a.Value)
+		case syscall.RTA_MULTIPATH:
+			var ok bool
+			if hops, ok = parseRtNexthops(a.Value); !ok {
+				return nil, "malformed multipath nexthops"
+			}
+		}
+	}
+	if !netlinkKeepsTable(table) {
+		return nil, ""
+	}
+	routeAttrs["Table"] = strconv.FormatUint(uint64(table), 10)
+	if len(hops) == 0 {
+		hops = []netlinkNexthop{hop}
+	}
+
+	flags := netlinkRouteFlags(msg, dst)
+	var routes NetRouteList
+	for _, h := range hops {
+		if h.ifindex == 0 {
+			return nil, "no output interface"
+		}
+		name, ok := names[h.ifindex]
+		if !ok {
+			if name, ok = interfaceName(h.ifindex); !ok {
+				return nil, fmt.Sprintf("interface %d not found", h.ifindex)
+			}
+			names[h.ifindex] = name
+		}
+
+		hopFlags := flags
+		gateway := h.gateway
+		if gateway.IsValid() {
+			hopFlags |= rtfGateway
+		} else if kind == NetRouteKindV4 {
+			gateway = netip.IPv4Unspecified()
+		} else {
+			gateway = netip.IPv6Unspecified()
+		}
+
+		a := make(map[string]string, len(routeAttrs))
+		for k, v := range routeAttrs {
+			a[k] = v
+		}
+		routes = append(routes, NetRoute{
+			Kind:        kind,
+			Destination: withLinkZone(dst, name),
+			Flags:       hopFlags.String(),
+			FlagBits:    linuxRouteFlags(hopFlags),
+			Netif:       name,
+			Gateway:     withLinkZone(gateway, name),
+			Attrs:       a,
+		})
+	}
+	return routes, ""
+}
+
+// netlinkRouteFlags expresses the properties of a route message through the
+// flags /proc/net/route and /proc/net/ipv6_route report, so routes read
+// through either backend look alike.
+func netlinkRouteFlags(msg *syscall.RtMsg, dst netip.Addr) routeTableFlag {
+	var flags routeTableFlag
+	switch msg.Type {
+	case syscall.RTN_UNREACHABLE, syscall.RTN_PROHIBIT, syscall.RTN_BLACKHOLE, syscall.RTN_THROW:
+		flags |= rtfReject
+	default:
+		flags |= rtfUp
+	}
+	if msg.Type == syscall.RTN_LOCAL {
+		flags |= rtfLocal
+	}
+	if int(msg.Dst_len) == dst.BitLen() {
+		flags |= rtfHost
+	}
+	switch msg.Protocol {
+	case syscall.RTPROT_REDIRECT:
+		flags |= rtfDynamic
+	case rtProtRA:
+		flags |= rtfAddrConf
+		if msg.Dst_len == 0 {
+			flags |= rtfDefault
+		}
+	}
+	return flags
+}
+
+// parseRtVia parses an RTA_VIA attribute, holding an address family followed
+// by an address, as used by IPv4 routes through IPv6 gateways.
+func parseRtVia(b []byte) (netip.Addr, bool) {
+	if len(b) < 2 {
+		return netip.Addr{}, false
+	}
+	return netip.AddrFromSlice(b[2:])
+}
+
+// parseRtNexthops parses an RTA_MULTIPATH attribute into its nexthops, each
+// an rtnexthop structure followed by its own attributes.
+func parseRtNexthops(b []byte) ([]netlinkNexthop, bool) {
+	var hops []netlinkNexthop
+	for len(b) >= syscall.SizeofRtNexthop {
+		l := int(binary.NativeEndian.Uint16(b[0:2]))
+		if l < syscall.SizeofRtNexthop || l > len(b) {
+			return nil, false
+		}
+		hop := netlinkNexthop{ifindex: int32(binary.NativeEndian.Uint32(b[4:8]))}
+		rest := b[syscall.SizeofRtNexthop:l]
+		for len(rest) >= syscall.SizeofRtAttr {
+			al := int(binary.NativeEndian.Uint16(rest[0:2]))
+			if al < syscall.SizeofRtAttr || al > len(rest) {
+				return nil, false
+			}
+			value := rest[syscall.SizeofRtAttr:al]
+			switch binary.NativeEndian.Uint16(rest[2:4]) {
+			case syscall.RTA_GATEWAY:
+				if addr, ok := netip.AddrFromSlice(value); ok {
+					hop.gateway = addr
+				}
+			case rtaVia:
+				if addr, ok := parseRtVia(value); ok {
+					hop.gateway = addr
+				}
+			}
+			rest = rest[min(rtaAlign(al), len(rest)):]
+		}
+		hops = append(hops, hop)
+		b = b[min(rtaAlign(l), len(b)):]
+	}
+	return hops, true
+}
+
+// formatRealms formats the realms held by an RTA_FLOW attribute as ip does,
+// as the destination realm, preceded by the source realm and a slash when
+// set, such as "2/10".
+func formatRealms(flow uint32) string {
+	to := strconv.FormatUint(uint64(flow&0xffff), 10)
+	if from := flow >> 16; from != 0 {
+		return strconv.FormatUint(uint64(from), 10) + "/" + to
+	}
+	return to
+}
+
+// parseRtCacheInfo returns how many seconds ago the route was last used, and
+// in how many seconds it expires, from an RTA_CACHEINFO attribute, holding a
+// struct rta_cacheinfo whose times are expressed in clock ticks. Either is
+// zero when not applicable, as for routes never looked up through the route
+// cache, or never expiring.
+func parseRtCacheInfo(b []byte) (lastUse uint32, expires int32, ok bool) {
+	// The kernel reports clock ticks at USER_HZ, which is 100 on every
+	// architecture Go supports.
+	const userHZ = 100
+	if len(b) < 12 {
+		return 0, 0, false
+	}
+	lastUse = binary.NativeEndian.Uint32(b[4:]) / userHZ
+	expires = int32(binary.NativeEndian.Uint32(b[8:])) / userHZ
+	return lastUse, expires, true
+}
+
+// parseRtMTU returns the MTU held by an RTA_METRICS attribute, whose value
+// nests an attribute for each metric, and whether one is set.
+func parseRtMTU(b []byte) (uint32, bool) {
+	for len(b) >= syscall.SizeofRtAttr {
+		l := int(binary.NativeEndian.Uint16(b[0:2]))
+		if l < syscall.SizeofRtAttr || l > len(b) {
+			return 0, false
+		}
+		if binary.NativeEndian.Uint16(b[2:4]) == syscall.RTAX_MTU && l >= syscall.SizeofRtAttr+4 {
+			return binary.NativeEndian.Uint32(b[syscall.SizeofRtAttr:]), true
+		}
+		b = b[min(rtaAlign(l), len(b)):]
+	}
+	return 0, false
+}
+
+// rtaAlign rounds l up to the alignment of netlink route attributes.
+func rtaAlign(l int) int {
+	return (l + syscall.RTA_ALIGNTO - 1) &^ (syscall.RTA_ALIGNTO - 1)
+}
+
+// interfaceName returns the name of the interface with a given index through
+// SIOCGIFNAME, which, unlike the RTM_GETLINK dump used by
+// net.InterfaceByIndex, remains permitted on Android.
+func interfaceName(index int32) (string, bool) {
+	s, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_DGRAM|syscall.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return "", false
+	}
+	defer syscall.Close(s)
+
+	var req struct {
+		name  [syscall.IFNAMSIZ]byte
+		index int32
+		_     [20]byte
+	}
+	req.index = index
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(s), syscall.SIOCGIFNAME, uintptr(unsafe.Pointer(&req))); errno != 0 {
+		return "", false
+	}
+	return cString(req.name[:]), true
+}
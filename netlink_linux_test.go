@@ -0,0 +1,87 @@
+package defip
+
+import (
+	"encoding/binary"
+	"syscall"
+	"testing"
+	"unsafe"
+)
+
+// netlinkAttr encodes a route attribute of type typ holding value.
+func netlinkAttr(typ uint16, value []byte) []byte {
+	b := make([]byte, syscall.SizeofRtAttr, rtaAlign(syscall.SizeofRtAttr+len(value)))
+	binary.NativeEndian.PutUint16(b[0:], uint16(syscall.SizeofRtAttr+len(value)))
+	binary.NativeEndian.PutUint16(b[2:], typ)
+	b = append(b, value...)
+	return b[:cap(b)]
+}
+
+// netlinkUint32 encodes v as the value of a route attribute.
+func netlinkUint32(v uint32) []byte {
+	return binary.NativeEndian.AppendUint32(nil, v)
+}
+
+// netlinkRouteMessage returns an RTM_NEWROUTE message for an IPv4 default
+// route of the main table through 192.0.2.1 on interface 1, followed by
+// attrs.
+func netlinkRouteMessage(msg syscall.RtMsg, attrs ...[]byte) *syscall.NetlinkMessage {
+	msg.Family = syscall.AF_INET
+	msg.Table = syscall.RT_TABLE_MAIN
+	msg.Type = syscall.RTN_UNICAST
+	data := append([]byte(nil), unsafe.Slice((*byte)(unsafe.Pointer(&msg)), syscall.SizeofRtMsg)...)
+	data = append(data, netlinkAttr(syscall.RTA_GATEWAY, []byte{192, 0, 2, 1})...)
+	data = append(data, netlinkAttr(syscall.RTA_OIF, netlinkUint32(1))...)
+	for _, a := range attrs {
+		data = append(data, a...)
+	}
+	return &syscall.NetlinkMessage{
+		Header: syscall.NlMsghdr{Len: uint32(syscall.NLMSG_HDRLEN + len(data)), Type: syscall.RTM_NEWROUTE},
+		Data:   data,
+	}
+}
+
+func TestParseNetlinkRouteCacheInfo(t *testing.T) {
+	// struct rta_cacheinfo: clntref, lastuse, expires, error, used, id, ts,
+	// tsage; times in clock ticks.
+	info := make([]byte, 32)
+	binary.NativeEndian.PutUint32(info[4:], 4200)
+	binary.NativeEndian.PutUint32(info[8:], 180000)
+	m := netlinkRouteMessage(syscall.RtMsg{}, netlinkAttr(syscall.RTA_CACHEINFO, info))
+
+	routes, reason := parseNetlinkRoute(m, map[int32]string{1: "sim0"})
+	if reason != "" || len(routes) != 1 {
+		t.Fatalf("got %v (%q), want a single route", routes, reason)
+	}
+	if v := routes[0].Attrs["LastUse"]; v != "42" {
+		t.Errorf("got LastUse %q, want 42", v)
+	}
+	if v := routes[0].Attrs["Expire"]; v != "1800" {
+		t.Errorf("got Expire %q, want 1800", v)
+	}
+	if _, ok := routes[0].Age(); ok {
+		t.Error("netlink routes report no age")
+	}
+}
+
+func TestParseNetlinkRouteTOSRealms(t *testing.T) {
+	m := netlinkRouteMessage(syscall.RtMsg{Tos: 0x10}, netlinkAttr(syscall.RTA_FLOW, netlinkUint32(2<<16|10)))
+	routes, reason := parseNetlinkRoute(m, map[int32]string{1: "sim0"})
+	if reason != "" || len(routes) != 1 {
+		t.Fatalf("got %v (%q), want a single route", routes, reason)
+	}
+	if v := routes[0].Attrs["TOS"]; v != "16" {
+		t.Errorf("got TOS %q, want 16", v)
+	}
+	if v := routes[0].Attrs["Realms"]; v != "2/10" {
+		t.Errorf("got Realms %q, want 2/10", v)
+	}
+
+	m = netlinkRouteMessage(syscall.RtMsg{}, netlinkAttr(syscall.RTA_FLOW, netlinkUint32(10)))
+	routes, _ = parseNetlinkRoute(m, map[int32]string{1: "sim0"})
+	if _, ok := routes[0].Attrs["TOS"]; ok {
+		t.Error("got TOS for a route without one")
+	}
+	if v := routes[0].Attrs["Realms"]; v != "10" {
+		t.Errorf("got Realms %q, want 10", v)
+	}
+}
@@ -1,16 +1,20 @@
 package defip
 
 import (
+	"fmt"
+	"net"
 	"net/netip"
+	"slices"
+	"strconv"
 	"strings"
 )
 
+// Keys used to index column positions of a netstat section.
 const (
 	nsDestination = "Destination"
 	nsFlags       = "Flags"
 	nsNetif       = "Netif"
 	nsGateway     = "Gateway"
-	nsInterface   = "Interface"
 )
 
 type netstatParserState int
@@ -22,16 +26,77 @@ const (
 	netstatParserStateInternet4Data
 	netstatParserStateInternet6Header
 	netstatParserStateInternet6Data
+
+	// netstatParserStateSkipSection ignores lines up to the end of the
+	// current section.
+	netstatParserStateSkipSection
 )
 
+func (s netstatParserState) String() string {
+	switch s {
+	case netstatParserStateHeader:
+		return "header"
+	case netstatParserStateInternetHeader:
+		return "section header"
+	case netstatParserStateInternet4Header:
+		return "IPv4 column header"
+	case netstatParserStateInternet4Data:
+		return "IPv4 data"
+	case netstatParserStateInternet6Header:
+		return "IPv6 column header"
+	case netstatParserStateInternet6Data:
+		return "IPv6 data"
+	case netstatParserStateSkipSection:
+		return "skipped section"
+	}
+	return "invalid"
+}
+
+// netstatSource identifies netstat output in raw routes, traces, warnings,
+// and errors.
+const netstatSource = "netstat -rn"
+
 type netstatParser struct {
+	// source identifies the output being parsed in traces, warnings, and
+	// errors; n.source unless parsed through ParseNetstat.
+	source string
+
+	profile    NetstatProfile
 	state      netstatParserState
 	netData    NetRouteList
 	net4Fields map[string]int
 	net6Fields map[string]int
+	net4Header fieldSet
+	net6Header fieldSet
+	log        *parseLog
+	line       int
+
+	// tables counts the sections found so far for each family, identifying
+	// the table rows belong to.
+	tables map[NetRouteKind]int
+
+	// raw holds the line being parsed, before whitespace is trimmed, and
+	// net4Cuts and net6Cuts the offsets at which each section's Gateway and
+	// Flags columns start, for splitFlush.
+	raw      string
+	net4Cuts []int
+	net6Cuts []int
+
+	// pending holds a row that may be continued on the next line, found at
+	// pendingLine. rowLine holds the line the row being parsed starts at.
+	pending     string
+	pendingLine int
+	rowLine     int
+}
+
+func (n *netstatParser) setState(state netstatParserState) {
+	n.log.printf(n.source, "state %s -> %s", n.state, state)
+	n.state = state
 }
 
 func (n *netstatParser) feed(line string) error {
+	n.line++
+	n.raw = strings.TrimRight(line, "\r\n")
 	line = strings.TrimSpace(line)
 
 	switch n.state {
@@ -51,26 +116,31 @@ func (n *netstatParser) feed(line string) error {
 		if err := n.parseInternet6Data(line); err != nil {
 			return err
 		}
+
+	case netstatParserStateSkipSection:
+		if len(line) == 0 {
+			n.setState(netstatParserStateInternetHeader)
+		}
 	}
 
 	return nil
 }
 
-func (n *netstatParser) reset() {
-	n.state = netstatParserStateHeader
-	clear(n.netData)
-	n.netData = n.netData[:0]
-	clear(n.net4Fields)
-	clear(n.net6Fields)
+// skipSection abandons the current section, ignoring lines up to the next
+// blank line. Routes from previous sections are kept.
+func (n *netstatParser) skipSection() {
+	n.setState(netstatParserStateSkipSection)
 }
 
 func (n *netstatParser) parseHeader(line string) error {
-	if strings.ToLower(line) == "routing tables" {
-		n.state = netstatParserStateInternetHeader
+	if strings.EqualFold(line, n.profile.TablesHeader) {
+		n.log.printf(n.source, "matched tables header %q", line)
+		n.setState(netstatParserStateInternetHeader)
 		return nil
 	}
 
-	return &ErrCantParse{}
+	n.log.printf(n.source, "expected tables header %q, found %q", n.profile.TablesHeader, line)
+	return &ErrCantParse{Source: n.source, Line: n.line}
 }
 
 func (n *netstatParser) parseInternetHeader(line string) {
@@ -78,164 +148,531 @@ func (n *netstatParser) parseInternetHeader(line string) {
 		return
 	}
 
-	line = strings.ToLower(line)
-	switch line {
-	case "internet:":
-		n.state = netstatParserStateInternet4Header
-	case "internet6:":
-		n.state = netstatParserStateInternet6Header
+	switch {
+	case strings.EqualFold(line, n.profile.V4Header):
+		n.log.printf(n.source, "matched IPv4 section header %q", line)
+		n.tables[NetRouteKindV4]++
+		if n.net4Header != nil && n.profile.SharedColumnHeader {
+			n.setState(netstatParserStateInternet4Data)
+		} else {
+			n.setState(netstatParserStateInternet4Header)
+		}
+	case strings.EqualFold(line, n.profile.V6Header):
+		n.log.printf(n.source, "matched IPv6 section header %q", line)
+		n.tables[NetRouteKindV6]++
+		if n.net6Header != nil && n.profile.SharedColumnHeader {
+			n.setState(netstatParserStateInternet6Data)
+		} else {
+			n.setState(netstatParserStateInternet6Header)
+		}
+	case n.profile.SharedColumnHeader && n.net4Header == nil:
+		n.parseSharedHeader(line)
 	default:
-		n.reset()
+		n.log.printf(n.source, "unknown section header %q", line)
+		n.skipSection()
 	}
 }
 
-func (n *netstatParser) parseInternetHeader4(line string) {
+// parseSharedHeader parses a column header applying to every section, as
+// described by NetstatProfile.SharedColumnHeader.
+func (n *netstatParser) parseSharedHeader(line string) {
 	fields := fieldSet(strings.Fields(line))
-	if len(fields) < 4 {
-		n.reset()
+	if !n.parseColumns(fields, n.net4Fields) || !n.parseColumns(fields, n.net6Fields) {
+		n.log.printf(n.source, "rejected shared column header %q: missing required columns", line)
+		clear(n.net4Fields)
+		clear(n.net6Fields)
 		return
 	}
 
-	wantedFields := []string{nsDestination, nsGateway, nsFlags}
-	for _, v := range wantedFields {
-		idx := fields.fieldIdx(v)
+	n.log.printf(n.source, "matched shared column header %q", line)
+	n.net4Header, n.net6Header = fields, fields
+	n.net4Cuts = columnOffsets(n.raw, n.net4Fields[nsGateway], n.net4Fields[nsFlags])
+	n.net6Cuts = n.net4Cuts
+}
+
+// parseColumns locates the columns described by the parser's profile in
+// fields, storing their positions in target. Returns false in case a required
+// column is missing.
+func (n *netstatParser) parseColumns(fields fieldSet, target map[string]int) bool {
+	if len(fields) < 4 {
+		return false
+	}
+
+	wantedFields := map[string]string{
+		nsDestination: n.profile.DestinationColumn,
+		nsGateway:     n.profile.GatewayColumn,
+		nsFlags:       n.profile.FlagsColumn,
+	}
+	for key, name := range wantedFields {
+		idx := fields.fieldIdx(name)
+		for i := 0; idx == -1 && key == nsDestination && i < len(n.profile.ExtraDestinationColumns); i++ {
+			idx = fields.fieldIdx(n.profile.ExtraDestinationColumns[i])
+		}
 		if idx == -1 {
-			n.reset()
-			return
+			return false
+		}
+		target[key] = idx
+	}
+
+	for _, name := range n.profile.NetifColumns {
+		if idx := fields.fieldIdx(name); idx != -1 {
+			target[nsNetif] = idx
+			return true
 		}
-		n.net4Fields[v] = idx
 	}
 
-	iface, netif := fields.fieldIdx(nsInterface), fields.fieldIdx(nsNetif)
-	if iface == -1 && netif == -1 {
-		n.reset()
+	return false
+}
+
+// splitFlush returns the line being parsed with a space inserted at each of
+// cuts falling within a token. netstat truncates values too long for their
+// column, such as long IPv6 destinations, leaving them flush against the
+// next column; as columns other than the interface are left-aligned, values
+// are then told apart by where the header places their columns.
+func (n *netstatParser) splitFlush(cuts []int) string {
+	line := n.raw
+	for i := len(cuts) - 1; i >= 0; i-- {
+		c := cuts[i]
+		if c <= 0 || c >= len(line) || line[c-1] == ' ' || line[c] == ' ' {
+			continue
+		}
+		line = line[:c] + " " + line[c:]
+	}
+	n.log.printf(n.source, "split row %q by column offsets: %q", strings.TrimSpace(n.raw), strings.TrimSpace(line))
+	return line
+}
+
+// columnOffsets returns the offsets at which the whitespace-separated words
+// of header at the provided indexes start.
+func columnOffsets(header string, indexes ...int) []int {
+	var starts []int
+	for i := range header {
+		if header[i] != ' ' && (i == 0 || header[i-1] == ' ') {
+			starts = append(starts, i)
+		}
+	}
+
+	var result []int
+	for _, idx := range indexes {
+		if idx < len(starts) {
+			result = append(result, starts[idx])
+		}
+	}
+	slices.Sort(result)
+	return result
+}
+
+// splitRow splits line into fields, keeping interface names containing
+// spaces, such as Windows friendly names, whole. Values of other columns never
+// contain spaces, and columns following the interface (such as Expire) only
+// hold numbers, "!", or "-", or are left empty. netstat right-aligns those
+// values in their columns, leaving at least two blanks before them, whereas
+// words of an interface name are separated by a single space, as in
+// "Ethernet 2". Tokens not claimed by those columns form the interface name.
+func splitRow(line string, header fieldSet, netifIdx int) []string {
+	var fields, gaps []string
+	for rest := line; ; {
+		trimmed := strings.TrimLeft(rest, " \t")
+		if trimmed == "" {
+			break
+		}
+		end := strings.IndexAny(trimmed, " \t")
+		if end == -1 {
+			end = len(trimmed)
+		}
+		gaps = append(gaps, rest[:len(rest)-len(trimmed)])
+		fields = append(fields, trimmed[:end])
+		rest = trimmed[end:]
+	}
+	if len(fields) > len(header) && fields[len(fields)-1] == "=>" {
+		// AIX marks multipath routes with a trailing "=>", outside of any
+		// column.
+		fields = fields[:len(fields)-1]
+	}
+	if len(fields) <= netifIdx+1 {
+		return fields
+	}
+
+	trailing := 0
+	for trailing < len(header)-netifIdx-1 && len(fields)-trailing-1 > netifIdx {
+		i := len(fields) - trailing - 1
+		if !isTrailingValue(fields[i]) || gaps[i] == " " {
+			break
+		}
+		trailing++
+	}
+	end := len(fields) - trailing
+	name := strings.Join(fields[netifIdx:end], " ")
+	return append(append(fields[:netifIdx:netifIdx], name), fields[end:]...)
+}
+
+// isTrailingValue returns whether v may be the value of a column following
+// the interface column.
+func isTrailingValue(v string) bool {
+	if v == "!" || v == "-" {
+		return true
+	}
+	for _, r := range v {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// rowFields splits a data row into fields, returning the row (which may
+// span two lines), its fields, and whether the row is complete. Rows too
+// short to reach the interface column are first split by column offsets (see
+// splitFlush). Should they remain short, they are held, as some netstat
+// variants wrap long IPv6 routes onto a continuation line starting with
+// whitespace; the next line is then joined to them. Rows found incomplete
+// are recorded as skipped.
+func (n *netstatParser) rowFields(line string, header fieldSet, columns map[string]int, cuts []int) (string, []string, bool) {
+	n.rowLine = n.line
+	joined := false
+	if n.pending != "" {
+		if n.raw != "" && (n.raw[0] == ' ' || n.raw[0] == '\t') {
+			n.log.printf(n.source, "joined continuation line %q", line)
+			line = n.pending + " " + line
+			n.rowLine = n.pendingLine
+			n.pending = ""
+			joined = true
+		} else {
+			n.finish()
+		}
+	}
+
+	netifIdx := columns[nsNetif]
+	fields := splitRow(line, header, netifIdx)
+	if len(fields) == netifIdx && netifIdx == len(header)-1 && n.profile.BlankNetif {
+		n.log.printf(n.source, "row %q leaves the interface column blank", line)
+		fields = append(fields, "")
+	}
+	if len(fields) <= netifIdx && !joined {
+		fields = splitRow(n.splitFlush(cuts), header, netifIdx)
+		if len(fields) <= netifIdx {
+			n.log.printf(n.source, "holding row %q, which may be continued on the next line", line)
+			n.pending, n.pendingLine = line, n.line
+			return line, nil, false
+		}
+	}
+
+	for _, idx := range columns {
+		if idx >= len(fields) {
+			n.log.skip(n.source, n.rowLine, line, fmt.Sprintf("expected at least %d fields, found %d", idx+1, len(fields)))
+			return line, nil, false
+		}
+	}
+	return line, fields, true
+}
+
+// isRule returns whether line only consists of dashes ruling off a column
+// header, as Solaris prints.
+func isRule(line string) bool {
+	return strings.Trim(line, "- ") == "" && strings.Contains(line, "-")
+}
+
+// rowAttrs returns the attributes of a row of a given kind, adding the
+// Table attribute. Tables are numbered from 1 in the order their sections
+// appear.
+func (n *netstatParser) rowAttrs(kind NetRouteKind, header fieldSet, fields []string) map[string]string {
+	attrs := header.attrs(fields, n.profile.columns()...)
+	attrs["Table"] = strconv.Itoa(n.tables[kind])
+	return attrs
+}
+
+// finish records a row held by rowFields as skipped, as it was not continued.
+// Must be called once all lines are fed.
+func (n *netstatParser) finish() {
+	if n.pending == "" {
 		return
 	}
+	n.log.skip(n.source, n.pendingLine, n.pending, "too few fields, and not continued on the next line")
+	n.pending = ""
+}
 
-	if iface > 0 {
-		// NetBSD
-		n.net4Fields[nsNetif] = iface
-	} else {
-		// Other BSD (Solaris, Darwin...)
-		n.net4Fields[nsNetif] = netif
+func (n *netstatParser) parseInternetHeader4(line string) {
+	fields := fieldSet(strings.Fields(line))
+	if !n.parseColumns(fields, n.net4Fields) {
+		n.log.printf(n.source, "rejected IPv4 column header %q: missing required columns", line)
+		clear(n.net4Fields)
+		n.skipSection()
+		return
 	}
 
-	n.state = netstatParserStateInternet4Data
+	n.log.printf(n.source, "matched IPv4 column header %q", line)
+	n.net4Header = fields
+	n.net4Cuts = columnOffsets(n.raw, n.net4Fields[nsGateway], n.net4Fields[nsFlags])
+	n.setState(netstatParserStateInternet4Data)
 }
 
 func (n *netstatParser) parseInternet4Data(line string) {
 	if len(line) == 0 {
-		n.state = netstatParserStateInternetHeader
+		n.finish()
+		n.setState(netstatParserStateInternetHeader)
 		return
 	}
 
-	fields := strings.Fields(line)
-	if strings.ContainsAny(fields[n.net4Fields[nsGateway]], "#:") {
-		// This is some link-level address. Just ignore it as we don't want to
-		// route through it anyway.
+	if isRule(line) {
 		return
 	}
-
-	if fields[n.net4Fields[nsDestination]] == "default" {
-		fields[n.net4Fields[nsDestination]] = "0.0.0.0"
+	line, fields, ok := n.rowFields(line, n.net4Header, n.net4Fields, n.net4Cuts)
+	if !ok {
+		return
 	}
-	dstIp, err := netip.ParseAddr(fields[n.net4Fields[nsDestination]])
+	netif := fields[n.net4Fields[nsNetif]]
+	gateway := fields[n.net4Fields[nsGateway]]
+	attrs := n.rowAttrs(NetRouteKindV4, n.net4Header, fields)
+	flags := n.profile.translateFlags(fields[n.net4Fields[nsFlags]])
+	dstIp, bits, err := n.parseDestination4(fields[n.net4Fields[nsDestination]], bsdRouteFlags(flags), attrs)
 	if err != nil {
+		n.log.skip(n.source, n.rowLine, line, err.Error())
 		return
 	}
 
-	gatewayIp, err := netip.ParseAddr(fields[n.net4Fields[nsGateway]])
+	gatewayIp, gatewayHw, err := parseGateway(gateway, netif, attrs)
 	if err != nil {
+		n.log.skip(n.source, n.rowLine, line, err.Error())
 		return
 	}
 
 	n.netData = append(n.netData, NetRoute{
 		Kind:        NetRouteKindV4,
 		Destination: dstIp,
-		Flags:       fields[n.net4Fields[nsFlags]],
-		Netif:       fields[n.net4Fields[nsNetif]],
+		Flags:       flags,
+		FlagBits:    bsdRouteFlags(flags),
+		Netif:       netif,
 		Gateway:     gatewayIp,
+		Attrs:       withPrefixAttr(attrs, bits),
+
+		GatewayHardware: gatewayHw,
 	})
+	n.log.printf(n.source, "accepted row %q: %s", line, n.netData[len(n.netData)-1])
 }
 
 func (n *netstatParser) parseInternetHeader6(line string) {
 	fields := fieldSet(strings.Fields(line))
-	if len(fields) < 4 {
-		n.reset()
-		return
-	}
-
-	wantedFields := []string{nsDestination, nsGateway, nsFlags}
-	for _, v := range wantedFields {
-		idx := fields.fieldIdx(v)
-		if idx == -1 {
-			n.reset()
-			return
-		}
-		n.net6Fields[v] = idx
-	}
-
-	iface, netif := fields.fieldIdx(nsInterface), fields.fieldIdx(nsNetif)
-	if iface == -1 && netif == -1 {
-		n.reset()
+	if !n.parseColumns(fields, n.net6Fields) {
+		n.log.printf(n.source, "rejected IPv6 column header %q: missing required columns", line)
+		clear(n.net6Fields)
+		n.skipSection()
 		return
 	}
 
-	if iface > 0 {
-		// NetBSD
-		n.net6Fields[nsNetif] = iface
-	} else {
-		// Other BSD (Solaris, Darwin...)
-		n.net6Fields[nsNetif] = netif
-	}
-
-	n.state = netstatParserStateInternet6Data
+	n.log.printf(n.source, "matched IPv6 column header %q", line)
+	n.net6Header = fields
+	n.net6Cuts = columnOffsets(n.raw, n.net6Fields[nsGateway], n.net6Fields[nsFlags])
+	n.setState(netstatParserStateInternet6Data)
 }
 
 func (n *netstatParser) parseInternet6Data(line string) error {
 	if len(line) == 0 {
-		n.state = netstatParserStateInternetHeader
+		n.finish()
+		n.setState(netstatParserStateInternetHeader)
 		return nil
 	}
 
-	fields := strings.Fields(line)
-
-	// Some normalizations and filters...
-	if strings.ContainsRune(fields[n.net6Fields[nsGateway]], '#') {
-		// This is some link-level address. Just ignore it as we don't want to
-		// route through it anyway.
+	if isRule(line) {
 		return nil
 	}
-
-	if fields[n.net6Fields[nsDestination]] == "default" {
-		fields[n.net6Fields[nsDestination]] = "::"
-	}
-
-	if strings.ContainsRune(fields[n.net6Fields[nsDestination]], '/') {
-		fields[n.net6Fields[nsDestination]] = strings.Split(fields[n.net6Fields[nsDestination]], "/")[0]
+	line, fields, ok := n.rowFields(line, n.net6Header, n.net6Fields, n.net6Cuts)
+	if !ok {
+		return nil
 	}
 
-	// The parsing itself
-	dstIp, err := netip.ParseAddr(fields[n.net6Fields[nsDestination]])
+	netif := fields[n.net6Fields[nsNetif]]
+	attrs := n.rowAttrs(NetRouteKindV6, n.net6Header, fields)
+	dstIp, bits, err := n.parseDestination6(fields[n.net6Fields[nsDestination]])
 	if err != nil {
-		return err
+		n.log.skip(n.source, n.rowLine, line, err.Error())
+		return nil
 	}
 
-	gatewayIp, err := netip.ParseAddr(fields[n.net6Fields[nsGateway]])
+	gatewayIp, gatewayHw, err := parseGateway(fields[n.net6Fields[nsGateway]], netif, attrs)
 	if err != nil {
+		n.log.skip(n.source, n.rowLine, line, err.Error())
 		return nil
 	}
 
+	flags := n.profile.translateFlags(fields[n.net6Fields[nsFlags]])
 	n.netData = append(n.netData, NetRoute{
 		Kind:        NetRouteKindV6,
-		Destination: dstIp,
-		Flags:       fields[n.net6Fields[nsFlags]],
-		Netif:       fields[n.net6Fields[nsNetif]],
-		Gateway:     gatewayIp,
+		Destination: withLinkZone(dstIp, netif),
+		Flags:       flags,
+		FlagBits:    bsdRouteFlags(flags),
+		Netif:       netif,
+		Gateway:     withLinkZone(gatewayIp, netif),
+		Attrs:       withPrefixAttr(attrs, bits),
+
+		GatewayHardware: gatewayHw,
 	})
+	n.log.printf(n.source, "accepted row %q: %s", line, n.netData[len(n.netData)-1])
 	return nil
 }
 
+// parseScopedAddr parses an address optionally followed by a prefix length,
+// such as "fe80::%lo0/64", returning the address along with its zone, and the
+// prefix length, or -1 when absent. netip.ParsePrefix rejects zones, so both
+// parts are parsed separately. Darwin truncates long destinations into the
+// gateway column, leaving the start of the gateway after the slash instead of
+// a prefix length, as in "2001:db8::1%en0/fe80::1%en0"; such prefixes, along
+// with empty ones, are unknown, and reported as -1.
+func parseScopedAddr(s string) (netip.Addr, int, error) {
+	addr, bits, found := strings.Cut(s, "/")
+	ip, err := netip.ParseAddr(addr)
+	if err != nil {
+		return netip.Addr{}, 0, err
+	}
+	if !found {
+		return ip, -1, nil
+	}
+	l, err := strconv.Atoi(bits)
+	if err != nil {
+		return ip, -1, nil
+	}
+	if l < 0 || l > ip.BitLen() {
+		return netip.Addr{}, 0, fmt.Errorf("invalid prefix length in `%s'", s)
+	}
+	return ip, l, nil
+}
+
+// isDefaultKeyword returns whether dst denotes the default route by keyword,
+// as printed by BSD systems and Solaris in each of its tables.
+func (n *netstatParser) isDefaultKeyword(dst string) bool {
+	return strings.EqualFold(dst, n.profile.DefaultKeyword) || strings.EqualFold(dst, "default")
+}
+
+// parseDestination4 parses an IPv4 destination, returning it along with its
+// prefix length. Besides the default keyword and explicit prefixes, BSD
+// systems abbreviate networks by omitting trailing zero octets covered by
+// their mask, such as "10.8.0/24" or "127" for 127.0.0.0/8, in which case
+// each octet present accounts for 8 bits. Destinations printed in full
+// without a prefix take it from a mask column when present. Otherwise, 0.0.0.0
+// denotes the default route, and routes flagged as host routes have a /32
+// prefix; the prefix length of other routes, which Solaris prints in full, is
+// unknown, and reported as -1.
+func (n *netstatParser) parseDestination4(dst string, flags RouteFlag, attrs map[string]string) (netip.Addr, int, error) {
+	if n.isDefaultKeyword(dst) {
+		return netip.IPv4Unspecified(), 0, nil
+	}
+
+	addr, bits, found := strings.Cut(dst, "/")
+	octets := strings.Split(addr, ".")
+	if len(octets) > 4 {
+		return netip.Addr{}, 0, fmt.Errorf("invalid IPv4 destination `%s'", dst)
+	}
+	full := len(octets) == 4
+	for len(octets) < 4 {
+		octets = append(octets, "0")
+	}
+	ip, err := netip.ParseAddr(strings.Join(octets, "."))
+	if err != nil {
+		return netip.Addr{}, 0, err
+	}
+
+	switch {
+	case found:
+		l, err := strconv.Atoi(bits)
+		if err != nil || l < 0 || l > 32 {
+			return netip.Addr{}, 0, fmt.Errorf("invalid prefix length in `%s'", dst)
+		}
+		return ip, l, nil
+	case !full:
+		return ip, 8*strings.Count(addr, ".") + 8, nil
+	}
+	for _, key := range maskAttrs {
+		if v, ok := attrs[key]; ok {
+			if l, ok := maskLen(v); ok {
+				return ip, l, nil
+			}
+		}
+	}
+	switch {
+	case ip.IsUnspecified():
+		return ip, 0, nil
+	case flags&RouteFlagHost != 0:
+		return ip, 32, nil
+	}
+	return ip, -1, nil
+}
+
+// parseDestination6 parses an IPv6 destination, returning it along with its
+// prefix length. netstat omits the prefix length of host routes, except for
+// ::, which denotes the default route.
+func (n *netstatParser) parseDestination6(dst string) (netip.Addr, int, error) {
+	if n.isDefaultKeyword(dst) {
+		return netip.IPv6Unspecified(), 0, nil
+	}
+	ip, bits, err := parseScopedAddr(dst)
+	if err != nil {
+		return netip.Addr{}, 0, err
+	}
+	switch {
+	case bits != -1:
+		return ip, bits, nil
+	case ip.IsUnspecified():
+		return ip, 0, nil
+	}
+	return ip, 128, nil
+}
+
+// parseGateway parses the gateway of a route through netif. Some tables name
+// a link instead of an address for routes reached directly through their
+// interface, printing either `link#N' or the interface name itself. These
+// are on-link routes, which may carry the actual default route, such as on
+// cloud VMs: the zero Addr is returned for them, and the link is recorded as
+// the GatewayLink attribute of attrs. Rows for directly attached neighbors
+// print their hardware address instead, which is returned along with the
+// zero Addr.
+func parseGateway(gateway, netif string, attrs map[string]string) (netip.Addr, net.HardwareAddr, error) {
+	if strings.HasPrefix(gateway, "link#") || gateway == netif {
+		attrs["GatewayLink"] = gateway
+		return netip.Addr{}, nil, nil
+	}
+	// Addresses are tried first, as IPv6 addresses printed in full, with
+	// groups of up to two digits, also read as 8-byte link addresses.
+	ip, err := netip.ParseAddr(gateway)
+	if err == nil {
+		return ip, nil, nil
+	}
+	if hw, ok := parseLinkAddr(gateway); ok {
+		return netip.Addr{}, hw, nil
+	}
+	return netip.Addr{}, nil, err
+}
+
+// parseLinkAddr parses a hardware address as printed by netstat, whose
+// octets omit leading zeros (e.g. "0:1c:42:0:0:18"), which net.ParseMAC
+// rejects.
+func parseLinkAddr(s string) (net.HardwareAddr, bool) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 6 && len(parts) != 8 && len(parts) != 20 {
+		return nil, false
+	}
+	hw := make(net.HardwareAddr, len(parts))
+	for i, v := range parts {
+		if len(v) == 0 || len(v) > 2 {
+			return nil, false
+		}
+		b, err := strconv.ParseUint(v, 16, 8)
+		if err != nil {
+			return nil, false
+		}
+		hw[i] = byte(b)
+	}
+	return hw, true
+}
+
+// withPrefixAttr records bits as the DestinationPrefix attribute of attrs,
+// consistently with the Linux backend, unless unknown (-1), and returns
+// attrs.
+func withPrefixAttr(attrs map[string]string, bits int) map[string]string {
+	if bits != -1 {
+		attrs["DestinationPrefix"] = strconv.Itoa(bits)
+	}
+	return attrs
+}
+
 func (n *netstatParser) result() NetRouteList {
 	newList := make(NetRouteList, len(n.netData))
 	for i, v := range n.netData {
@@ -245,10 +682,21 @@ func (n *netstatParser) result() NetRouteList {
 }
 
 func newNetstatParser() *netstatParser {
+	return newNetstatParserProfile(BSDNetstatProfile)
+}
+
+func newNetstatParserProfile(profile NetstatProfile) *netstatParser {
+	state := netstatParserStateHeader
+	if profile.TablesHeader == "" {
+		state = netstatParserStateInternetHeader
+	}
 	return &netstatParser{
-		state:      netstatParserStateHeader,
+		source:     netstatSource,
+		profile:    profile,
+		state:      state,
 		netData:    nil,
 		net4Fields: map[string]int{},
 		net6Fields: map[string]int{},
+		tables:     map[NetRouteKind]int{},
 	}
 }
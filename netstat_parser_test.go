@@ -0,0 +1,173 @@
+package defip
+
+import (
+	"net"
+	"net/netip"
+	"os"
+	"testing"
+)
+
+// parseFixture parses a netstat fixture according to profile.
+func parseFixture(t *testing.T, name string, profile NetstatProfile) NetRouteList {
+	t.Helper()
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	routes, err := ParseNetstat(f, profile)
+	if err != nil {
+		t.Fatalf("%s: %s", name, err)
+	}
+	return routes
+}
+
+func TestParseGateway(t *testing.T) {
+	tests := []struct {
+		gateway string
+		addr    netip.Addr
+		hw      net.HardwareAddr
+		link    string
+	}{
+		{"192.0.2.1", netip.MustParseAddr("192.0.2.1"), nil, ""},
+		{"fe80::1%en0", netip.MustParseAddr("fe80::1%en0"), nil, ""},
+		{"2001:db8:0:0:0:0:0:1", netip.MustParseAddr("2001:db8::1"), nil, ""},
+		{"fd:0:0:0:0:0:0:1", netip.MustParseAddr("fd::1"), nil, ""},
+		{"0:1c:42:0:0:18", netip.Addr{}, net.HardwareAddr{0, 0x1c, 0x42, 0, 0, 0x18}, ""},
+		{"link#4", netip.Addr{}, nil, "link#4"},
+		{"en0", netip.Addr{}, nil, "en0"},
+	}
+	for _, tt := range tests {
+		attrs := map[string]string{}
+		addr, hw, err := parseGateway(tt.gateway, "en0", attrs)
+		if err != nil {
+			t.Errorf("%s: %s", tt.gateway, err)
+			continue
+		}
+		if addr != tt.addr || hw.String() != tt.hw.String() || attrs["GatewayLink"] != tt.link {
+			t.Errorf("%s: got %s, %q, link %q, want %s, %q, link %q", tt.gateway, addr, hw, attrs["GatewayLink"], tt.addr, tt.hw, tt.link)
+		}
+	}
+	if _, _, err := parseGateway("not-an-address", "en0", map[string]string{}); err == nil {
+		t.Error("got no error for a malformed gateway")
+	}
+}
+
+func TestParseNetstatTruncated(t *testing.T) {
+	routes := parseFixture(t, "fixtures/darwin_netstat_truncated", BSDNetstatProfile)
+	if len(routes) != 8 {
+		t.Fatalf("got %d routes, want 8:\n%v", len(routes), routes)
+	}
+
+	// Destinations running into the gateway column are recovered along
+	// with their gateway.
+	gateway := netip.MustParseAddr("fe80::a691:b1ff:fe2c:3d4e%en0")
+	recovered := 0
+	for _, v := range routes {
+		if v.Kind == NetRouteKindV6 && v.HasAllFlags(RouteFlagHost|RouteFlagGateway) {
+			if v.Gateway != gateway || v.Netif != "en0" {
+				t.Errorf("route %s: got gateway %s on %s, want %s on en0", v, v.Gateway, v.Netif, gateway)
+			}
+			recovered++
+		}
+	}
+	if recovered != 3 {
+		t.Errorf("recovered %d host routes, want 3", recovered)
+	}
+}
+
+func TestParseNetstatSolarisBlankNetif(t *testing.T) {
+	routes := parseFixture(t, "fixtures/solaris_netstat", SolarisNetstatProfile)
+	if len(routes) != 9 {
+		t.Fatalf("got %d routes, want 9:\n%v", len(routes), routes)
+	}
+
+	want := netip.MustParseAddr("192.168.1.1")
+	for _, v := range routes.FindDefaults(NetRouteKindV4) {
+		if v.Gateway == want {
+			if v.Netif != "" {
+				t.Errorf("route %s: got interface %q, want none", v, v.Netif)
+			}
+			return
+		}
+	}
+	t.Errorf("default route through %s not found in:\n%v", want, routes)
+}
+
+func TestParseNetstatNetifSpaces(t *testing.T) {
+	routes := parseFixture(t, "fixtures/netstat_netif_spaces", BSDNetstatProfile)
+	if len(routes) != 12 {
+		t.Fatalf("got %d routes, want 12:\n%v", len(routes), routes)
+	}
+
+	want := []struct {
+		netif, expire string
+	}{
+		{"en0", ""},
+		{"vEthernet (WSL)", ""},
+		{"Ethernet 2", ""},
+		{"Ethernet 2", "!"},
+		{"Ethernet 2", "!"},
+		{"Ethernet 2", "1187"},
+		{"lo0", ""},
+		{"lo0", ""},
+		{"vEthernet (WSL)", "!"},
+		{"vEthernet (WSL)", "1195"},
+		{"en0", "!"},
+		{"en0", "1184"},
+	}
+	for i, v := range routes {
+		if v.Netif != want[i].netif || v.Attrs["Expire"] != want[i].expire {
+			t.Errorf("route %s: got interface %q expiring %q, want %q expiring %q", v, v.Netif, v.Attrs["Expire"], want[i].netif, want[i].expire)
+		}
+	}
+}
+
+func TestParseNetstatUnknownColumns(t *testing.T) {
+	// The IPv6 section lacks the columns the profile expects, and is
+	// skipped without discarding the IPv4 section preceding it.
+	routes := parseFixture(t, "fixtures/netstat_unknown_columns", BSDNetstatProfile)
+	if len(routes) != 4 {
+		t.Fatalf("got %d routes, want 4:\n%v", len(routes), routes)
+	}
+	for _, v := range routes {
+		if v.Kind != NetRouteKindV4 {
+			t.Errorf("route %s parsed from a skipped section", v)
+		}
+	}
+	if defaults := routes.FindDefaults(NetRouteKindV4); len(defaults) != 1 || defaults[0].Gateway != netip.MustParseAddr("192.168.1.1") {
+		t.Errorf("got IPv4 defaults %v, want one via 192.168.1.1", defaults)
+	}
+}
+
+func TestParseNetstatFreeBSD(t *testing.T) {
+	routes := parseFixture(t, "fixtures/freebsd_netstat", netstatProfileFor("freebsd", "14.1-RELEASE"))
+	if len(routes) != 15 {
+		t.Fatalf("got %d routes, want 15:\n%v", len(routes), routes)
+	}
+
+	tests := []struct {
+		kind    NetRouteKind
+		gateway netip.Addr
+	}{
+		{NetRouteKindV4, netip.MustParseAddr("192.168.122.1")},
+		{NetRouteKindV6, netip.MustParseAddr("fe80::5054:ff:fe12:3456%vtnet0")},
+	}
+	for _, tt := range tests {
+		defaults := routes.FindDefaults(tt.kind)
+		if len(defaults) != 1 {
+			t.Errorf("%s: got defaults %v, want one", tt.kind, defaults)
+			continue
+		}
+		if d := defaults[0]; d.Gateway != tt.gateway || d.Netif != "vtnet0" || !d.HasAllFlags(RouteFlagUp|RouteFlagGateway|RouteFlagStatic) {
+			t.Errorf("%s: got default %s, want one via %s on vtnet0", tt.kind, d, tt.gateway)
+		}
+	}
+
+	// Loopback host routes are listed through link#N gateways.
+	for _, v := range routes {
+		if v.Netif == "lo0" && v.Gateway.IsValid() && !v.Gateway.IsLoopback() {
+			t.Errorf("route %s: got gateway %s on lo0", v, v.Gateway)
+		}
+	}
+}
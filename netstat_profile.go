@@ -0,0 +1,200 @@
+package defip
+
+import (
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// NetstatProfile describes the layout of a BSD-like `netstat -rn' output,
+// allowing new variants to be supported by registering a profile through
+// RegisterNetstatProfile instead of new parser code.
+type NetstatProfile struct {
+	// TablesHeader is the line introducing the routing tables. Compared
+	// case-insensitively. When empty, output starts directly with a section
+	// header.
+	TablesHeader string
+
+	// V4Header and V6Header are the lines introducing the IPv4 and IPv6
+	// sections, respectively. Compared case-insensitively. Several sections
+	// of the same family may follow each other, one per routing table; see
+	// the Table attribute of routes.
+	V4Header string
+	V6Header string
+
+	// SharedColumnHeader indicates that a single column header, following
+	// TablesHeader, applies to every section, instead of each section
+	// having its own.
+	SharedColumnHeader bool
+
+	// DestinationColumn, GatewayColumn, and FlagsColumn are the names of the
+	// columns holding the route's destination, gateway, and flags.
+	DestinationColumn string
+	GatewayColumn     string
+	FlagsColumn       string
+
+	// ExtraDestinationColumns lists other names the destination column may
+	// have in some sections, such as "Destination/Mask".
+	ExtraDestinationColumns []string
+
+	// NetifColumns lists the names of columns that may hold the route's
+	// interface, in order of preference.
+	NetifColumns []string
+
+	// DefaultKeyword is the value used in the destination column to indicate
+	// the default route.
+	DefaultKeyword string
+
+	// BlankNetif indicates that rows may leave the interface column blank
+	// when it is the last one, as Solaris does for routes not bound to an
+	// interface, such as defaults added without -ifp. Such rows are
+	// complete, instead of being held as wrapped rows, and their routes have
+	// no Netif.
+	BlankNetif bool
+
+	// FlagAlphabet translates flag letters used by the platform into the
+	// letters used by this package (U for up, G for gateway, H for host, and
+	// so on). Letters absent from the map are kept as-is.
+	FlagAlphabet map[rune]rune
+}
+
+// BSDNetstatProfile covers Darwin, NetBSD, and other BSD-derived systems such
+// as QNX, whose io-pkt stack prints NetBSD-style tables. It is used whenever
+// no other profile matches the running system.
+var BSDNetstatProfile = NetstatProfile{
+	TablesHeader:      "routing tables",
+	V4Header:          "internet:",
+	V6Header:          "internet6:",
+	DestinationColumn: "Destination",
+	GatewayColumn:     "Gateway",
+	FlagsColumn:       "Flags",
+	NetifColumns: []string{
+		"Interface", // NetBSD, QNX
+		"Netif",     // Other BSD (Solaris, Darwin...)
+	},
+	DefaultKeyword: "default",
+}
+
+// OpenBSDNetstatProfile covers OpenBSD, which names the interface column
+// Iface, following the Mtu and Prio columns, and flags cached routes with h.
+var OpenBSDNetstatProfile = NetstatProfile{
+	TablesHeader:      "routing tables",
+	V4Header:          "internet:",
+	V6Header:          "internet6:",
+	DestinationColumn: "Destination",
+	GatewayColumn:     "Gateway",
+	FlagsColumn:       "Flags",
+	NetifColumns:      []string{"Iface"},
+	DefaultKeyword:    "default",
+	FlagAlphabet: map[rune]rune{
+		'h': 'c', // RTF_CACHED
+		'c': 'W', // RTF_CLONED, as Darwin's W
+	},
+}
+
+// AIXNetstatProfile covers AIX, which prints a single column header for all
+// sections, introduced as route trees for each protocol family, names the
+// interface column If, followed by the Exp and Groups columns, and flags
+// cloning routes with c.
+var AIXNetstatProfile = NetstatProfile{
+	TablesHeader:       "routing tables",
+	V4Header:           "route tree for protocol family 2 (internet):",
+	V6Header:           "route tree for protocol family 24 (internet v6):",
+	SharedColumnHeader: true,
+	DestinationColumn:  "Destination",
+	GatewayColumn:      "Gateway",
+	FlagsColumn:        "Flags",
+	NetifColumns:       []string{"If"},
+	DefaultKeyword:     "default",
+	FlagAlphabet: map[rune]rune{
+		'c': 'C', // RTF_CLONING
+	},
+}
+
+// SolarisNetstatProfile covers Solaris and illumos, which print a separate
+// "Routing Table" section per table, without an overall header, and rule
+// their column headers off with dashes.
+var SolarisNetstatProfile = NetstatProfile{
+	V4Header:                "routing table: ipv4",
+	V6Header:                "routing table: ipv6",
+	DestinationColumn:       "Destination",
+	GatewayColumn:           "Gateway",
+	FlagsColumn:             "Flags",
+	ExtraDestinationColumns: []string{"Destination/Mask"},
+	NetifColumns: []string{
+		"Interface", // IPv4
+		"If",        // IPv6
+	},
+	DefaultKeyword: "default",
+	BlankNetif:     true,
+}
+
+// columns returns the names of all columns interpreted by the parser.
+func (p NetstatProfile) columns() []string {
+	columns := []string{p.DestinationColumn, p.GatewayColumn, p.FlagsColumn}
+	columns = append(columns, p.ExtraDestinationColumns...)
+	return append(columns, p.NetifColumns...)
+}
+
+// translateFlags converts flags into this package's alphabet.
+func (p NetstatProfile) translateFlags(flags string) string {
+	if len(p.FlagAlphabet) == 0 {
+		return flags
+	}
+	return strings.Map(func(r rune) rune {
+		if t, ok := p.FlagAlphabet[r]; ok {
+			return t
+		}
+		return r
+	}, flags)
+}
+
+type netstatProfileKey struct {
+	goos    string
+	version string
+}
+
+var (
+	netstatProfilesMu sync.RWMutex
+	netstatProfiles   = map[netstatProfileKey]NetstatProfile{
+		{goos: "aix"}:     AIXNetstatProfile,
+		{goos: "illumos"}: SolarisNetstatProfile,
+		{goos: "openbsd"}: OpenBSDNetstatProfile,
+		{goos: "solaris"}: SolarisNetstatProfile,
+	}
+)
+
+// RegisterNetstatProfile registers a profile to be used when parsing netstat
+// output on a given GOOS. When version is not empty, the profile is only used
+// on operating system versions starting with it (e.g. "14" matches "14.2.1"),
+// taking precedence over profiles registered without a version. Registering a
+// profile for an existing GOOS and version pair replaces it.
+func RegisterNetstatProfile(goos, version string, profile NetstatProfile) {
+	netstatProfilesMu.Lock()
+	defer netstatProfilesMu.Unlock()
+	netstatProfiles[netstatProfileKey{goos, version}] = profile
+}
+
+// netstatProfileFor returns the profile registered for goos that best matches
+// version, falling back to BSDNetstatProfile.
+func netstatProfileFor(goos, version string) NetstatProfile {
+	netstatProfilesMu.RLock()
+	defer netstatProfilesMu.RUnlock()
+
+	best, found := BSDNetstatProfile, false
+	var bestVersion string
+	for k, v := range netstatProfiles {
+		if k.goos != goos || !strings.HasPrefix(version, k.version) {
+			continue
+		}
+		if !found || len(k.version) > len(bestVersion) {
+			best, bestVersion, found = v, k.version, true
+		}
+	}
+	return best
+}
+
+// currentNetstatProfile returns the profile for the running system.
+func currentNetstatProfile() NetstatProfile {
+	return netstatProfileFor(runtime.GOOS, osVersion())
+}
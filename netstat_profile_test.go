@@ -0,0 +1,72 @@
+package defip
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestNetstatProfileFor(t *testing.T) {
+	versioned := BSDNetstatProfile
+	versioned.DefaultKeyword = "versioned"
+	RegisterNetstatProfile("darwin", "14", versioned)
+	t.Cleanup(func() {
+		netstatProfilesMu.Lock()
+		defer netstatProfilesMu.Unlock()
+		delete(netstatProfiles, netstatProfileKey{"darwin", "14"})
+	})
+
+	tests := []struct {
+		goos    string
+		version string
+		want    NetstatProfile
+	}{
+		{"aix", "", AIXNetstatProfile},
+		{"aix", "7.2.0.0", AIXNetstatProfile},
+		{"openbsd", "", OpenBSDNetstatProfile},
+		{"solaris", "11.4.42.111.0", SolarisNetstatProfile},
+		{"illumos", "joyent_20240516T000420Z", SolarisNetstatProfile},
+		{"openbsd", "7.5", OpenBSDNetstatProfile},
+		{"darwin", "", BSDNetstatProfile},
+		{"darwin", "13.6", BSDNetstatProfile},
+		{"darwin", "14.2.1", versioned},
+		{"freebsd", "14.1-RELEASE", BSDNetstatProfile},
+		{"netbsd", "10.0", BSDNetstatProfile},
+		{"dragonfly", "6.4-RELEASE", BSDNetstatProfile},
+		{"ios", "17.4", BSDNetstatProfile},
+	}
+
+	// Map iteration order varies, so each lookup is repeated.
+	for _, tt := range tests {
+		for i := 0; i < 20; i++ {
+			if got := netstatProfileFor(tt.goos, tt.version); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("netstatProfileFor(%q, %q) = %+v, want %+v", tt.goos, tt.version, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestNetstatProfileForFixtures(t *testing.T) {
+	tests := []struct {
+		goos    string
+		fixture string
+	}{
+		{"aix", "fixtures/aix_netstat"},
+		{"openbsd", "fixtures/openbsd_netstat"},
+		{"solaris", "fixtures/solaris_netstat"},
+	}
+	for _, tt := range tests {
+		f, err := os.Open(tt.fixture)
+		if err != nil {
+			t.Fatal(err)
+		}
+		routes, err := ParseNetstat(f, netstatProfileFor(tt.goos, ""))
+		f.Close()
+		if err != nil {
+			t.Fatalf("%s: %s", tt.fixture, err)
+		}
+		if len(routes.FindDefaults(NetRouteKindV4)) == 0 {
+			t.Errorf("%s: no IPv4 default route among %d routes", tt.fixture, len(routes))
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/heyvito/defip/v2"
+)
+
+// ChangeAction reacts to a change of the default IP, such as by updating a
+// dynamic DNS record. Actions are meant to be called with events emitted by
+// Resolver.WatchDefaultIP:
+//
+//	action := notify.WebhookAction("https://example.com/hooks/ip")
+//	for e := range r.WatchDefaultIP(ctx, defip.NetRouteKindV4, defip.WatchConfig{}) {
+//		if err := action(ctx, e); err != nil {
+//			log.Printf("could not notify IP change: %s", err)
+//		}
+//	}
+type ChangeAction func(ctx context.Context, e defip.DefaultIPEvent) error
+
+// changeEvent is the JSON representation of a defip.DefaultIPEvent sent by
+// WebhookAction.
+type changeEvent struct {
+	Kind           string    `json:"kind"`
+	Previous       string    `json:"previous,omitempty"`
+	Current        string    `json:"current,omitempty"`
+	PreviousPrefix string    `json:"previous_prefix,omitempty"`
+	CurrentPrefix  string    `json:"current_prefix,omitempty"`
+	PrefixChanged  bool      `json:"prefix_changed"`
+	Interface      string    `json:"interface,omitempty"`
+	Error          string    `json:"error,omitempty"`
+	At             time.Time `json:"at"`
+}
+
+func changeEventOf(e defip.DefaultIPEvent) changeEvent {
+	c := changeEvent{Kind: e.Kind.String(), PrefixChanged: e.PrefixChanged(), At: e.At}
+	if e.Previous.IsValid() {
+		c.Previous = e.Previous.String()
+	}
+	if e.Current.IsValid() {
+		c.Current = e.Current.String()
+	}
+	if e.PreviousPrefix.IsValid() {
+		c.PreviousPrefix = e.PreviousPrefix.String()
+	}
+	if e.CurrentPrefix.IsValid() {
+		c.CurrentPrefix = e.CurrentPrefix.String()
+	}
+	if e.Selection != nil {
+		c.Interface = e.Selection.Interface.Name
+	}
+	if e.Err != nil {
+		c.Error = e.Err.Error()
+	}
+	return c
+}
+
+// ExecAction returns a ChangeAction running the program name with args,
+// passing the event through the environment variables DEFIP_KIND,
+// DEFIP_PREVIOUS, DEFIP_CURRENT, DEFIP_PREVIOUS_PREFIX, DEFIP_CURRENT_PREFIX,
+// DEFIP_PREFIX_CHANGED ("true" or "false"), DEFIP_INTERFACE, and
+// DEFIP_ERROR, in addition to those of the current process. Variables not
+// applying to the event are set empty. The action fails when the program
+// exits with a non-zero status, in which case its standard error is included
+// in the returned error.
+func ExecAction(name string, args ...string) ChangeAction {
+	return func(ctx context.Context, e defip.DefaultIPEvent) error {
+		c := changeEventOf(e)
+		var stderr bytes.Buffer
+		cmd := exec.CommandContext(ctx, name, args...)
+		cmd.Env = append(os.Environ(),
+			"DEFIP_KIND="+c.Kind,
+			"DEFIP_PREVIOUS="+c.Previous,
+			"DEFIP_CURRENT="+c.Current,
+			"DEFIP_PREVIOUS_PREFIX="+c.PreviousPrefix,
+			"DEFIP_CURRENT_PREFIX="+c.CurrentPrefix,
+			"DEFIP_PREFIX_CHANGED="+strconv.FormatBool(c.PrefixChanged),
+			"DEFIP_INTERFACE="+c.Interface,
+			"DEFIP_ERROR="+c.Error,
+		)
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			if msg := strings.TrimSpace(stderr.String()); msg != "" {
+				return fmt.Errorf("%s: %w: %s", name, err, msg)
+			}
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		return nil
+	}
+}
+
+// WebhookAction returns a ChangeAction POSTing the event to url as a JSON
+// object holding its kind, previous and current addresses and prefixes,
+// whether the prefix changed, interface, error, and time, such as:
+//
+//	{"kind":"IPv4","previous":"192.0.2.10","current":"192.0.2.20","previous_prefix":"192.0.2.0/24","current_prefix":"192.0.2.0/24","prefix_changed":false,"interface":"eth0","at":"2024-05-01T10:00:00Z"}
+//
+// Fields not applying to the event are omitted. The action fails unless the
+// server responds with a 2xx status.
+func WebhookAction(url string) ChangeAction {
+	return func(ctx context.Context, e defip.DefaultIPEvent) error {
+		body, err := json.Marshal(changeEventOf(e))
+		if err != nil {
+			return err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("webhook `%s' responded with %s", url, resp.Status)
+		}
+		return nil
+	}
+}
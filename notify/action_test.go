@@ -0,0 +1,64 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/heyvito/defip/v2"
+)
+
+func testEvent() defip.DefaultIPEvent {
+	return defip.DefaultIPEvent{
+		Kind:           defip.NetRouteKindV4,
+		Previous:       netip.MustParseAddr("192.0.2.10"),
+		Current:        netip.MustParseAddr("192.0.2.20"),
+		PreviousPrefix: netip.MustParsePrefix("192.0.2.0/24"),
+		CurrentPrefix:  netip.MustParsePrefix("192.0.2.0/24"),
+		At:             time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestWebhookAction(t *testing.T) {
+	var got changeEvent
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if err := json.NewDecoder(req.Body).Decode(&got); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer srv.Close()
+
+	if err := WebhookAction(srv.URL)(context.Background(), testEvent()); err != nil {
+		t.Fatal(err)
+	}
+	if want := changeEventOf(testEvent()); got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestWebhookActionStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	if err := WebhookAction(srv.URL)(context.Background(), testEvent()); err == nil {
+		t.Error("got no error for a 502 response")
+	}
+}
+
+func TestExecAction(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+
+	script := `test "$DEFIP_CURRENT" = 192.0.2.20 && test "$DEFIP_PREFIX_CHANGED" = false || { echo "$DEFIP_CURRENT" >&2; exit 1; }`
+	if err := ExecAction("sh", "-c", script)(context.Background(), testEvent()); err != nil {
+		t.Fatal(err)
+	}
+}
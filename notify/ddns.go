@@ -0,0 +1,213 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/heyvito/defip/v2"
+)
+
+// ChangeHandler reacts to changes of the default IP emitted by
+// Resolver.WatchDefaultIP, such as by updating a dynamic DNS record. DynDNS2Handler,
+// DuckDNSHandler, and CloudflareHandler update records with common providers;
+// other providers may be supported by implementing ChangeHandler, or through
+// a ChangeAction.
+type ChangeHandler interface {
+	HandleChange(ctx context.Context, e defip.DefaultIPEvent) error
+}
+
+// HandleChange calls a(ctx, e), making ChangeAction a ChangeHandler.
+func (a ChangeAction) HandleChange(ctx context.Context, e defip.DefaultIPEvent) error {
+	return a(ctx, e)
+}
+
+// ddnsSkips returns whether a dynamic DNS handler has nothing to publish for
+// e: no address could be selected, or it did not change.
+func ddnsSkips(e defip.DefaultIPEvent) bool {
+	return !e.Current.IsValid() || e.Current == e.Previous
+}
+
+// ddnsResponseLimit bounds how much of a provider's response is read.
+const ddnsResponseLimit = 64 << 10
+
+// ddnsDo performs req through client, or http.DefaultClient when nil,
+// returning the response body, and failing unless the response has a 2xx
+// status.
+func ddnsDo(client *http.Client, req *http.Request) ([]byte, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, ddnsResponseLimit))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("%s responded with %s", req.URL.Host, resp.Status)
+	}
+	return body, nil
+}
+
+// DynDNS2Handler updates a record through the DynDNS2 protocol, supported by
+// many providers, such as No-IP (dynupdate.no-ip.com) and Dyn
+// (members.dyndns.org).
+type DynDNS2Handler struct {
+	// Server is the host name of the provider's update server.
+	Server string
+
+	// Hostname is the record to update.
+	Hostname string
+
+	// Username and Password authenticate with the provider.
+	Username string
+	Password string
+
+	// UseSourceAddress makes the provider record the address requests
+	// come from, instead of the selected one, as needed behind NAT.
+	UseSourceAddress bool
+
+	// Client performs requests. Defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+// HandleChange implements ChangeHandler.
+func (h *DynDNS2Handler) HandleChange(ctx context.Context, e defip.DefaultIPEvent) error {
+	if ddnsSkips(e) {
+		return nil
+	}
+
+	query := url.Values{"hostname": {h.Hostname}}
+	if !h.UseSourceAddress {
+		query.Set("myip", e.Current.WithZone("").String())
+	}
+	u := url.URL{Scheme: "https", Host: h.Server, Path: "/nic/update", RawQuery: query.Encode()}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(h.Username, h.Password)
+	body, err := ddnsDo(h.Client, req)
+	if err != nil {
+		return err
+	}
+
+	// Responses start with a return code, possibly followed by the address.
+	code, _, _ := strings.Cut(strings.TrimSpace(string(body)), " ")
+	if code != "good" && code != "nochg" {
+		return fmt.Errorf("%s refused to update `%s': %s", h.Server, h.Hostname, code)
+	}
+	return nil
+}
+
+// DuckDNSHandler updates a Duck DNS (www.duckdns.org) record.
+type DuckDNSHandler struct {
+	// Domain is the subdomain to update, without the duckdns.org suffix.
+	Domain string
+
+	// Token authenticates with Duck DNS.
+	Token string
+
+	// UseSourceAddress makes Duck DNS record the address requests come
+	// from, instead of the selected one, as needed behind NAT. Only applies
+	// to IPv4 addresses.
+	UseSourceAddress bool
+
+	// Client performs requests. Defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+// HandleChange implements ChangeHandler.
+func (h *DuckDNSHandler) HandleChange(ctx context.Context, e defip.DefaultIPEvent) error {
+	if ddnsSkips(e) {
+		return nil
+	}
+
+	query := url.Values{"domains": {h.Domain}, "token": {h.Token}}
+	if e.Current.Is6() {
+		query.Set("ipv6", e.Current.WithZone("").String())
+	} else if !h.UseSourceAddress {
+		query.Set("ip", e.Current.String())
+	}
+	u := url.URL{Scheme: "https", Host: "www.duckdns.org", Path: "/update", RawQuery: query.Encode()}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	body, err := ddnsDo(h.Client, req)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(string(body)) != "OK" {
+		return fmt.Errorf("www.duckdns.org refused to update `%s'", h.Domain)
+	}
+	return nil
+}
+
+// CloudflareHandler updates an existing A or AAAA record hosted by
+// Cloudflare, whose type must match the kind of address watched.
+type CloudflareHandler struct {
+	// ZoneID and RecordID identify the record to update.
+	ZoneID   string
+	RecordID string
+
+	// Token is an API token allowed to edit the zone's DNS records.
+	Token string
+
+	// Client performs requests. Defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+// HandleChange implements ChangeHandler.
+func (h *CloudflareHandler) HandleChange(ctx context.Context, e defip.DefaultIPEvent) error {
+	if ddnsSkips(e) {
+		return nil
+	}
+
+	payload, err := json.Marshal(map[string]string{"content": e.Current.WithZone("").String()})
+	if err != nil {
+		return err
+	}
+	u := url.URL{
+		Scheme: "https",
+		Host:   "api.cloudflare.com",
+		Path:   "/client/v4/zones/" + url.PathEscape(h.ZoneID) + "/dns_records/" + url.PathEscape(h.RecordID),
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPatch, u.String(), bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+h.Token)
+	req.Header.Set("Content-Type", "application/json")
+	body, err := ddnsDo(h.Client, req)
+	if err != nil {
+		return err
+	}
+
+	var result struct {
+		Success bool `json:"success"`
+		Errors  []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err = json.Unmarshal(body, &result); err != nil {
+		return fmt.Errorf("api.cloudflare.com: %w", err)
+	}
+	if !result.Success {
+		var msgs []string
+		for _, v := range result.Errors {
+			msgs = append(msgs, v.Message)
+		}
+		return fmt.Errorf("api.cloudflare.com refused to update record `%s': %s", h.RecordID, strings.Join(msgs, "; "))
+	}
+	return nil
+}
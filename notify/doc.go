@@ -0,0 +1,5 @@
+// Package notify reacts to changes of the default IP emitted by
+// defip.Resolver.WatchDefaultIP, by running programs, calling webhooks, or
+// updating dynamic DNS records. It is kept apart from package defip, so
+// programs only detecting addresses do not depend on net/http.
+package notify
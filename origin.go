@@ -0,0 +1,164 @@
+package defip
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// RouteOrigin indicates how a route was configured.
+type RouteOrigin uint8
+
+const (
+	// RouteOriginUnknown indicates the origin of the route could not be
+	// determined.
+	RouteOriginUnknown RouteOrigin = iota
+
+	// RouteOriginStatic indicates a route configured manually, or by static
+	// network configuration.
+	RouteOriginStatic
+
+	// RouteOriginDHCP indicates a route learned through DHCP.
+	RouteOriginDHCP
+
+	// RouteOriginRA indicates a route learned through IPv6 Router
+	// Advertisements.
+	RouteOriginRA
+
+	// RouteOriginVPN indicates a route installed through a VPN or overlay
+	// network interface.
+	RouteOriginVPN
+)
+
+func (o RouteOrigin) String() string {
+	switch o {
+	case RouteOriginStatic:
+		return "static"
+	case RouteOriginDHCP:
+		return "DHCP"
+	case RouteOriginRA:
+		return "RA"
+	case RouteOriginVPN:
+		return "VPN"
+	}
+	return "unknown"
+}
+
+// vpnInterfaceNames matches names of interfaces created by VPN clients across
+// platforms.
+var vpnInterfaceNames = regexp.MustCompile(`^(tun|tap|wg|utun|ppp|ipsec|gpd|nordlynx|proton)\d*$`)
+
+// networkdLeaseGlob locates lease files written by systemd-networkd, named
+// after the index of their interface.
+const networkdLeaseGlob = "/run/systemd/netif/leases/*"
+
+// DefaultOrigin reports how the default route used by Select was configured,
+// combining the interface it goes through, route flags, and DHCP lease files
+// (dhclient and systemd-networkd, on Linux). IPv6 routes learned through
+// Router Advertisements are told apart by the kernel's route flags, so IPv6
+// routes are always reported as either RA or static. IPv4 routes not found in
+// lease files are reported as RouteOriginUnknown, as DHCP clients keeping
+// leases elsewhere install routes indistinguishable from static ones.
+func (r *Resolver) DefaultOrigin(ctx context.Context, kind NetRouteKind) (RouteOrigin, error) {
+	sel, err := r.Select(ctx, kind)
+	if err != nil {
+		return RouteOriginUnknown, err
+	}
+
+	return routeOrigin(sel.Route, sel.InterfaceInfo), nil
+}
+
+func routeOrigin(route NetRoute, info InterfaceInfo) RouteOrigin {
+	if info.Class == InterfaceClassOverlay || info.Type == "wireguard" || info.Type == "ppp" ||
+		vpnInterfaceNames.MatchString(route.Netif) {
+		return RouteOriginVPN
+	}
+
+	if route.Kind == NetRouteKindV6 {
+		switch {
+		case route.HasAnyFlags(RouteFlagAddrConf | RouteFlagDefault):
+			return RouteOriginRA
+		case route.HasAnyFlags(RouteFlagStatic) || runtime.GOOS == "linux":
+			return RouteOriginStatic
+		}
+		// BSD kernels mark every route not learned from Router
+		// Advertisements as static.
+		return RouteOriginRA
+	}
+
+	for _, gw := range readDHCPRouters()[route.Netif] {
+		if gw == route.Gateway.WithZone("") {
+			return RouteOriginDHCP
+		}
+	}
+	return RouteOriginUnknown
+}
+
+// readDHCPRouters collects routers recorded in DHCP lease files, keyed by
+// interface name.
+func readDHCPRouters() map[string][]netip.Addr {
+	result := map[string][]netip.Addr{}
+
+	// dhclient leases are blocks naming their interface, followed by the
+	// options received.
+	for _, pattern := range dhcpLeaseGlobs {
+		paths, _ := filepath.Glob(pattern)
+		for _, path := range paths {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				continue
+			}
+			netif := ""
+			for _, line := range strings.Split(string(data), "\n") {
+				fields := strings.Fields(strings.TrimSuffix(strings.TrimSpace(line), ";"))
+				switch {
+				case len(fields) == 2 && fields[0] == "interface":
+					netif = strings.Trim(fields[1], `"`)
+				case len(fields) == 3 && fields[0] == "option" && fields[1] == "routers":
+					for _, v := range strings.Split(fields[2], ",") {
+						if addr, err := netip.ParseAddr(v); err == nil {
+							result[netif] = append(result[netif], addr)
+						}
+					}
+				}
+			}
+		}
+	}
+
+	// systemd-networkd leases are key-value files named after the index of
+	// their interface.
+	paths, _ := filepath.Glob(networkdLeaseGlob)
+	for _, path := range paths {
+		idx, err := strconv.Atoi(filepath.Base(path))
+		if err != nil {
+			continue
+		}
+		iface, err := net.InterfaceByIndex(idx)
+		if err != nil {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			value, ok := strings.CutPrefix(strings.TrimSpace(line), "ROUTER=")
+			if !ok {
+				continue
+			}
+			for _, v := range strings.Fields(value) {
+				if addr, err := netip.ParseAddr(v); err == nil {
+					result[iface.Name] = append(result[iface.Name], addr)
+				}
+			}
+		}
+	}
+
+	return result
+}
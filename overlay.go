@@ -0,0 +1,117 @@
+package defip
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"regexp"
+	"runtime"
+)
+
+// OverlayNetwork represents an overlay network, such as Tailscale or ZeroTier,
+// detected on the host.
+type OverlayNetwork struct {
+	// Provider names the overlay software, such as "tailscale" or "zerotier".
+	Provider string
+
+	// Interface is the name of the interface attached to the overlay.
+	Interface string
+
+	// Prefixes lists the addresses configured on Interface, along with their
+	// prefix lengths.
+	Prefixes []netip.Prefix
+}
+
+type overlayProvider struct {
+	name string
+
+	// names maps GOOS values to the naming pattern of the provider's
+	// interfaces on that platform.
+	names map[string]*regexp.Regexp
+
+	// match returns whether an address configured on an interface belongs to
+	// the provider's address space.
+	match func(netip.Prefix) bool
+}
+
+var (
+	tailscaleV4 = netip.MustParsePrefix("100.64.0.0/10")
+	tailscaleV6 = netip.MustParsePrefix("fd7a:115c:a1e0::/48")
+	zerotierV6  = netip.MustParsePrefix("fc00::/8")
+)
+
+var overlayProviders = []overlayProvider{
+	{
+		name: "tailscale",
+		names: map[string]*regexp.Regexp{
+			"linux":   regexp.MustCompile(`^tailscale\d+$`),
+			"windows": regexp.MustCompile(`^Tailscale`),
+		},
+		match: func(p netip.Prefix) bool {
+			// Tailscale assigns single addresses out of the CGNAT range,
+			// which ISPs also use for whole subnets.
+			return (tailscaleV4.Contains(p.Addr()) && p.IsSingleIP()) ||
+				tailscaleV6.Contains(p.Addr())
+		},
+	},
+	{
+		name: "zerotier",
+		names: map[string]*regexp.Regexp{
+			"linux":   regexp.MustCompile(`^zt[0-9a-z]+$`),
+			"darwin":  regexp.MustCompile(`^feth\d+$`),
+			"windows": regexp.MustCompile(`^ZeroTier`),
+		},
+		match: func(p netip.Prefix) bool {
+			// 6PLANE addresses are the only ones using fc00::/8, as RFC 4193
+			// leaves it undefined.
+			return zerotierV6.Contains(p.Addr())
+		},
+	},
+}
+
+// overlayOf returns the name of the overlay provider the named interface
+// belongs to, either by its name or the addresses configured on it, or an
+// empty string when none matches. Interfaces such as utun on macOS are shared
+// by several VPN clients, and can only be recognised by their addresses.
+func overlayOf(name string, prefixes []netip.Prefix) string {
+	for _, provider := range overlayProviders {
+		if re, ok := provider.names[runtime.GOOS]; ok && re.MatchString(name) {
+			return provider.name
+		}
+	}
+	for _, provider := range overlayProviders {
+		for _, p := range prefixes {
+			if provider.match(p) {
+				return provider.name
+			}
+		}
+	}
+	return ""
+}
+
+// OverlayNetworks returns overlay networks the host is attached to, as
+// recognised by the name of their interfaces or the address ranges they
+// assign.
+func OverlayNetworks() ([]OverlayNetwork, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, fmt.Errorf("could not list interfaces: %w", err)
+	}
+
+	var result []OverlayNetwork
+	for i := range ifaces {
+		prefixes, err := interfacePrefixes(&ifaces[i])
+		if err != nil {
+			return nil, err
+		}
+		if provider := overlayOf(ifaces[i].Name, prefixes); provider != "" {
+			result = append(result, OverlayNetwork{
+				Provider:  provider,
+				Interface: ifaces[i].Name,
+				Prefixes:  prefixes,
+			})
+		}
+	}
+
+	return result, nil
+}
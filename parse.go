@@ -0,0 +1,76 @@
+package defip
+
+import (
+	"bufio"
+	"io"
+)
+
+// The functions below expose this package's parsers for callers holding
+// routing table dumps obtained elsewhere (e.g. collected from another host),
+// and work regardless of the platform the program runs on. Rows that cannot
+// be parsed are skipped.
+//
+// Errors locating the part of the input that could not be parsed, such as
+// ErrCantParse, identify it by the name of the reader, as returned by its
+// Name method, such as that of an *os.File, or provided through NamedReader.
+// Input from unnamed readers is identified by line alone.
+
+// namedReader is an io.Reader named through NamedReader.
+type namedReader struct {
+	io.Reader
+	name string
+}
+
+func (r *namedReader) Name() string {
+	return r.name
+}
+
+// NamedReader returns a reader reading from r, whose input is identified by
+// name in errors returned by the functions parsing it, such as ParseNetstat.
+func NamedReader(r io.Reader, name string) io.Reader {
+	return &namedReader{Reader: r, name: name}
+}
+
+// readerName returns the name of r, or an empty string when unnamed.
+func readerName(r io.Reader) string {
+	if named, ok := r.(interface{ Name() string }); ok {
+		return named.Name()
+	}
+	return ""
+}
+
+// ParseProcNetRoute parses the contents of Linux's /proc/net/route.
+func ParseProcNetRoute(r io.Reader) (NetRouteList, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return parseRoutesIPv4(readerName(r), data, nil)
+}
+
+// ParseProcNetIPv6Route parses the contents of Linux's /proc/net/ipv6_route.
+func ParseProcNetIPv6Route(r io.Reader) (NetRouteList, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return parseRoutesIPv6(readerName(r), data, nil)
+}
+
+// ParseNetstat parses the output of `netstat -rn' as printed by BSD-derived
+// systems, according to profile.
+func ParseNetstat(r io.Reader, profile NetstatProfile) (NetRouteList, error) {
+	parser := newNetstatParserProfile(profile)
+	parser.source = readerName(r)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if err := parser.feed(scanner.Text()); err != nil {
+			return nil, err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	parser.finish()
+	return parser.result(), nil
+}
@@ -0,0 +1,37 @@
+package defip
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseErrorSource(t *testing.T) {
+	_, err := ParseNetstat(NamedReader(strings.NewReader("Garbage\n"), "host-42 netstat"), BSDNetstatProfile)
+	var parseErr *ErrCantParse
+	if !errors.As(err, &parseErr) || parseErr.Source != "host-42 netstat" || parseErr.Line != 1 {
+		t.Errorf("got %v, want an ErrCantParse at line 1 of host-42 netstat", err)
+	}
+
+	// Unnamed readers are not mistaken for the files read on this host.
+	_, err = ParseProcNetRoute(strings.NewReader("Garbage\n"))
+	if !errors.As(err, &parseErr) || parseErr.Source != "" || parseErr.Line != 1 {
+		t.Fatalf("got %v, want an ErrCantParse at line 1 of an unnamed source", err)
+	}
+	if want := "can't parse route table at line 1"; err.Error() != want {
+		t.Errorf("got %q, want %q", err, want)
+	}
+}
+
+func TestErrInvalidRouteFileFormatUnwrap(t *testing.T) {
+	var err error = &ErrInvalidRouteFileFormat{row: "garbage", Err: &ErrCantParse{Source: routeV4, Line: 3}}
+	var parseErr *ErrCantParse
+	if !errors.As(err, &parseErr) || parseErr.Line != 3 {
+		t.Errorf("got %v, want it to wrap an ErrCantParse at line 3", err)
+	}
+
+	err = &ErrInvalidRouteFileFormat{row: "garbage"}
+	if errors.As(err, &parseErr) {
+		t.Errorf("got %v wrapping %v, want nothing wrapped", err, parseErr)
+	}
+}
@@ -0,0 +1,68 @@
+package defip
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+)
+
+// pmtuProbePort is the port used when connecting UDP sockets to determine
+// the path towards a destination. No packets are sent.
+const pmtuProbePort = 9
+
+// dialTowards connects a UDP socket to dst, causing the kernel to resolve the
+// route towards it without sending any traffic.
+func dialTowards(ctx context.Context, dst netip.Addr) (*net.UDPConn, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", netip.AddrPortFrom(dst, pmtuProbePort).String())
+	if err != nil {
+		return nil, err
+	}
+	return conn.(*net.UDPConn), nil
+}
+
+// interfaceMTUTowards returns the MTU of the interface the kernel would use to
+// reach dst.
+func interfaceMTUTowards(ctx context.Context, dst netip.Addr) (int, error) {
+	conn, err := dialTowards(ctx, dst)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	local := conn.LocalAddr().(*net.UDPAddr).AddrPort().Addr().Unmap()
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return 0, fmt.Errorf("could not list interfaces: %w", err)
+	}
+	for _, iface := range ifaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, a := range addrs {
+			ipNet, ok := a.(*net.IPNet)
+			if !ok {
+				continue
+			}
+			if addr, ok := netip.AddrFromSlice(ipNet.IP); ok && addr.Unmap() == local.WithZone("") {
+				return iface.MTU, nil
+			}
+		}
+	}
+
+	return 0, fmt.Errorf("could not find interface holding `%s'", local)
+}
+
+// pathMTU returns the path MTU towards dst. Platforms without access to the
+// kernel's path MTU cache report the MTU of the outgoing interface.
+var pathMTU = interfaceMTUTowards
+
+// PathMTU returns the MTU of the path towards dst. On Linux, this is the path
+// MTU cached by the kernel, which reflects Packet Too Big / Fragmentation
+// Needed messages received from routers along the path; elsewhere, and when no
+// such information is cached, it is the MTU of the outgoing interface.
+func (r *Resolver) PathMTU(ctx context.Context, dst netip.Addr) (int, error) {
+	return pathMTU(ctx, dst)
+}
@@ -0,0 +1,38 @@
+package defip
+
+import (
+	"context"
+	"net/netip"
+	"syscall"
+)
+
+func init() {
+	pathMTU = func(ctx context.Context, dst netip.Addr) (int, error) {
+		conn, err := dialTowards(ctx, dst)
+		if err != nil {
+			return 0, err
+		}
+		defer conn.Close()
+
+		raw, err := conn.SyscallConn()
+		if err != nil {
+			return 0, err
+		}
+
+		level, opt := syscall.IPPROTO_IP, syscall.IP_MTU
+		if dst.Unmap().Is6() {
+			level, opt = syscall.IPPROTO_IPV6, syscall.IPV6_MTU
+		}
+
+		var mtu int
+		var sockErr error
+		err = raw.Control(func(fd uintptr) {
+			mtu, sockErr = syscall.GetsockoptInt(int(fd), level, opt)
+		})
+		if err == nil && sockErr == nil && mtu > 0 {
+			return mtu, nil
+		}
+
+		return interfaceMTUTowards(ctx, dst)
+	}
+}
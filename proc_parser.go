@@ -3,9 +3,11 @@ package defip
 import (
 	"encoding/binary"
 	"encoding/hex"
+	"fmt"
 	"net/netip"
 	"os"
 	"slices"
+	"strconv"
 	"strings"
 )
 
@@ -40,12 +42,23 @@ func ip6FromHex(in string) (ip netip.Addr, ok bool) {
 	v, err := hex.DecodeString(in)
 	if err != nil {
 		ok = false
+		return
 	}
 	ip = netip.AddrFrom16([16]byte(v))
 	ok = true
 	return
 }
 
+// ip6RouteAttrs maps attribute names to the index of hexadecimal fields in
+// ipv6_route exposed through NetRoute.Attrs.
+var ip6RouteAttrs = map[string]int{
+	"DestinationPrefix": 1,
+	"SourcePrefix":      3,
+	"Metric":            5,
+	"RefCnt":            6,
+	"Use":               7,
+}
+
 func parseSingleRouteIPv6(fields []string) *NetRoute {
 	dstNet, ok := ip6FromHex(fields[0])
 	if !ok {
@@ -56,45 +69,63 @@ func parseSingleRouteIPv6(fields []string) *NetRoute {
 		return nil
 	}
 	rawFlags, err := hex.DecodeString(fields[8])
-	if err != nil {
+	if err != nil || len(rawFlags) != 4 {
 		return nil
 	}
 	flags := routeTableFlag(binary.BigEndian.Uint32(rawFlags))
 
+	attrs := map[string]string{}
+	for name, idx := range ip6RouteAttrs {
+		v, err := strconv.ParseUint(fields[idx], 16, 32)
+		if err != nil {
+			return nil
+		}
+		attrs[name] = strconv.FormatUint(v, 10)
+	}
+	if src, ok := ip6FromHex(fields[2]); ok {
+		attrs["Source"] = src.String()
+	}
+
 	ifName := fields[9]
 	return &NetRoute{
 		Kind:        NetRouteKindV6,
-		Destination: dstNet,
+		Destination: withLinkZone(dstNet, ifName),
 		Flags:       flags.String(),
+		FlagBits:    linuxRouteFlags(flags),
 		Netif:       ifName,
-		Gateway:     nextHop,
+		Gateway:     withLinkZone(nextHop, ifName),
+		Attrs:       attrs,
 	}
 }
 
-func getRoutesIPv6(source string) (NetRouteList, error) {
+func getRoutesIPv6(source string, log *parseLog) (NetRouteList, error) {
 	f, err := os.ReadFile(source)
 	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, nil
-		}
 		return nil, err
 	}
 
+	return parseRoutesIPv6(source, f, log)
+}
+
+func parseRoutesIPv6(source string, f []byte, log *parseLog) (NetRouteList, error) {
 	var routes NetRouteList
 	lines := strings.Split(string(f), "\n")
-	for _, v := range lines {
+	for i, v := range lines {
 		v = strings.TrimSpace(v)
 		if len(v) == 0 {
 			continue
 		}
 		fields := strings.Fields(v)
 		if len(fields) != 10 {
-			return nil, &ErrInvalidRouteFileFormat{row: v}
+			log.skip(source, i+1, v, fmt.Sprintf("expected 10 fields, found %d", len(fields)))
+			continue
 		}
 		item := parseSingleRouteIPv6(fields)
 		if item == nil {
-			return nil, &ErrInvalidRouteFileFormat{row: v}
+			log.skip(source, i+1, v, "malformed field")
+			continue
 		}
+		log.printf(source, "accepted row %q: %s", v, item)
 		routes = append(routes, *item)
 	}
 
@@ -119,6 +150,7 @@ func ip4FromHex(in string) (ip netip.Addr, ok bool) {
 	v, err := hex.DecodeString(in)
 	if err != nil {
 		ok = false
+		return
 	}
 	slices.Reverse(v)
 	ip = netip.AddrFrom4([4]byte(v))
@@ -126,19 +158,20 @@ func ip4FromHex(in string) (ip netip.Addr, ok bool) {
 	return
 }
 
-func getRoutesIPv4(source string) (NetRouteList, error) {
+func getRoutesIPv4(source string, log *parseLog) (NetRouteList, error) {
 	f, err := os.ReadFile(source)
 	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, nil
-		}
 		return nil, err
 	}
 
+	return parseRoutesIPv4(source, f, log)
+}
+
+func parseRoutesIPv4(source string, f []byte, log *parseLog) (NetRouteList, error) {
 	var routes NetRouteList
 	lines := strings.Split(string(f), "\n")
 	if len(lines) < 1 {
-		return nil, &ErrCantParse{}
+		return nil, &ErrCantParse{Source: source, Line: 1}
 	}
 	fields := fieldSet(strings.Fields(lines[0]))
 	ifNameIdx := fields.fieldIdx("Iface")
@@ -147,30 +180,39 @@ func getRoutesIPv4(source string) (NetRouteList, error) {
 	flagsIdx := fields.fieldIdx("Flags")
 
 	if ifNameIdx == -1 || dstNetIdx == -1 || gatewayIdx == -1 || flagsIdx == -1 {
-		return nil, &ErrCantParse{}
+		log.printf(source, "rejected header %q: missing Iface, Destination, Gateway, or Flags", lines[0])
+		return nil, &ErrCantParse{Source: source, Line: 1}
 	}
+	log.printf(source, "matched header %q", lines[0])
+	minFields := max(ifNameIdx, dstNetIdx, gatewayIdx, flagsIdx) + 1
 
-	for _, v := range lines[1:] {
+	// Rows follow the header, and line numbers start at 1: lines[1:][i] is at
+	// line i+2.
+	for i, v := range lines[1:] {
 		v = strings.TrimSpace(v)
 		if len(v) == 0 {
 			continue
 		}
-		fields := strings.Fields(strings.TrimSpace(v))
-		if len(fields) < 4 {
-			return nil, &ErrInvalidRouteFileFormat{row: v}
+		values := strings.Fields(strings.TrimSpace(v))
+		if len(values) < minFields {
+			log.skip(source, i+2, v, fmt.Sprintf("expected at least %d fields, found %d", minFields, len(values)))
+			continue
 		}
-		dstNet, ok := ip4FromHex(fields[dstNetIdx])
+		dstNet, ok := ip4FromHex(values[dstNetIdx])
 		if !ok {
-			return nil, &ErrInvalidRouteFileFormat{row: v}
+			log.skip(source, i+2, v, "malformed destination")
+			continue
 		}
-		gateway, ok := ip4FromHex(fields[gatewayIdx])
+		gateway, ok := ip4FromHex(values[gatewayIdx])
 		if !ok {
-			return nil, &ErrInvalidRouteFileFormat{row: v}
+			log.skip(source, i+2, v, "malformed gateway")
+			continue
 		}
 
-		rawFlags, err := hex.DecodeString(fields[flagsIdx])
-		if err != nil {
-			return nil, &ErrInvalidRouteFileFormat{row: v}
+		rawFlags, err := hex.DecodeString(values[flagsIdx])
+		if err != nil || len(rawFlags) != 2 {
+			log.skip(source, i+2, v, "malformed flags")
+			continue
 		}
 		flags := routeTableFlag(binary.BigEndian.Uint16(rawFlags))
 
@@ -178,9 +220,12 @@ func getRoutesIPv4(source string) (NetRouteList, error) {
 			Kind:        NetRouteKindV4,
 			Destination: dstNet,
 			Flags:       flags.String(),
-			Netif:       fields[ifNameIdx],
+			FlagBits:    linuxRouteFlags(flags),
+			Netif:       values[ifNameIdx],
 			Gateway:     gateway,
+			Attrs:       fields.attrs(values, "Iface", "Destination", "Gateway", "Flags"),
 		})
+		log.printf(source, "accepted row %q: %s", v, routes[len(routes)-1])
 	}
 
 	return routes, nil
@@ -0,0 +1,31 @@
+package defip
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestParseRoutesIPv6MalformedFlags(t *testing.T) {
+	data, err := os.ReadFile("fixtures/linux_route_v6")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Count(strings.TrimSpace(string(data)), "\n") + 1
+
+	// A flags field of two bytes, instead of four.
+	row := "00000000000000000000000000000000 00 00000000000000000000000000000000 00 fe800000000000000000000000000002 00000400 00000001 00000000 0003     eth1"
+	data = append(data, row+"\n"...)
+
+	log := &parseLog{}
+	routes, err := parseRoutesIPv6(routeV6, data, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(routes) != want {
+		t.Errorf("got %d routes, want %d", len(routes), want)
+	}
+	if len(log.warnings) != 1 || log.warnings[0].Row != row {
+		t.Errorf("got warnings %+v, want one for the malformed row", log.warnings)
+	}
+}
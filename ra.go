@@ -0,0 +1,252 @@
+package defip
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io/fs"
+	"net"
+	"net/netip"
+	"strings"
+	"time"
+)
+
+const (
+	icmpv6RouterSolicitation  = 133
+	icmpv6RouterAdvertisement = 134
+
+	ndOptionRDNSS = 25
+	ndOptionDNSSL = 31
+)
+
+// raListenTimeout bounds how long RouterDNS waits for an advertisement when
+// the provided context has no deadline.
+var raListenTimeout = 5 * time.Second
+
+// RouterDNS represents DNS configuration advertised by an IPv6 router through
+// Router Advertisement options (RFC 8106).
+type RouterDNS struct {
+	// Router is the address of the advertising router, usually link-local,
+	// in which case it is scoped to Interface.
+	Router netip.Addr
+
+	// Interface is the name of the interface the advertisement was received
+	// on.
+	Interface string
+
+	// Servers lists recursive DNS servers (RDNSS option).
+	Servers []netip.Addr
+
+	// SearchDomains lists DNS search domains (DNSSL option).
+	SearchDomains []string
+
+	// Lifetime is the shortest lifetime among the advertised options.
+	Lifetime time.Duration
+}
+
+// RouterDNS solicits Router Advertisements on the interface carrying the
+// default IPv6 route and returns the DNS configuration advertised by the
+// default router. Advertisements from other routers, or that may have been
+// forwarded from beyond the link, are ignored. When ctx has no deadline, waits
+// at most five seconds.
+//
+// Sending solicitations requires a raw ICMPv6 socket, which usually demands
+// elevated privileges (root, or CAP_NET_RAW on Linux). Returns
+// ErrRawSocketDenied when it is not permitted, telling it apart from routers
+// advertising no DNS configuration, for which RouterDNS holds no servers.
+func (r *Resolver) RouterDNS(ctx context.Context) (*RouterDNS, error) {
+	defaults, err := r.FindDefaults(ctx, NetRouteKindV6)
+	if err != nil {
+		return nil, err
+	}
+
+	// Routers are told apart by their address and zone, as the same
+	// link-local address may be used by routers on different links.
+	routers := map[netip.Addr]string{}
+	for _, v := range defaults {
+		if v.IsDefault() && v.Gateway.IsValid() && !v.Gateway.IsUnspecified() {
+			routers[routerKey(v.Gateway, v.Netif)] = v.Netif
+		}
+	}
+	if len(routers) == 0 {
+		return nil, ErrNoDefaultRoute
+	}
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, raListenTimeout)
+		defer cancel()
+	}
+
+	conn, err := net.ListenIP("ip6:ipv6-icmp", &net.IPAddr{IP: net.IPv6unspecified})
+	if err != nil {
+		return nil, raSocketErr(err)
+	}
+	defer conn.Close()
+	if err = prepareRASocket(conn); err != nil {
+		return nil, err
+	}
+	go func() {
+		<-ctx.Done()
+		_ = conn.SetReadDeadline(time.Now())
+	}()
+
+	solicitation := []byte{icmpv6RouterSolicitation, 0, 0, 0, 0, 0, 0, 0}
+	for _, netif := range routers {
+		dst := &net.IPAddr{IP: net.ParseIP("ff02::2"), Zone: netif}
+		if _, err = conn.WriteTo(solicitation, dst); err != nil {
+			return nil, raSocketErr(err)
+		}
+	}
+
+	buf := make([]byte, 1500)
+	oob := make([]byte, 64)
+	for {
+		n, oobn, _, fromAddr, err := conn.ReadMsgIP(buf, oob)
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, ctxErr
+			}
+			return nil, err
+		}
+		// Advertisements not sent with a hop limit of 255 may have been
+		// forwarded from beyond the link, and must be ignored (RFC 4861,
+		// section 6.1.2).
+		if hops, ok := raHopLimit(oob[:oobn]); ok && hops != raHopLimitValue {
+			continue
+		}
+
+		src, ok := netip.AddrFromSlice(fromAddr.IP)
+		if !ok {
+			continue
+		}
+		src = routerKey(src, fromAddr.Zone)
+		netif, ok := routers[src]
+		if !ok {
+			continue
+		}
+
+		result, err := parseRouterAdvertisement(buf[:n])
+		if err != nil {
+			continue
+		}
+		result.Router = src
+		result.Interface = netif
+		return result, nil
+	}
+}
+
+// raSocketErr reports err as ErrRawSocketDenied when it denotes missing
+// privileges, as sandboxes may also forbid sending on raw sockets opened
+// successfully.
+func raSocketErr(err error) error {
+	if errors.Is(err, fs.ErrPermission) {
+		return &ErrRawSocketDenied{Err: err}
+	}
+	return err
+}
+
+// raHopLimitValue is the hop limit Router Solicitations and Advertisements
+// must be sent with, allowing receivers to tell they originate on-link.
+const raHopLimitValue = 255
+
+// prepareRASocket configures conn to send messages with a hop limit of
+// raHopLimitValue, as routers silently drop solicitations sent otherwise, and
+// to report the hop limit of received messages to raHopLimit. Replaced by
+// platforms supporting it.
+var prepareRASocket = func(conn *net.IPConn) error {
+	return nil
+}
+
+// raHopLimit returns the hop limit of a received message from its control
+// data, or false when unknown, in which case the message is accepted.
+var raHopLimit = func(oob []byte) (int, bool) {
+	return 0, false
+}
+
+// routerKey returns addr scoped to zone when it is a link-local address,
+// which is only meaningful along with the link it was seen on, or otherwise
+// without a zone.
+func routerKey(addr netip.Addr, zone string) netip.Addr {
+	if addr.IsLinkLocalUnicast() {
+		return addr.WithZone(zone)
+	}
+	return addr.WithZone("")
+}
+
+var errNotRouterAdvertisement = errors.New("not a router advertisement")
+
+// parseRouterAdvertisement extracts RDNSS and DNSSL options from an ICMPv6
+// Router Advertisement message.
+func parseRouterAdvertisement(msg []byte) (*RouterDNS, error) {
+	// Type, code, checksum, hop limit, flags, router lifetime, reachable
+	// time, and retransmission timer precede options.
+	if len(msg) < 16 || msg[0] != icmpv6RouterAdvertisement {
+		return nil, errNotRouterAdvertisement
+	}
+
+	result := &RouterDNS{}
+	// A lifetime of zero is meaningful, denoting options no longer to be
+	// used, so whether one was found is tracked apart.
+	var lifetime uint32
+	var found bool
+	setLifetime := func(v uint32) {
+		if !found || v < lifetime {
+			lifetime, found = v, true
+		}
+	}
+
+	opts := msg[16:]
+	for len(opts) >= 2 {
+		length := int(opts[1]) * 8
+		if length == 0 || length > len(opts) {
+			return nil, errNotRouterAdvertisement
+		}
+		opt := opts[:length]
+		opts = opts[length:]
+
+		if len(opt) < 8 {
+			continue
+		}
+		switch opt[0] {
+		case ndOptionRDNSS:
+			setLifetime(binary.BigEndian.Uint32(opt[4:8]))
+			for body := opt[8:]; len(body) >= 16; body = body[16:] {
+				result.Servers = append(result.Servers, netip.AddrFrom16([16]byte(body[:16])))
+			}
+		case ndOptionDNSSL:
+			setLifetime(binary.BigEndian.Uint32(opt[4:8]))
+			result.SearchDomains = append(result.SearchDomains, parseDomainNames(opt[8:])...)
+		}
+	}
+
+	result.Lifetime = time.Duration(lifetime) * time.Second
+	return result, nil
+}
+
+// parseDomainNames decodes a sequence of uncompressed DNS names, as carried by
+// the DNSSL option, stopping at padding or malformed data.
+func parseDomainNames(data []byte) []string {
+	var names []string
+	var labels []string
+	for len(data) > 0 {
+		l := int(data[0])
+		data = data[1:]
+		if l == 0 {
+			if len(labels) == 0 {
+				// Padding.
+				break
+			}
+			names = append(names, strings.Join(labels, "."))
+			labels = nil
+			continue
+		}
+		if l > len(data) {
+			break
+		}
+		labels = append(labels, string(data[:l]))
+		data = data[l:]
+	}
+
+	return names
+}
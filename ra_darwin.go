@@ -0,0 +1,8 @@
+package defip
+
+// The RFC 3542 socket options, from netinet6/in6.h, are absent from syscall
+// on Darwin, which only exposes their RFC 2292 counterparts.
+const (
+	ipv6RecvHopLimit = 0x25
+	ipv6HopLimit     = 0x2f
+)
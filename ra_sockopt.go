@@ -0,0 +1,10 @@
+//go:build aix || dragonfly || freebsd || linux || netbsd || openbsd
+
+package defip
+
+import "syscall"
+
+const (
+	ipv6RecvHopLimit = syscall.IPV6_RECVHOPLIMIT
+	ipv6HopLimit     = syscall.IPV6_HOPLIMIT
+)
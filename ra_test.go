@@ -0,0 +1,81 @@
+package defip
+
+import (
+	"encoding/binary"
+	"errors"
+	"io/fs"
+	"net"
+	"net/netip"
+	"os"
+	"slices"
+	"syscall"
+	"testing"
+)
+
+// raOption encodes an RDNSS or DNSSL option of type typ with the provided
+// lifetime and body, padded to a multiple of 8 bytes.
+func raOption(typ byte, lifetime uint32, body []byte) []byte {
+	opt := []byte{typ, 0, 0, 0}
+	opt = binary.BigEndian.AppendUint32(opt, lifetime)
+	opt = append(opt, body...)
+	for len(opt)%8 != 0 {
+		opt = append(opt, 0)
+	}
+	opt[1] = byte(len(opt) / 8)
+	return opt
+}
+
+func TestParseRouterAdvertisement(t *testing.T) {
+	server := netip.MustParseAddr("2001:db8::53")
+	msg := make([]byte, 16)
+	msg[0] = icmpv6RouterAdvertisement
+	msg = append(msg, raOption(ndOptionRDNSS, 0, server.AsSlice())...)
+	msg = append(msg, raOption(ndOptionDNSSL, 3600, []byte("\x07example\x03com\x00"))...)
+
+	ra, err := parseRouterAdvertisement(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(ra.Servers, []netip.Addr{server}) {
+		t.Errorf("got servers %v, want %s", ra.Servers, server)
+	}
+	if !slices.Equal(ra.SearchDomains, []string{"example.com"}) {
+		t.Errorf("got search domains %v, want example.com", ra.SearchDomains)
+	}
+	// The RDNSS option withdraws its servers, which must not be masked by
+	// the lifetime of the DNSSL option following it.
+	if ra.Lifetime != 0 {
+		t.Errorf("got lifetime %s, want 0s", ra.Lifetime)
+	}
+}
+
+func TestRouterKey(t *testing.T) {
+	tests := []struct {
+		addr, zone, want string
+	}{
+		{"fe80::1%en0", "en0", "fe80::1%en0"},
+		{"fe80::1", "en1", "fe80::1%en1"},
+		{"2001:db8::1%en0", "en0", "2001:db8::1"},
+	}
+	for _, tt := range tests {
+		if got := routerKey(netip.MustParseAddr(tt.addr), tt.zone); got.String() != tt.want {
+			t.Errorf("routerKey(%s, %q) = %s, want %s", tt.addr, tt.zone, got, tt.want)
+		}
+	}
+	if routerKey(netip.MustParseAddr("fe80::1"), "en0") == routerKey(netip.MustParseAddr("fe80::1"), "en1") {
+		t.Error("routers sharing a link-local address on different links are not told apart")
+	}
+}
+
+func TestRASocketErr(t *testing.T) {
+	denied := &net.OpError{Op: "listen", Net: "ip6:ipv6-icmp", Err: os.NewSyscallError("socket", syscall.EPERM)}
+	var rawErr *ErrRawSocketDenied
+	if err := raSocketErr(denied); !errors.As(err, &rawErr) || !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("got %v, want an ErrRawSocketDenied wrapping a permission error", err)
+	}
+
+	unreachable := &net.OpError{Op: "write", Net: "ip6:ipv6-icmp", Err: errors.New("network is unreachable")}
+	if err := raSocketErr(unreachable); errors.As(err, &rawErr) {
+		t.Errorf("got %v, want the error unchanged", err)
+	}
+}
@@ -0,0 +1,51 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package defip
+
+import (
+	"encoding/binary"
+	"net"
+	"syscall"
+)
+
+func init() {
+	prepareRASocket = func(conn *net.IPConn) error {
+		raw, err := conn.SyscallConn()
+		if err != nil {
+			return err
+		}
+
+		var sockErr error
+		err = raw.Control(func(fd uintptr) {
+			for _, opt := range [][2]int{
+				{syscall.IPV6_MULTICAST_HOPS, raHopLimitValue},
+				{syscall.IPV6_UNICAST_HOPS, raHopLimitValue},
+				{ipv6RecvHopLimit, 1},
+			} {
+				if sockErr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IPV6, opt[0], opt[1]); sockErr != nil {
+					return
+				}
+			}
+		})
+		if err != nil {
+			return err
+		}
+		return sockErr
+	}
+	raHopLimit = parseHopLimit
+}
+
+// parseHopLimit returns the hop limit carried by an IPV6_HOPLIMIT control
+// message in oob.
+func parseHopLimit(oob []byte) (int, bool) {
+	msgs, err := syscall.ParseSocketControlMessage(oob)
+	if err != nil {
+		return 0, false
+	}
+	for _, m := range msgs {
+		if m.Header.Level == syscall.IPPROTO_IPV6 && int(m.Header.Type) == ipv6HopLimit && len(m.Data) >= 4 {
+			return int(binary.NativeEndian.Uint32(m.Data)), true
+		}
+	}
+	return 0, false
+}
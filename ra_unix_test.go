@@ -0,0 +1,63 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package defip
+
+import (
+	"encoding/binary"
+	"errors"
+	"io/fs"
+	"net"
+	"syscall"
+	"testing"
+	"unsafe"
+)
+
+func TestParseHopLimit(t *testing.T) {
+	oob := make([]byte, syscall.CmsgSpace(4))
+	h := (*syscall.Cmsghdr)(unsafe.Pointer(&oob[0]))
+	h.Level = syscall.IPPROTO_IPV6
+	h.Type = ipv6HopLimit
+	h.SetLen(syscall.CmsgLen(4))
+	binary.NativeEndian.PutUint32(oob[syscall.CmsgLen(0):], 64)
+
+	if hops, ok := parseHopLimit(oob); !ok || hops != 64 {
+		t.Errorf("got %d, %v, want 64, true", hops, ok)
+	}
+	if _, ok := parseHopLimit(nil); ok {
+		t.Error("hop limit reported without control data")
+	}
+}
+
+func TestPrepareRASocket(t *testing.T) {
+	conn, err := net.ListenIP("ip6:ipv6-icmp", &net.IPAddr{IP: net.IPv6unspecified})
+	if errors.Is(err, fs.ErrPermission) {
+		t.Skip("raw sockets require elevated privileges")
+	}
+	if err != nil {
+		t.Skip(err)
+	}
+	defer conn.Close()
+
+	if err = prepareRASocket(conn); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, opt := range []int{syscall.IPV6_MULTICAST_HOPS, syscall.IPV6_UNICAST_HOPS} {
+		var v int
+		var sockErr error
+		if err = raw.Control(func(fd uintptr) {
+			v, sockErr = syscall.GetsockoptInt(int(fd), syscall.IPPROTO_IPV6, opt)
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if sockErr != nil {
+			t.Fatal(sockErr)
+		}
+		if v != raHopLimitValue {
+			t.Errorf("option %d: got hop limit %d, want %d", opt, v, raHopLimitValue)
+		}
+	}
+}
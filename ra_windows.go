@@ -0,0 +1,30 @@
+package defip
+
+import (
+	"net"
+	"syscall"
+)
+
+func init() {
+	// The hop limit of received messages is not reported on Windows, so
+	// only the one of sent messages is set.
+	prepareRASocket = func(conn *net.IPConn) error {
+		raw, err := conn.SyscallConn()
+		if err != nil {
+			return err
+		}
+
+		var sockErr error
+		err = raw.Control(func(fd uintptr) {
+			for _, opt := range []int{syscall.IPV6_MULTICAST_HOPS, syscall.IPV6_UNICAST_HOPS} {
+				if sockErr = syscall.SetsockoptInt(syscall.Handle(fd), syscall.IPPROTO_IPV6, opt, raHopLimitValue); sockErr != nil {
+					return
+				}
+			}
+		})
+		if err != nil {
+			return err
+		}
+		return sockErr
+	}
+}
@@ -0,0 +1,31 @@
+package defip
+
+import "context"
+
+// RawRouteMessage represents a single, unparsed entry obtained from the
+// platform's routing table source, for callers needing attributes not carried
+// by NetRoute.
+type RawRouteMessage struct {
+	// Source identifies where Data was obtained from, such as
+	// "/proc/net/route" or "netstat -rn".
+	Source string
+
+	// Data contains the entry as provided by Source.
+	Data []byte
+}
+
+// RawRoutes returns the low-level routing table entries as provided by the
+// platform, before any parsing takes place.
+//
+// Deprecated: Use Resolver.RawRoutes.
+func RawRoutes() ([]RawRouteMessage, error) {
+	return defaultResolver.RawRoutes(context.Background())
+}
+
+// RawRoutes returns the low-level routing table entries as provided by the
+// platform, before any parsing takes place.
+func (r *Resolver) RawRoutes(ctx context.Context) ([]RawRouteMessage, error) {
+	return useBackend(r.backendContext(ctx), r.backends, r.timeout, func(ctx context.Context, b *backend) ([]RawRouteMessage, error) {
+		return b.rawRoutes(ctx)
+	})
+}
@@ -0,0 +1,74 @@
+package defip
+
+// RequirementKind indicates the kind of access a Requirement refers to.
+type RequirementKind uint8
+
+const (
+	// RequirementReadFile indicates read access to a file, directory, or glob
+	// pattern.
+	RequirementReadFile RequirementKind = iota + 1
+
+	// RequirementExec indicates permission to execute a program.
+	RequirementExec
+
+	// RequirementRawSocket indicates permission to open raw sockets, usually
+	// granted to root, or through CAP_NET_RAW on Linux.
+	RequirementRawSocket
+
+	// RequirementNetlinkSocket indicates permission to open netlink sockets
+	// of the family given by Target, on Linux and Android.
+	RequirementNetlinkSocket
+
+	// RequirementRoutingSocket indicates permission to open PF_ROUTE routing
+	// sockets, on Darwin.
+	RequirementRoutingSocket
+)
+
+func (k RequirementKind) String() string {
+	switch k {
+	case RequirementReadFile:
+		return "read"
+	case RequirementExec:
+		return "exec"
+	case RequirementRawSocket:
+		return "raw-socket"
+	case RequirementNetlinkSocket:
+		return "netlink-socket"
+	case RequirementRoutingSocket:
+		return "routing-socket"
+	}
+	return "unknown"
+}
+
+// Requirement describes access the package may attempt on the running
+// platform, allowing sandbox profiles to be checked against it beforehand.
+type Requirement struct {
+	// Kind indicates the kind of access required.
+	Kind RequirementKind
+
+	// Target is the path, glob pattern, program, or netlink family the access
+	// refers to. Empty for raw and routing sockets.
+	Target string
+
+	// Feature briefly describes what requires the access.
+	Feature string
+
+	// Optional indicates that the package degrades gracefully without the
+	// access, instead of failing.
+	Optional bool
+}
+
+// RequiredPrivileges lists the access the package may attempt on the running
+// platform. Nothing beyond what is listed is attempted; in particular,
+// routing tables are never modified, so CAP_NET_ADMIN is never needed.
+func RequiredPrivileges() []Requirement {
+	return platformRequirements()
+}
+
+// commonRequirements lists access attempted on every supported platform.
+var commonRequirements = []Requirement{
+	{Kind: RequirementRawSocket, Feature: "Resolver.RouterDNS (ICMPv6 router solicitations)"},
+	{Kind: RequirementReadFile, Target: kubeletFlagFiles[0], Feature: "Resolver.CheckKubernetesNodeIP", Optional: true},
+	{Kind: RequirementReadFile, Target: kubeletFlagFiles[1], Feature: "Resolver.CheckKubernetesNodeIP", Optional: true},
+	{Kind: RequirementReadFile, Target: kubeletFlagFiles[2], Feature: "Resolver.CheckKubernetesNodeIP", Optional: true},
+}
@@ -0,0 +1,360 @@
+package defip
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/netip"
+	"slices"
+	"time"
+)
+
+// Resolver detects routes and default IPs according to its configuration. The
+// zero value is not usable; use NewResolver to obtain a Resolver.
+type Resolver struct {
+	routeFilter  func(NetRoute) bool
+	honorMetrics bool
+	defaultsOnly bool
+	weights      Weights
+	duplicates   bool
+	anyLink      bool
+	members      bool
+	preference   func(InterfaceInfo) int
+	excluded     []InterfaceClass
+	trace        io.Writer
+	maxOutput    int64
+	timeout      time.Duration
+	fib          int
+	stability    *stabilityTracker
+	backends     []string
+}
+
+// Option configures a Resolver created through NewResolver.
+type Option func(*Resolver)
+
+// WithRouteFilter replaces DefaultRouteFilter with fn when determining which
+// routes lead to default gateways. A nil fn restores DefaultRouteFilter.
+func WithRouteFilter(fn func(NetRoute) bool) Option {
+	return func(r *Resolver) {
+		if fn == nil {
+			fn = DefaultRouteFilter
+		}
+		r.routeFilter = fn
+	}
+}
+
+// WithHonorOSMetrics makes the Resolver prefer addresses from the interface
+// whose default route has the lowest metric, as the operating system does when
+// routing traffic, before applying address weights. This makes the selected
+// address match the interface shown first by tools such as `ip route' or
+// `route print'. Routes without a known metric are considered last.
+func WithHonorOSMetrics(honor bool) Option {
+	return func(r *Resolver) {
+		r.honorMetrics = honor
+	}
+}
+
+// WithDefaultRoutesOnly determines whether only interfaces carrying a true
+// default route (0.0.0.0/0 or ::/0, see NetRoute.IsDefault) contribute
+// candidate addresses. When disabled, any route accepted by the route filter
+// makes its interface a candidate. Enabled by default.
+func WithDefaultRoutesOnly(only bool) Option {
+	return func(r *Resolver) {
+		r.defaultsOnly = only
+	}
+}
+
+// WithWeights replaces DefaultWeights with w when scoring candidate addresses.
+func WithWeights(w Weights) Option {
+	return func(r *Resolver) {
+		r.weights = w
+	}
+}
+
+// WithDuplicateDefaults determines whether FindDefaults keeps duplicate
+// routes (same kind, destination, gateway, and interface) instead of returning
+// each only once. Disabled by default.
+func WithDuplicateDefaults(keep bool) Option {
+	return func(r *Resolver) {
+		r.duplicates = keep
+	}
+}
+
+// WithIgnoreLinkState determines whether interfaces without carrier (e.g. an
+// unplugged NIC that is administratively up and still holds a static address)
+// may contribute candidate addresses. By default, only interfaces that are
+// operationally up are considered: on Linux, this is determined from the
+// interface's operstate; elsewhere, from its running flag.
+func WithIgnoreLinkState(ignore bool) Option {
+	return func(r *Resolver) {
+		r.anyLink = ignore
+	}
+}
+
+// WithLinkMembers makes selections report the members of the selected
+// interface when it is a bond or bridge, along with which of them is
+// active and their link state. See Selection.Members.
+func WithLinkMembers(resolve bool) Option {
+	return func(r *Resolver) {
+		r.members = resolve
+	}
+}
+
+// WithInterfacePreference sets a policy hook ranking candidate interfaces.
+// Addresses from interfaces with higher preference are always selected over
+// those with lower preference, before address weights (and OS metrics, see
+// WithHonorOSMetrics) are taken into account. For instance, preferring SR-IOV
+// physical functions over virtual functions:
+//
+//	defip.WithInterfacePreference(func(i defip.InterfaceInfo) int {
+//		if i.SRIOV == defip.SRIOVPhysicalFunction {
+//			return 1
+//		}
+//		return 0
+//	})
+func WithInterfacePreference(fn func(InterfaceInfo) int) Option {
+	return func(r *Resolver) {
+		r.preference = fn
+	}
+}
+
+// DefaultExcludedInterfaceClasses lists interface classes excluded from
+// selection unless configured otherwise through
+// WithExcludedInterfaceClasses.
+var DefaultExcludedInterfaceClasses = []InterfaceClass{
+	InterfaceClassVirtualSwitch,
+	InterfaceClassPeerToPeer,
+}
+
+// WithExcludedInterfaceClasses replaces DefaultExcludedInterfaceClasses with
+// classes. Addresses on interfaces of excluded classes are never selected.
+// Calling it without arguments allows every class. For instance, selecting
+// a LAN address rather than one assigned by an overlay network:
+//
+//	defip.WithExcludedInterfaceClasses(append(defip.DefaultExcludedInterfaceClasses, defip.InterfaceClassOverlay)...)
+//
+// Conversely, WithInterfacePreference may be used to prefer overlays through
+// InterfaceInfo.Class.
+func WithExcludedInterfaceClasses(classes ...InterfaceClass) Option {
+	return func(r *Resolver) {
+		r.excluded = classes
+	}
+}
+
+// WithMaxBackendOutput replaces DefaultMaxBackendOutput with n as the
+// maximum amount of output, in bytes, buffered from programs the routing
+// table is obtained from, such as netstat. Past it, the program is terminated
+// and ErrOutputTooLarge is returned. Zero or a negative value removes the
+// cap.
+func WithMaxBackendOutput(n int64) Option {
+	return func(r *Resolver) {
+		r.maxOutput = n
+	}
+}
+
+// WithBackends restricts the Resolver to the routing table backends named
+// by names (see Backends), tried in the provided order. For instance, pinning
+// the procfs backend inside containers where other sources are blocked:
+//
+//	defip.WithBackends("procfs")
+//
+// Calling it without arguments restores the platform's default order. Names
+// not matching any backend compiled in make operations obtaining routes fail.
+func WithBackends(names ...string) Option {
+	return func(r *Resolver) {
+		r.backends = names
+	}
+}
+
+// WithBackendTimeout bounds each attempt to obtain the routing table to d,
+// past which the attempt fails with context.DeadlineExceeded instead of
+// falling through to the next backend. Each backend tried is given d anew.
+// Zero or a negative value, the default, leaves attempts bounded only by the
+// provided contexts.
+func WithBackendTimeout(d time.Duration) Option {
+	return func(r *Resolver) {
+		r.timeout = d
+	}
+}
+
+// WithFIB makes the Resolver obtain routes from forwarding table n on
+// FreeBSD systems configured with more than one through net.fibs (see
+// setfib(1)), instead of the table of the calling process. Routes obtained on
+// FreeBSD carry the table they were listed from as their FIB attribute. A
+// negative value, the default, restores the table of the calling process.
+// Ignored on other platforms.
+func WithFIB(n int) Option {
+	return func(r *Resolver) {
+		r.fib = n
+	}
+}
+
+// backendContext returns a copy of ctx carrying the Resolver's output limit
+// and forwarding table. Its backend timeout is applied to each attempt by
+// useBackend.
+func (r *Resolver) backendContext(ctx context.Context) context.Context {
+	return withFIB(withOutputLimit(ctx, r.maxOutput), r.fib)
+}
+
+// NewResolver returns a new Resolver configured with the provided options.
+func NewResolver(opts ...Option) *Resolver {
+	r := &Resolver{
+		routeFilter:  DefaultRouteFilter,
+		defaultsOnly: true,
+		weights:      DefaultWeights,
+		excluded:     DefaultExcludedInterfaceClasses,
+		maxOutput:    DefaultMaxBackendOutput,
+		fib:          -1,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+var defaultResolver = NewResolver()
+
+// FindRoutes returns a list of detected routes.
+func (r *Resolver) FindRoutes(ctx context.Context) (NetRouteList, error) {
+	routes, _, err := r.findRoutes(ctx)
+	return routes, err
+}
+
+// findRoutes works like FindRoutes, additionally returning rows skipped by
+// the backend's parser.
+func (r *Resolver) findRoutes(ctx context.Context) (NetRouteList, []ParseWarning, error) {
+	ctx, log := withParseLog(r.backendContext(ctx), r.trace)
+	routes, err := useBackend(ctx, r.backends, r.timeout, func(ctx context.Context, b *backend) (NetRouteList, error) {
+		return b.routes(ctx)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return routes, log.warnings, nil
+}
+
+// FindDefaults returns routes of a given kind accepted by the Resolver's route
+// filter.
+func (r *Resolver) FindDefaults(ctx context.Context, kind NetRouteKind) ([]NetRoute, error) {
+	routes, err := r.FindRoutes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defaults := NetRouteList(routes.FindDefaultsFunc(kind, r.routeFilter))
+	if !r.duplicates {
+		defaults = defaults.Deduplicate()
+	}
+
+	return defaults, nil
+}
+
+// DefaultGateway returns the default route of a given kind through a gateway
+// accepted by the Resolver's route filter, with the lowest metric, or listed
+// first when the backend reports no metrics. When kind is NetRouteKindAny,
+// routes of both families are considered. Backends able to query the default
+// route directly do so instead of reading the whole routing table; the table
+// is only read should the query fail, or yield a route rejected by the
+// filter. On macOS, the sysctl backend, used unless unavailable, queries it
+// through an RTM_GET message on a routing socket, while the netstat backend,
+// used in its place, runs `route -n get default'. On other BSDs, the netstat
+// backend queries it through a routing socket too.
+// Returns ErrNoDefaultRoute when no such route exists.
+func (r *Resolver) DefaultGateway(ctx context.Context, kind NetRouteKind) (NetRoute, error) {
+	ctx, log := withParseLog(r.backendContext(ctx), r.trace)
+	return useBackend(ctx, r.backends, r.timeout, func(ctx context.Context, b *backend) (NetRoute, error) {
+		if b.defaultRoute != nil {
+			route, err := b.defaultRoute(ctx, kind)
+			if err == nil && r.isDefaultGateway(route) {
+				return route, nil
+			}
+			if ctx.Err() != nil {
+				return NetRoute{}, ctx.Err()
+			}
+			if err == nil {
+				err = fmt.Errorf("route rejected by filter: %s", route)
+			}
+			log.printf(b.name, "reading routing table, as the default route could not be queried: %s", err)
+		}
+
+		routes, err := b.routes(ctx)
+		if err != nil {
+			return NetRoute{}, err
+		}
+		defaults := routes.FindDefaultsFunc(kind, r.isDefaultGateway)
+		if len(defaults) == 0 {
+			return NetRoute{}, ErrNoDefaultRoute
+		}
+		slices.SortStableFunc(defaults, func(a, b NetRoute) int {
+			return cmp.Compare(routeMetric(a), routeMetric(b))
+		})
+		return defaults[0], nil
+	})
+}
+
+// isDefaultGateway returns whether route is a default route through a
+// gateway accepted by the Resolver's route filter.
+func (r *Resolver) isDefaultGateway(route NetRoute) bool {
+	return route.IsDefault() && !route.IsOnLink() && r.routeFilter(route)
+}
+
+// FindDefaultIP attempts to find an IP of given NetRouteKind that's most likely
+// connected to wider network. When kind is NetRouteKindAny, addresses of both
+// families are considered. Returns an error matching ErrNoIP (see errors.Is)
+// in case no IP with the given kind can be detected.
+func (r *Resolver) FindDefaultIP(ctx context.Context, kind NetRouteKind) (netip.Addr, error) {
+	sel, err := r.Select(ctx, kind)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+
+	return sel.Addr, nil
+}
+
+// candidateGroups groups candidate addresses in the order they must be
+// considered by selectIP. Addresses are grouped by the preference given to
+// their interface through WithInterfacePreference, highest first, then, when
+// the Resolver honours OS metrics, by the metric of their interface's default
+// route, lowest first. Without either, a single group containing all
+// addresses is returned.
+func (r *Resolver) candidateGroups(metrics, prefs map[string]int, addrs map[string][]netip.Addr) [][]netip.Addr {
+	rank := func(name string) [2]int {
+		var k [2]int
+		k[0] = -prefs[name]
+		if r.honorMetrics {
+			k[1] = metrics[name]
+		}
+		return k
+	}
+
+	names := make([]string, 0, len(metrics))
+	for name := range metrics {
+		names = append(names, name)
+	}
+	slices.SortFunc(names, func(a, b string) int {
+		ka, kb := rank(a), rank(b)
+		if c := cmp.Compare(ka[0], kb[0]); c != 0 {
+			return c
+		}
+		return cmp.Compare(ka[1], kb[1])
+	})
+
+	var groups [][]netip.Addr
+	for i, name := range names {
+		if i == 0 || rank(name) != rank(names[i-1]) {
+			groups = append(groups, nil)
+		}
+		groups[len(groups)-1] = append(groups[len(groups)-1], addrs[name]...)
+	}
+	return groups
+}
+
+// routeMetric returns the metric of route, or math.MaxInt when unknown.
+func routeMetric(route NetRoute) int {
+	if metric, ok := route.Metric(); ok {
+		return metric
+	}
+	return math.MaxInt
+}
@@ -0,0 +1,144 @@
+package defip
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// ribSource identifies routes obtained through sysctl in raw routes and logs.
+const ribSource = "sysctl NET_RT_DUMP"
+
+// Route flags missing from package syscall on some architectures.
+const (
+	rtfProxy  = 0x8000000
+	rtfRouter = 0x10000000
+)
+
+// ribFlagLetters lists the letter netstat uses to represent each route flag,
+// in the order it prints them.
+var ribFlagLetters = []struct {
+	flag   int32
+	letter byte
+}{
+	{syscall.RTF_UP, 'U'},
+	{syscall.RTF_GATEWAY, 'G'},
+	{syscall.RTF_HOST, 'H'},
+	{syscall.RTF_REJECT, 'R'},
+	{syscall.RTF_DYNAMIC, 'D'},
+	{syscall.RTF_MODIFIED, 'M'},
+	{syscall.RTF_MULTICAST, 'm'},
+	{syscall.RTF_BROADCAST, 'b'},
+	{syscall.RTF_CLONING, 'C'},
+	{syscall.RTF_XRESOLVE, 'X'},
+	{syscall.RTF_LLINFO, 'L'},
+	{syscall.RTF_STATIC, 'S'},
+	{syscall.RTF_PROTO1, '1'},
+	{syscall.RTF_PROTO2, '2'},
+	{syscall.RTF_WASCLONED, 'W'},
+	{syscall.RTF_PRCLONING, 'c'},
+	{syscall.RTF_PROTO3, '3'},
+	{syscall.RTF_BLACKHOLE, 'B'},
+	{syscall.RTF_IFSCOPE, 'I'},
+	{syscall.RTF_IFREF, 'i'},
+	{rtfProxy, 'Y'},
+	{rtfRouter, 'r'},
+}
+
+// ribAlign is the size socket addresses following routing message headers
+// are padded to.
+const ribAlign = 4
+
+// ribHeaderLen returns the length of the header of a routing message.
+func ribHeaderLen(hdr *syscall.RtMsghdr) int {
+	return syscall.SizeofRtMsghdr
+}
+
+// ribHeaderAttrs returns the Attrs of the route described by hdr.
+func ribHeaderAttrs(hdr *syscall.RtMsghdr) map[string]string {
+	attrs := map[string]string{
+		"Use": strconv.Itoa(int(hdr.Use)),
+	}
+	if hdr.Rmx.Expire != 0 {
+		attrs["Expire"] = strconv.FormatInt(ribExpire(int64(hdr.Rmx.Expire), time.Now()), 10)
+	}
+	return attrs
+}
+
+// probeRIB checks whether the routing table can be dumped through sysctl.
+func probeRIB() error {
+	_, err := syscall.RouteRIB(syscall.NET_RT_DUMP, 0)
+	if err != nil && isSandboxErr(err) {
+		return &ErrSandboxed{Causes: []error{err}}
+	}
+	return err
+}
+
+// ribMessages dumps the routing table through sysctl, returning each
+// routing message it holds.
+func ribMessages(ctx context.Context) ([][]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	b, err := syscall.RouteRIB(syscall.NET_RT_DUMP, 0)
+	if err != nil {
+		if isSandboxErr(err) {
+			return nil, &ErrSandboxed{Causes: []error{err}}
+		}
+		return nil, err
+	}
+	if limit := outputLimitFrom(ctx); limit > 0 && int64(len(b)) > limit {
+		return nil, &ErrOutputTooLarge{Cmd: ribSource, Limit: limit}
+	}
+
+	var msgs [][]byte
+	for len(b) >= 4 {
+		l := int(binary.NativeEndian.Uint16(b[0:2]))
+		if l < 4 || l > len(b) {
+			return nil, &ErrCantParse{Source: ribSource, Line: len(msgs) + 1}
+		}
+		msgs = append(msgs, b[:l])
+		b = b[l:]
+	}
+	return msgs, nil
+}
+
+func ribRoutes(ctx context.Context) (NetRouteList, error) {
+	msgs, err := ribMessages(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	log := parseLogFrom(ctx)
+	names := map[uint16]string{}
+	var routes NetRouteList
+	for i, m := range msgs {
+		route, reason := parseRIBMessage(m, names)
+		if reason != "" {
+			log.skip(ribSource, i+1, fmt.Sprintf("%x", m), reason)
+			continue
+		}
+		if route == nil {
+			continue
+		}
+		log.printf(ribSource, "accepted message %d: %s", i+1, route)
+		routes = append(routes, *route)
+	}
+	return routes, nil
+}
+
+func ribRawRoutes(ctx context.Context) ([]RawRouteMessage, error) {
+	msgs, err := ribMessages(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]RawRouteMessage, 0, len(msgs))
+	for _, m := range msgs {
+		result = append(result, RawRouteMessage{Source: ribSource, Data: m})
+	}
+	return result, nil
+}
@@ -0,0 +1,168 @@
+package defip
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"strings"
+	"syscall"
+)
+
+// routeGetArgs lists the arguments passed to route to query the default
+// route of each family.
+var routeGetArgs = map[NetRouteKind][]string{
+	NetRouteKindV4: {"-n", "get", "default"},
+	NetRouteKindV6: {"-n", "get", "-inet6", "default"},
+}
+
+// routeGetSource returns the name identifying the query of the default route
+// of a given kind in logs.
+func routeGetSource(kind NetRouteKind) string {
+	return "route " + strings.Join(routeGetArgs[kind], " ")
+}
+
+// routeGetFlags maps the names route prints for route flags to their values.
+// Flags netstat does not print, such as DONE, are left out.
+var routeGetFlags = map[string]int32{
+	"UP":        syscall.RTF_UP,
+	"GATEWAY":   syscall.RTF_GATEWAY,
+	"HOST":      syscall.RTF_HOST,
+	"REJECT":    syscall.RTF_REJECT,
+	"DYNAMIC":   syscall.RTF_DYNAMIC,
+	"MODIFIED":  syscall.RTF_MODIFIED,
+	"MULTICAST": syscall.RTF_MULTICAST,
+	"BROADCAST": syscall.RTF_BROADCAST,
+	"CLONING":   syscall.RTF_CLONING,
+	"XRESOLVE":  syscall.RTF_XRESOLVE,
+	"LLINFO":    syscall.RTF_LLINFO,
+	"STATIC":    syscall.RTF_STATIC,
+	"PROTO1":    syscall.RTF_PROTO1,
+	"PROTO2":    syscall.RTF_PROTO2,
+	"WASCLONED": syscall.RTF_WASCLONED,
+	"PRCLONING": syscall.RTF_PRCLONING,
+	"PROTO3":    syscall.RTF_PROTO3,
+	"BLACKHOLE": syscall.RTF_BLACKHOLE,
+	"IFSCOPE":   syscall.RTF_IFSCOPE,
+	"IFREF":     syscall.RTF_IFREF,
+	"PROXY":     rtfProxy,
+	"ROUTER":    rtfRouter,
+}
+
+// routeGetDefault queries the default route of a given kind through
+// `route -n get default', sparing the netstat backend from listing the whole
+// routing table. The netstat backend is only used when the routing table
+// cannot be dumped through sysctl, whose backend queries the default route
+// through a routing socket instead (see rtsockDefaultRoute). When kind is
+// NetRouteKindAny, the IPv4 default route is preferred.
+func routeGetDefault(ctx context.Context, kind NetRouteKind) (NetRoute, error) {
+	kinds := []NetRouteKind{kind}
+	if kind == NetRouteKindAny {
+		kinds = []NetRouteKind{NetRouteKindV4, NetRouteKindV6}
+	}
+
+	var firstErr error
+	for _, k := range kinds {
+		route, err := runRouteGet(ctx, k)
+		if err == nil {
+			return route, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return NetRoute{}, firstErr
+}
+
+func runRouteGet(ctx context.Context, kind NetRouteKind) (NetRoute, error) {
+	source := routeGetSource(kind)
+	stdout, _, err := runBackendCommand(ctx, source, "route", routeGetArgs[kind]...)
+	if err != nil {
+		return NetRoute{}, err
+	}
+	route, err := parseRouteGet(kind, stdout)
+	if err != nil {
+		return NetRoute{}, err
+	}
+	parseLogFrom(ctx).printf(source, "accepted route: %s", route)
+	return route, nil
+}
+
+// parseRouteGet parses the output of `route -n get', made of "key: value"
+// lines describing the route, followed by a table of its metrics:
+//
+//	   route to: default
+//	destination: default
+//	       mask: default
+//	    gateway: 192.0.2.1
+//	  interface: en0
+//	      flags: <UP,GATEWAY,DONE,STATIC,PRCLONING,GLOBAL>
+//	 recvpipe  sendpipe  ssthresh  rtt,msec    rttvar  hopcount      mtu     expire
+//	       0         0         0         0         0         0      1500         0
+//
+// Fails unless the route is a default route through a gateway.
+func parseRouteGet(kind NetRouteKind, out string) (NetRoute, error) {
+	source := routeGetSource(kind)
+	values := map[string]string{}
+	attrs := map[string]string{}
+	lines := strings.Split(out, "\n")
+	for i := 0; i < len(lines); i++ {
+		key, value, ok := strings.Cut(lines[i], ":")
+		if ok {
+			values[strings.TrimSpace(key)] = strings.TrimSpace(value)
+			continue
+		}
+
+		header := strings.Fields(lines[i])
+		if len(header) == 0 || i+1 == len(lines) {
+			continue
+		}
+		i++
+		row := strings.Fields(lines[i])
+		for j, name := range header {
+			if j >= len(row) || row[j] == "0" {
+				continue
+			}
+			switch name {
+			case "mtu":
+				attrs["MTU"] = row[j]
+			case "expire":
+				attrs["Expire"] = row[j]
+			}
+		}
+	}
+
+	if dst := values["destination"]; dst != "default" && dst != "0.0.0.0" && dst != "::" {
+		return NetRoute{}, fmt.Errorf("%s: `%s' is not a default route", source, dst)
+	}
+	if mask := values["mask"]; mask != "" && mask != "default" && mask != "0.0.0.0" && mask != "::" {
+		return NetRoute{}, fmt.Errorf("%s: `%s' is not a default route", source, values["destination"]+"/"+mask)
+	}
+
+	netif := values["interface"]
+	if netif == "" {
+		return NetRoute{}, &ErrCantParse{Source: source}
+	}
+	gateway, err := netip.ParseAddr(values["gateway"])
+	if err != nil || !kind.MatchesAddr(gateway) {
+		return NetRoute{}, fmt.Errorf("%s: no gateway address", source)
+	}
+
+	var flags int32
+	for _, v := range strings.Split(strings.Trim(values["flags"], "<>"), ",") {
+		flags |= routeGetFlags[v]
+	}
+
+	dst := netip.IPv4Unspecified()
+	if kind == NetRouteKindV6 {
+		dst = netip.IPv6Unspecified()
+	}
+	return NetRoute{
+		Kind:        kind,
+		Destination: dst,
+		Flags:       ribFlags(flags),
+		FlagBits:    bsdRouteFlags(ribFlags(flags)),
+		Netif:       netif,
+		Gateway:     withLinkZone(gateway, netif),
+		Attrs:       withPrefixAttr(attrs, 0),
+	}, nil
+}
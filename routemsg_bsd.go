@@ -0,0 +1,215 @@
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+
+package defip
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// Routing messages share their header fields and address encoding across
+// BSDs, but not their layout: each platform defines
+//
+//   - ribAlign, the size socket addresses following a header are padded to;
+//   - ribHeaderLen, returning the length of a header, which addresses follow;
+//   - ribHeaderAttrs, returning the Attrs of the route a header describes;
+//   - ribFlagLetters, listing the letter netstat uses to represent each route
+//     flag, in the order it prints them.
+
+// ribFlags spells flags the way netstat does, so routes read through either
+// backend look alike.
+func ribFlags(flags int32) string {
+	var sb strings.Builder
+	for _, v := range ribFlagLetters {
+		if flags&v.flag != 0 {
+			sb.WriteByte(v.letter)
+		}
+	}
+	return sb.String()
+}
+
+// parseRIBMessage converts a routing message into a NetRoute, resolving
+// interface names through names, which caches them by index. Returns a
+// reason when the message is malformed; messages other than routes, or
+// routes to other families, yield neither a route nor a reason.
+func parseRIBMessage(m []byte, names map[uint16]string) (*NetRoute, string) {
+	if m[2] != syscall.RTM_VERSION || m[3] != syscall.RTM_GET {
+		return nil, ""
+	}
+	if len(m) < syscall.SizeofRtMsghdr {
+		return nil, "truncated message"
+	}
+	hdr := (*syscall.RtMsghdr)(unsafe.Pointer(&m[0]))
+	hdrlen := ribHeaderLen(hdr)
+	if hdrlen < syscall.SizeofRtMsghdr || hdrlen > len(m) {
+		return nil, "truncated message"
+	}
+
+	addrs, ok := ribSockaddrs(m[hdrlen:], hdr.Addrs)
+	if !ok {
+		return nil, "malformed addresses"
+	}
+	dst := addrs[syscall.RTAX_DST]
+	if len(dst) < 2 {
+		return nil, "no destination"
+	}
+
+	var kind NetRouteKind
+	var addrOffset int
+	switch dst[1] {
+	case syscall.AF_INET:
+		kind, addrOffset = NetRouteKindV4, 4
+	case syscall.AF_INET6:
+		kind, addrOffset = NetRouteKindV6, 8
+	default:
+		return nil, ""
+	}
+
+	name, ok := names[hdr.Index]
+	if !ok {
+		iface, err := net.InterfaceByIndex(int(hdr.Index))
+		if err != nil {
+			return nil, fmt.Sprintf("interface %d not found", hdr.Index)
+		}
+		name = iface.Name
+		names[hdr.Index] = name
+	}
+
+	dstAddr, ok := ribAddr(dst)
+	if !ok {
+		return nil, "malformed destination"
+	}
+	flags := ribFlags(hdr.Flags)
+	attrs := ribHeaderAttrs(hdr)
+
+	// Netmasks are truncated past their last non-zero byte, down to no
+	// address at all for default routes.
+	prefix := -1
+	if mask, ok := addrs[syscall.RTAX_NETMASK]; ok {
+		prefix = 0
+		if len(mask) > addrOffset {
+			for _, b := range mask[addrOffset:min(len(mask), addrOffset+dstAddr.BitLen()/8)] {
+				prefix += bits.OnesCount8(b)
+			}
+		}
+	} else if hdr.Flags&syscall.RTF_HOST != 0 {
+		prefix = dstAddr.BitLen()
+	}
+
+	route := &NetRoute{
+		Kind:        kind,
+		Destination: withLinkZone(dstAddr, name),
+		Flags:       flags,
+		FlagBits:    bsdRouteFlags(flags),
+		Netif:       name,
+		Attrs:       withPrefixAttr(attrs, prefix),
+	}
+
+	if gw := addrs[syscall.RTAX_GATEWAY]; len(gw) >= 2 {
+		switch gw[1] {
+		case syscall.AF_INET, syscall.AF_INET6:
+			addr, ok := ribAddr(gw)
+			if !ok {
+				return nil, "malformed gateway"
+			}
+			route.Gateway = withLinkZone(addr, name)
+		case syscall.AF_LINK:
+			hw, index, ok := ribLinkAddr(gw)
+			if !ok {
+				return nil, "malformed gateway"
+			}
+			if hw != nil {
+				route.GatewayHardware = hw
+			} else {
+				attrs["GatewayLink"] = "link#" + strconv.Itoa(int(index))
+			}
+		}
+	}
+	return route, ""
+}
+
+// ribSockaddrs splits the socket addresses following a routing message
+// header, indexed by their RTAX_* position among those flagged in present.
+// Each is padded to a multiple of ribAlign bytes, and empty ones still
+// occupy ribAlign.
+func ribSockaddrs(b []byte, present int32) (map[int][]byte, bool) {
+	addrs := map[int][]byte{}
+	for i := 0; i < syscall.RTAX_MAX; i++ {
+		if present&(1<<i) == 0 {
+			continue
+		}
+		if len(b) == 0 {
+			return nil, false
+		}
+		l := int(b[0])
+		if l > len(b) {
+			return nil, false
+		}
+		addrs[i] = b[:l]
+		step := ribAlign
+		if l > 0 {
+			step = (l + ribAlign - 1) &^ (ribAlign - 1)
+		}
+		b = b[min(step, len(b)):]
+	}
+	return addrs, true
+}
+
+// ribAddr parses an AF_INET or AF_INET6 socket address. The KAME stack
+// embeds the scope of link-local addresses in their second 16-bit word,
+// which is cleared.
+func ribAddr(sa []byte) (netip.Addr, bool) {
+	switch sa[1] {
+	case syscall.AF_INET:
+		if len(sa) < 8 {
+			return netip.Addr{}, false
+		}
+		return netip.AddrFrom4([4]byte(sa[4:8])), true
+	case syscall.AF_INET6:
+		if len(sa) < 24 {
+			return netip.Addr{}, false
+		}
+		a := [16]byte(sa[8:24])
+		addr := netip.AddrFrom16(a)
+		if addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() {
+			a[2], a[3] = 0, 0
+			addr = netip.AddrFrom16(a)
+		}
+		return addr, true
+	}
+	return netip.Addr{}, false
+}
+
+// ribLinkAddr parses an AF_LINK socket address, returning its hardware
+// address, or nil when it carries none, along with its interface index.
+func ribLinkAddr(sa []byte) (net.HardwareAddr, uint16, bool) {
+	// sdl_len, sdl_family, sdl_index, sdl_type, sdl_nlen, sdl_alen,
+	// sdl_slen, then sdl_data, holding the name followed by the address.
+	if len(sa) < 8 {
+		return nil, 0, false
+	}
+	index := binary.NativeEndian.Uint16(sa[2:4])
+	nlen, alen := int(sa[5]), int(sa[6])
+	if 8+nlen+alen > len(sa) {
+		return nil, 0, false
+	}
+	if alen == 0 {
+		return nil, index, true
+	}
+	return net.HardwareAddr(append([]byte(nil), sa[8+nlen:8+nlen+alen]...)), index, true
+}
+
+// ribExpire returns the remaining lifetime, in seconds, of a route whose
+// rmx_expire is expire. The kernel reports when routes expire as a calendar
+// time, while netstat reports, and Attrs holds, the remaining lifetime.
+func ribExpire(expire int64, now time.Time) int64 {
+	return max(expire-now.Unix(), 0)
+}
@@ -0,0 +1,39 @@
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+
+package defip
+
+import (
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestRibExpire(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	if got := ribExpire(1_700_000_090, now); got != 90 {
+		t.Errorf("got %d, want 90", got)
+	}
+	// Routes past their expiry, not yet removed by the kernel.
+	if got := ribExpire(1_699_999_990, now); got != 0 {
+		t.Errorf("got %d, want 0", got)
+	}
+}
+
+func TestRibSockaddrsRequest(t *testing.T) {
+	for _, kind := range []NetRouteKind{NetRouteKindV4, NetRouteKindV6} {
+		m := rtmGetRequest(kind, 1)
+		if len(m)%ribAlign != 0 {
+			t.Errorf("%s: request of %d bytes is not padded to %d", kind, len(m), ribAlign)
+		}
+		addrs, ok := ribSockaddrs(m[syscall.SizeofRtMsghdr:], syscall.RTA_DST|syscall.RTA_NETMASK)
+		if !ok {
+			t.Fatalf("%s: malformed addresses", kind)
+		}
+		for _, i := range []int{syscall.RTAX_DST, syscall.RTAX_NETMASK} {
+			addr, ok := ribAddr(addrs[i])
+			if !ok || !addr.IsUnspecified() || (kind == NetRouteKindV6) != addr.Is6() {
+				t.Errorf("%s: address %d is %s, want an unspecified %[1]s address", kind, i, addr)
+			}
+		}
+	}
+}
@@ -0,0 +1,203 @@
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+
+package defip
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// rtsockSource identifies routes obtained through a routing socket in raw
+// routes and logs.
+const rtsockSource = "PF_ROUTE RTM_GET"
+
+// rtsockPollInterval bounds how long reading a routing socket blocks before
+// ctx is checked again.
+const rtsockPollInterval = 250 * time.Millisecond
+
+// probeRouteSocket checks whether a routing socket can be opened.
+func probeRouteSocket() error {
+	s, err := syscall.Socket(syscall.AF_ROUTE, syscall.SOCK_RAW, syscall.AF_UNSPEC)
+	if err != nil {
+		if isSandboxErr(err) {
+			return &ErrSandboxed{Causes: []error{err}}
+		}
+		return fmt.Errorf("could not open routing socket: %w", err)
+	}
+	syscall.Close(s)
+	return nil
+}
+
+// prepareRouteSocket configures routing socket s before querying it for
+// routes requested through ctx. Replaced on FreeBSD, to select the
+// forwarding table to query.
+var prepareRouteSocket = func(ctx context.Context, s int) error {
+	return nil
+}
+
+// rtmGetRequest returns an RTM_GET message asking for the default route of
+// a given kind: a header followed by its destination and netmask, both
+// unspecified addresses, padded to ribAlign bytes.
+func rtmGetRequest(kind NetRouteKind, seq int32) []byte {
+	size, family := syscall.SizeofSockaddrInet4, syscall.AF_INET
+	if kind == NetRouteKindV6 {
+		size, family = syscall.SizeofSockaddrInet6, syscall.AF_INET6
+	}
+	sa := make([]byte, (size+ribAlign-1)&^(ribAlign-1))
+	sa[0], sa[1] = byte(size), byte(family)
+
+	b := make([]byte, syscall.SizeofRtMsghdr, syscall.SizeofRtMsghdr+2*len(sa))
+	hdr := (*syscall.RtMsghdr)(unsafe.Pointer(&b[0]))
+	hdr.Msglen = uint16(cap(b))
+	hdr.Version = syscall.RTM_VERSION
+	hdr.Type = syscall.RTM_GET
+	hdr.Flags = syscall.RTF_UP | syscall.RTF_GATEWAY
+	hdr.Addrs = syscall.RTA_DST | syscall.RTA_NETMASK
+	hdr.Seq = seq
+	return append(append(b, sa...), sa...)
+}
+
+// rtmGet asks the kernel for the default route of a given kind through a
+// routing socket, returning its reply. Returns ErrNoDefaultRoute when there
+// is none.
+func rtmGet(ctx context.Context, kind NetRouteKind) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s, err := syscall.Socket(syscall.AF_ROUTE, syscall.SOCK_RAW, syscall.AF_UNSPEC)
+	if err != nil {
+		if isSandboxErr(err) {
+			return nil, &ErrSandboxed{Causes: []error{err}}
+		}
+		return nil, err
+	}
+	defer syscall.Close(s)
+	if err = prepareRouteSocket(ctx, s); err != nil {
+		return nil, err
+	}
+
+	// Routing sockets receive every routing message; the reply is told
+	// apart by its sequence number and process ID.
+	const seq = 1
+	if _, err = syscall.Write(s, rtmGetRequest(kind, seq)); err != nil {
+		if err == syscall.ESRCH {
+			return nil, ErrNoDefaultRoute
+		}
+		if isSandboxErr(err) {
+			return nil, &ErrSandboxed{Causes: []error{err}}
+		}
+		return nil, err
+	}
+
+	tv := syscall.NsecToTimeval(rtsockPollInterval.Nanoseconds())
+	if err = syscall.SetsockoptTimeval(s, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, &tv); err != nil {
+		return nil, err
+	}
+	pid := int32(syscall.Getpid())
+	buf := make([]byte, syscall.Getpagesize())
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		n, err := syscall.Read(s, buf)
+		if err == syscall.EINTR || err == syscall.EAGAIN {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if n < syscall.SizeofRtMsghdr {
+			continue
+		}
+		hdr := (*syscall.RtMsghdr)(unsafe.Pointer(&buf[0]))
+		if hdr.Type != syscall.RTM_GET || hdr.Pid != pid || hdr.Seq != seq {
+			continue
+		}
+		if hdr.Errno != 0 {
+			if errno := syscall.Errno(hdr.Errno); errno != syscall.ESRCH {
+				return nil, fmt.Errorf("%s: %w", rtsockSource, errno)
+			}
+			return nil, ErrNoDefaultRoute
+		}
+		return append([]byte(nil), buf[:min(n, int(hdr.Msglen))]...), nil
+	}
+}
+
+// rtsockDefaultRoute queries the default route of a given kind through a
+// routing socket. When kind is NetRouteKindAny, the IPv4 default route is
+// preferred.
+func rtsockDefaultRoute(ctx context.Context, kind NetRouteKind) (NetRoute, error) {
+	kinds := []NetRouteKind{kind}
+	if kind == NetRouteKindAny {
+		kinds = []NetRouteKind{NetRouteKindV4, NetRouteKindV6}
+	}
+
+	var firstErr error
+	for _, k := range kinds {
+		route, err := rtsockQuery(ctx, k)
+		if err == nil {
+			return route, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return NetRoute{}, firstErr
+}
+
+// rtsockQuery obtains and parses the default route of a given kind, which
+// must not be NetRouteKindAny.
+func rtsockQuery(ctx context.Context, kind NetRouteKind) (NetRoute, error) {
+	m, err := rtmGet(ctx, kind)
+	if err != nil {
+		return NetRoute{}, err
+	}
+	log := parseLogFrom(ctx)
+	route, reason := parseRIBMessage(m, map[uint16]string{})
+	if reason == "" && route == nil {
+		reason = "not a route"
+	}
+	if reason != "" {
+		log.skip(rtsockSource, 1, fmt.Sprintf("%x", m), reason)
+		return NetRoute{}, &ErrCantParse{Source: rtsockSource, Line: 1}
+	}
+	log.printf(rtsockSource, "accepted reply: %s", route)
+	return *route, nil
+}
+
+// rtsockRoutes returns the default routes of both families, being the only
+// routes a routing socket is queried for.
+func rtsockRoutes(ctx context.Context) (NetRouteList, error) {
+	var routes NetRouteList
+	for _, kind := range []NetRouteKind{NetRouteKindV4, NetRouteKindV6} {
+		route, err := rtsockQuery(ctx, kind)
+		if errors.Is(err, ErrNoDefaultRoute) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		routes = append(routes, route)
+	}
+	return routes, nil
+}
+
+func rtsockRawRoutes(ctx context.Context) ([]RawRouteMessage, error) {
+	var result []RawRouteMessage
+	for _, kind := range []NetRouteKind{NetRouteKindV4, NetRouteKindV6} {
+		m, err := rtmGet(ctx, kind)
+		if errors.Is(err, ErrNoDefaultRoute) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, RawRouteMessage{Source: rtsockSource, Data: m})
+	}
+	return result, nil
+}
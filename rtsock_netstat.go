@@ -0,0 +1,29 @@
+//go:build dragonfly || freebsd || netbsd || openbsd
+
+package defip
+
+import "syscall"
+
+func init() {
+	netstatDefaultRoute = rtsockDefaultRoute
+}
+
+// ribFlagLetters lists the letter netstat uses to represent each route flag,
+// in the order it prints them, limited to flags defined on every BSD.
+var ribFlagLetters = []struct {
+	flag   int32
+	letter byte
+}{
+	{syscall.RTF_UP, 'U'},
+	{syscall.RTF_GATEWAY, 'G'},
+	{syscall.RTF_HOST, 'H'},
+	{syscall.RTF_REJECT, 'R'},
+	{syscall.RTF_DYNAMIC, 'D'},
+	{syscall.RTF_MODIFIED, 'M'},
+	{syscall.RTF_DONE, 'd'},
+	{syscall.RTF_LLINFO, 'L'},
+	{syscall.RTF_STATIC, 'S'},
+	{syscall.RTF_BLACKHOLE, 'B'},
+	{syscall.RTF_PROTO1, '1'},
+	{syscall.RTF_PROTO2, '2'},
+}
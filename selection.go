@@ -0,0 +1,347 @@
+package defip
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/netip"
+	"slices"
+	"time"
+)
+
+// Selection represents the result of selecting a default IP, along with the
+// data that justified it.
+type Selection struct {
+	// Addr is the selected address.
+	Addr netip.Addr
+
+	// Interface is the interface Addr is configured on.
+	Interface net.Interface
+
+	// InterfaceInfo holds additional metadata about Interface, such as the
+	// physical NIC it rides on when it is a macvlan or ipvlan interface.
+	InterfaceInfo InterfaceInfo
+
+	// Route is the default route through Interface that made it a
+	// candidate.
+	Route NetRoute
+
+	// Reason briefly describes why Addr was selected.
+	Reason string
+
+	// CollectedAt indicates when the routing table used for the selection was
+	// collected.
+	CollectedAt time.Time
+
+	// MTU is the usable MTU through Route: the route's own MTU when set, or
+	// the MTU of Interface otherwise.
+	MTU int
+
+	// Unnumbered indicates that Route's gateway is not reachable through any
+	// subnet configured on Interface: it is either unspecified, one of the
+	// interface's own addresses, or outside all of its prefixes. This is
+	// typical of proxy-ARP and unnumbered setups, such as cloud VMs
+	// configured with a /32 address.
+	Unnumbered bool
+
+	// Members lists the members of Interface when it is a bond or bridge.
+	// Only populated when the Resolver is configured through WithLinkMembers.
+	Members []LinkMember
+}
+
+// LogValue implements slog.LogValuer, emitting the selection and its
+// provenance.
+func (s Selection) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("addr", s.Addr.String()),
+		slog.String("interface", s.Interface.Name),
+		slog.Int("mtu", s.MTU),
+		slog.Any("route", s.Route),
+		slog.String("reason", s.Reason),
+		slog.Time("collected_at", s.CollectedAt),
+	)
+}
+
+// SelectDefaultIP works like FindDefaultIP, but returns a Selection containing
+// the interface and route that justified the selected address.
+//
+// Deprecated: Use Resolver.Select.
+func SelectDefaultIP(kind NetRouteKind) (*Selection, error) {
+	sel, err := defaultResolver.Select(context.Background(), kind)
+	if err != nil {
+		return nil, compatErr(err)
+	}
+	return sel, nil
+}
+
+// Select works like FindDefaultIP, but returns a Selection containing the
+// interface and route that justified the selected address, obtained from the
+// same routing table snapshot.
+func (r *Resolver) Select(ctx context.Context, kind NetRouteKind) (*Selection, error) {
+	routes, err := r.FindRoutes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.selectFrom(kind, routes, time.Now(), nil)
+}
+
+// DualSelection holds selections of both address families, made from the
+// same routing table snapshot by SelectDual.
+type DualSelection struct {
+	// V4 and V6 hold the selected IPv4 and IPv6 addresses, when found.
+	V4 *Selection
+	V6 *Selection
+
+	// V4Err and V6Err hold why no address of the respective family could be
+	// selected, such as an error matching ErrNoIP on single-stack hosts.
+	V4Err error
+	V6Err error
+}
+
+// SelectDual selects both an IPv4 and an IPv6 address, as Select does, while
+// collecting the routing table only once. Fails only when the routing table
+// cannot be obtained.
+func (r *Resolver) SelectDual(ctx context.Context) (*DualSelection, error) {
+	routes, err := r.FindRoutes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	d := &DualSelection{}
+	d.V4, d.V4Err = r.selectFrom(NetRouteKindV4, routes, now, nil)
+	d.V6, d.V6Err = r.selectFrom(NetRouteKindV6, routes, now, nil)
+	return d, nil
+}
+
+// selectFrom performs selection against routes. extra lists addresses assumed
+// to be configured on interfaces, in addition to those actually present,
+// allowing interfaces that do not exist to take part in simulations. Only
+// selections without extra, which are not simulations, are observed for
+// WithStabilityBonus.
+func (r *Resolver) selectFrom(kind NetRouteKind, routes NetRouteList, collectedAt time.Time, extra map[string][]netip.Addr) (*Selection, error) {
+	// ifaces maps each interface carrying a default route to the lowest
+	// metric among its default routes.
+	//
+	// Addresses are only considered on interfaces carrying a default route of
+	// their own family, so an interface whose only default is IPv6 cannot
+	// contribute (and win with) an IPv4 address, and vice versa.
+	ifaces := map[string]int{}
+	families := map[string]map[NetRouteKind]bool{}
+	defaults := routes.FindDefaultsFunc(kind, r.candidateFilter)
+	if len(defaults) == 0 {
+		// Single-stack hosts lack default routes of the other family
+		// altogether; fail without enumerating interfaces.
+		if r.stability != nil && extra == nil {
+			r.stability.observe(kind, nil, collectedAt)
+		}
+		return nil, fmt.Errorf("%w: no %s default route", ErrNoIP, kind)
+	}
+	for _, v := range defaults {
+		if families[v.Netif] == nil {
+			families[v.Netif] = map[NetRouteKind]bool{}
+		}
+		families[v.Netif][v.Kind] = true
+
+		metric, ok := v.Metric()
+		if !ok {
+			metric = math.MaxInt
+		}
+		if current, ok := ifaces[v.Netif]; !ok || metric < current {
+			ifaces[v.Netif] = metric
+		}
+	}
+
+	// Enumerate interfaces once, instead of resolving each one by name. An
+	// interface referenced by a route may vanish between the routing table
+	// being collected and this call; such interfaces are simply skipped.
+	all, err := net.Interfaces()
+	if err != nil {
+		return nil, fmt.Errorf("could not list interfaces: %w", err)
+	}
+	byName := make(map[string]*net.Interface, len(all))
+	for i := range all {
+		byName[all[i].Name] = &all[i]
+	}
+
+	addrs := map[string][]netip.Addr{}
+	prefixes := map[string][]netip.Prefix{}
+	owners := map[netip.Addr]*net.Interface{}
+	for name := range ifaces {
+		var present []netip.Addr
+		iface, ok := byName[name]
+		if ok && !r.anyLink && !linkUp(iface) {
+			continue
+		}
+		if ok && isEnslaved(name) {
+			// Addresses left behind on bond or bridge members are not
+			// used for traffic.
+			continue
+		}
+		if ok {
+			if prefixes[name], err = interfacePrefixes(iface); err != nil {
+				return nil, err
+			}
+			for _, p := range prefixes[name] {
+				present = append(present, p.Addr())
+			}
+		} else if len(extra[name]) > 0 {
+			iface = &net.Interface{Name: name}
+		} else {
+			continue
+		}
+		if slices.Contains(r.excluded, classifyPrefixes(name, prefixes[name])) {
+			continue
+		}
+
+		for _, add := range append(present, extra[name]...) {
+			// IPv4-mapped addresses carry IPv4 traffic, and are neither
+			// IPv6 candidates nor usable as IPv4 ones on their own.
+			if add.Is4In6() || !kind.MatchesAddr(add) || !families[name][kindOf(add)] {
+				continue
+			}
+			add = add.WithZone(name)
+			addrs[name] = append(addrs[name], add)
+			owners[add] = iface
+		}
+	}
+
+	prefs := map[string]int{}
+	if r.preference != nil {
+		for name := range addrs {
+			info := lookupInterfaceInfo(name)
+			info.Class = classifyPrefixes(name, prefixes[name])
+			prefs[name] = r.preference(info)
+		}
+	}
+
+	if r.stability != nil && extra == nil {
+		var candidates []netip.Addr
+		for _, v := range addrs {
+			candidates = append(candidates, v...)
+		}
+		r.stability.observe(kind, candidates, collectedAt)
+	}
+
+	score := r.scorer(collectedAt)
+	for _, candidates := range r.candidateGroups(ifaces, prefs, addrs) {
+		ip, ok := selectIP(kind, candidates, score)
+		if !ok {
+			continue
+		}
+
+		iface := owners[ip]
+		sel := &Selection{
+			Addr:        ip,
+			Interface:   *iface,
+			Reason:      fmt.Sprintf("highest weighted of %d candidate address(es) on interfaces carrying default routes", len(candidates)),
+			CollectedAt: collectedAt,
+		}
+		if r.honorMetrics {
+			sel.Reason += ", restricted to the lowest route metric"
+		}
+		if r.stability != nil && r.stability.stable(ip, collectedAt) {
+			sel.Reason += fmt.Sprintf(", favoured for being present for at least %s", r.stability.after)
+		}
+		sel.InterfaceInfo = lookupInterfaceInfo(iface.Name)
+		sel.InterfaceInfo.Class = classifyPrefixes(iface.Name, prefixes[iface.Name])
+		sel.Route = bestRoute(defaults, iface.Name, kindOf(ip))
+		sel.MTU = iface.MTU
+		if mtu, ok := sel.Route.MTU(); ok {
+			sel.MTU = mtu
+		}
+		sel.Unnumbered = isUnnumbered(sel.Route.Gateway, prefixes[iface.Name])
+		if sel.Unnumbered {
+			sel.Reason += ", through an unnumbered gateway"
+		}
+		if r.members {
+			sel.Members = linkMembers(iface.Name)
+		}
+		return sel, nil
+	}
+
+	return nil, ErrNoIP
+}
+
+// interfacePrefixes returns the addresses configured on iface, along with
+// their prefix lengths.
+func interfacePrefixes(iface *net.Interface) ([]netip.Prefix, error) {
+	ips, err := iface.Addrs()
+	if err != nil {
+		return nil, fmt.Errorf("could not get IPs for interface `%s': %w", iface.Name, err)
+	}
+
+	var prefixes []netip.Prefix
+	for _, v := range ips {
+		rawAdd, ok := v.(*net.IPNet)
+		if !ok {
+			continue
+		}
+
+		var add netip.Addr
+		if v4 := rawAdd.IP.To4(); v4 != nil {
+			add = netip.AddrFrom4([4]byte(v4))
+		} else {
+			add = netip.AddrFrom16([16]byte(rawAdd.IP))
+		}
+		ones, _ := rawAdd.Mask.Size()
+		prefixes = append(prefixes, netip.PrefixFrom(add, ones))
+	}
+	return prefixes, nil
+}
+
+// isUnnumbered returns whether gateway cannot be reached through any of
+// prefixes, either because it is unspecified, because it is one of the
+// addresses the prefixes were obtained from, or because no prefix covers it.
+func isUnnumbered(gateway netip.Addr, prefixes []netip.Prefix) bool {
+	gateway = gateway.WithZone("")
+	if !gateway.IsValid() || gateway.IsUnspecified() {
+		return true
+	}
+	if gateway.IsLinkLocalUnicast() {
+		// IPv6 routers are reached through their link-local address, which
+		// is always on-link.
+		return false
+	}
+	for _, p := range prefixes {
+		if p.Addr() == gateway {
+			return true
+		}
+	}
+	for _, p := range prefixes {
+		if p.Contains(gateway) {
+			return false
+		}
+	}
+	return true
+}
+
+// candidateFilter returns whether a route makes its interface a source of
+// candidate addresses.
+func (r *Resolver) candidateFilter(route NetRoute) bool {
+	return r.routeFilter(route) && (!r.defaultsOnly || route.IsDefault())
+}
+
+// bestRoute returns the lowest-metric route of a given kind among routes
+// going through netif.
+func bestRoute(routes []NetRoute, netif string, kind NetRouteKind) NetRoute {
+	var best NetRoute
+	bestMetric := math.MaxInt
+	found := false
+	for _, v := range routes {
+		if v.Netif != netif || v.Kind != kind {
+			continue
+		}
+		metric, ok := v.Metric()
+		if !ok {
+			metric = math.MaxInt
+		}
+		if !found || metric < bestMetric {
+			best, bestMetric, found = v, metric, true
+		}
+	}
+	return best
+}
@@ -0,0 +1,298 @@
+package defip
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/netip"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSelectFromV6Only(t *testing.T) {
+	routes := NetRouteList{
+		{
+			Kind:        NetRouteKindV6,
+			Destination: netip.IPv6Unspecified(),
+			Gateway:     netip.MustParseAddr("fe80::1%sim0"),
+			Netif:       "sim0",
+			Flags:       "UG",
+			FlagBits:    RouteFlagUp | RouteFlagGateway,
+			Attrs:       map[string]string{"DestinationPrefix": "0"},
+		},
+	}
+	extra := map[string][]netip.Addr{
+		"sim0": {netip.MustParseAddr("2001:db8::10")},
+	}
+	r := NewResolver()
+
+	if _, err := r.selectFrom(NetRouteKindV4, routes, time.Now(), extra); !errors.Is(err, ErrNoIP) {
+		t.Errorf("IPv4 selection: got error %v, want one matching ErrNoIP", err)
+	}
+
+	sel, err := r.selectFrom(NetRouteKindV6, routes, time.Now(), extra)
+	if err != nil {
+		t.Fatalf("IPv6 selection: %s", err)
+	}
+	if want := netip.MustParseAddr("2001:db8::10%sim0"); sel.Addr != want {
+		t.Errorf("IPv6 selection: got %s, want %s", sel.Addr, want)
+	}
+	if sel.Route.Netif != "sim0" {
+		t.Errorf("IPv6 selection: got route %s, want one through sim0", sel.Route)
+	}
+}
+
+func TestWithRouteFilterNil(t *testing.T) {
+	routes := NetRouteList{testRoute("0.0.0.0", 0, "192.0.2.1", "sim0", RouteFlagUp|RouteFlagGateway)}
+	extra := map[string][]netip.Addr{
+		"sim0": {netip.MustParseAddr("192.0.2.10")},
+	}
+	r := NewResolver(WithRouteFilter(nil))
+
+	sel, err := r.selectFrom(NetRouteKindV4, routes, time.Now(), extra)
+	if err != nil {
+		t.Fatalf("selection: %s", err)
+	}
+	if want := netip.MustParseAddr("192.0.2.10"); sel.Addr != want {
+		t.Errorf("selection: got %s, want %s", sel.Addr, want)
+	}
+}
+
+func TestCompatErr(t *testing.T) {
+	if err := compatErr(fmt.Errorf("%w: no IPv4 default route", ErrNoIP)); err != ErrNoIP {
+		t.Errorf("got %v, want ErrNoIP itself", err)
+	}
+	other := errors.New("other")
+	if err := compatErr(other); err != other {
+		t.Errorf("got %v, want %v", err, other)
+	}
+}
+
+// testRoute returns a route to dst/bits through gateway on netif.
+func testRoute(dst string, bits int, gateway, netif string, flags RouteFlag) NetRoute {
+	d := netip.MustParseAddr(dst)
+	kind := NetRouteKindV4
+	if d.Is6() {
+		kind = NetRouteKindV6
+	}
+	return NetRoute{
+		Kind:        kind,
+		Destination: d,
+		Gateway:     netip.MustParseAddr(gateway),
+		Netif:       netif,
+		Flags:       flags.String(),
+		FlagBits:    flags,
+		Attrs:       map[string]string{"DestinationPrefix": fmt.Sprint(bits)},
+	}
+}
+
+func TestSelectFromMatchesFindDefaults(t *testing.T) {
+	extra := map[string][]netip.Addr{
+		"sim0": {netip.MustParseAddr("192.168.1.10")},
+		"tun0": {netip.MustParseAddr("203.0.113.5")},
+		"sim1": {netip.MustParseAddr("198.51.100.7")},
+	}
+	tests := []struct {
+		name   string
+		routes NetRouteList
+	}{
+		{"host route pinning a VPN endpoint", NetRouteList{
+			testRoute("0.0.0.0", 0, "192.168.1.1", "sim0", RouteFlagUp|RouteFlagGateway),
+			testRoute("198.51.100.1", 32, "10.8.0.1", "tun0", RouteFlagUp|RouteFlagGateway|RouteFlagHost),
+		}},
+		{"only a host route", NetRouteList{
+			testRoute("198.51.100.1", 32, "10.8.0.1", "tun0", RouteFlagUp|RouteFlagGateway|RouteFlagHost),
+		}},
+		{"route not up", NetRouteList{
+			testRoute("0.0.0.0", 0, "192.168.1.1", "sim0", RouteFlagUp|RouteFlagGateway),
+			testRoute("0.0.0.0", 0, "198.51.100.1", "sim1", RouteFlagGateway),
+		}},
+		{"route without gateway", NetRouteList{
+			testRoute("0.0.0.0", 0, "192.168.1.1", "sim0", RouteFlagUp|RouteFlagGateway),
+			testRoute("0.0.0.0", 0, "0.0.0.0", "tun0", RouteFlagUp),
+		}},
+	}
+
+	for _, tt := range tests {
+		for _, only := range []bool{true, false} {
+			r := NewResolver(WithDefaultRoutesOnly(only))
+			netifs := map[string]bool{}
+			for _, v := range tt.routes.FindDefaults(NetRouteKindV4) {
+				if !only || v.IsDefault() {
+					netifs[v.Netif] = true
+				}
+			}
+
+			sel, err := r.selectFrom(NetRouteKindV4, tt.routes, time.Now(), extra)
+			if len(netifs) == 0 {
+				if !errors.Is(err, ErrNoIP) {
+					t.Errorf("%s (defaults only: %v): got %v, %v, want an error matching ErrNoIP", tt.name, only, sel, err)
+				}
+				continue
+			}
+			if err != nil {
+				t.Errorf("%s (defaults only: %v): %s", tt.name, only, err)
+				continue
+			}
+			if !netifs[sel.Route.Netif] {
+				t.Errorf("%s (defaults only: %v): selected %s through %s, not among FindDefaults interfaces %v", tt.name, only, sel.Addr, sel.Route.Netif, netifs)
+			}
+		}
+	}
+}
+
+func TestSelectFromDarwinFixtures(t *testing.T) {
+	tests := []struct {
+		fixture string
+		routes  int
+		extra   map[string][]netip.Addr
+		v4, v6  string
+	}{
+		{
+			// The VPN claims 0/1 and 128/1 rather than the default route,
+			// and system tunnels carry scoped IPv6 defaults through
+			// link-local addresses only.
+			fixture: "fixtures/darwin_netstat_vpn",
+			routes:  35,
+			extra: map[string][]netip.Addr{
+				"en0":   addrsOf("192.168.1.23", "2001:db8:5c1:10:1c2e:77ff:fe01:a2b3"),
+				"utun4": addrsOf("10.8.0.2"),
+				"utun0": addrsOf("fe80::7c1d:9b4e:6a02:1f3"),
+				"utun1": addrsOf("fe80::d2b1:4a77:3f0c:8e15"),
+			},
+			v4: "192.168.1.23",
+			v6: "2001:db8:5c1:10:1c2e:77ff:fe01:a2b3%en0",
+		},
+		{
+			// Virtual machines are bridged through bridge100, which holds
+			// addresses but no default route.
+			fixture: "fixtures/darwin_netstat_bridge",
+			routes:  27,
+			extra: map[string][]netip.Addr{
+				"en0":       addrsOf("10.0.0.42", "2001:db8:a::42"),
+				"bridge100": addrsOf("192.168.64.1", "fd7a:1e2b:3c4d::1"),
+			},
+			v4: "10.0.0.42",
+			v6: "2001:db8:a::42%en0",
+		},
+		{
+			fixture: "fixtures/darwin_netstat_awdl",
+			routes:  23,
+			extra: map[string][]netip.Addr{
+				"en0":   addrsOf("192.168.0.104", "2001:db8:b::104"),
+				"awdl0": addrsOf("fe80::5c3a:91ff:fe4b:7d20"),
+				"llw0":  addrsOf("fe80::5c3a:91ff:fe4b:7d20"),
+			},
+			v4: "192.168.0.104",
+			v6: "2001:db8:b::104%en0",
+		},
+	}
+
+	for _, tt := range tests {
+		routes := parseFixture(t, tt.fixture, BSDNetstatProfile)
+		if len(routes) != tt.routes {
+			t.Errorf("%s: got %d routes, want %d", tt.fixture, len(routes), tt.routes)
+		}
+		for kind, want := range map[NetRouteKind]string{NetRouteKindV4: tt.v4, NetRouteKindV6: tt.v6} {
+			sel, err := NewResolver().selectFrom(kind, routes, time.Now(), tt.extra)
+			if err != nil {
+				t.Errorf("%s: %s selection: %s", tt.fixture, kind, err)
+				continue
+			}
+			if sel.Addr.String() != want || sel.Route.Netif != "en0" {
+				t.Errorf("%s: %s selection: got %s through %s, want %s", tt.fixture, kind, sel.Addr, sel.Route, want)
+			}
+		}
+	}
+}
+
+// randomSelectionInput returns a random routing table and set of addresses
+// for selectFrom, mixing default and non-default routes, flags, metrics, and
+// addresses of every scope.
+func randomSelectionInput(rng *rand.Rand) (NetRouteList, map[string][]netip.Addr) {
+	pool := addrsOf("127.0.0.1", "::1", "0.0.0.0", "::", "169.254.10.1", "fe80::10",
+		"10.0.0.5", "192.168.1.20", "172.16.4.2", "203.0.113.9", "198.51.100.30",
+		"fd00::5", "2001:db8::20", "2001:db8:1::7", "::ffff:192.0.2.1")
+	flags := []RouteFlag{
+		RouteFlagUp | RouteFlagGateway,
+		RouteFlagUp | RouteFlagGateway | RouteFlagHost,
+		RouteFlagUp,
+		RouteFlagGateway,
+	}
+
+	var routes NetRouteList
+	extra := map[string][]netip.Addr{}
+	for i, n := 0, 1+rng.Intn(4); i < n; i++ {
+		netif := fmt.Sprintf("sim%d", i)
+		for j, n := 0, 1+rng.Intn(4); j < n; j++ {
+			extra[netif] = append(extra[netif], pool[rng.Intn(len(pool))])
+		}
+		for j, n := 0, 1+rng.Intn(3); j < n; j++ {
+			dst, gateway, bits := "0.0.0.0", "192.0.2.1", 0
+			if rng.Intn(2) == 0 {
+				dst, gateway = "::", "fe80::1"
+			}
+			if rng.Intn(4) == 0 {
+				bits = 1
+			}
+			route := testRoute(dst, bits, gateway, netif, flags[rng.Intn(len(flags))])
+			if rng.Intn(2) == 0 {
+				route.Attrs["Metric"] = fmt.Sprint(rng.Intn(3))
+			}
+			routes = append(routes, route)
+		}
+	}
+	return routes, extra
+}
+
+// addrsOf parses each of s.
+func addrsOf(s ...string) []netip.Addr {
+	var result []netip.Addr
+	for _, v := range s {
+		result = append(result, netip.MustParseAddr(v))
+	}
+	return result
+}
+
+func TestSelectFromInvariants(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		routes, extra := randomSelectionInput(rng)
+		for _, kind := range []NetRouteKind{NetRouteKindV4, NetRouteKindV6, NetRouteKindAny} {
+			r := NewResolver(WithHonorOSMetrics(rng.Intn(2) == 0))
+			sel, err := r.selectFrom(kind, routes, time.Now(), extra)
+			if len(routes.FindDefaultsFunc(kind, r.candidateFilter)) == 0 && !errors.Is(err, ErrNoIP) {
+				t.Fatalf("%s from %v: got %v, %v without default routes, want an error matching ErrNoIP", kind, routes, sel, err)
+			}
+			if err != nil {
+				if !errors.Is(err, ErrNoIP) {
+					t.Fatalf("%s from %v with %v: unexpected error %s", kind, routes, extra, err)
+				}
+				continue
+			}
+
+			addr := sel.Addr.Unmap()
+			if addr.IsLoopback() || addr.IsUnspecified() {
+				t.Fatalf("%s from %v with %v: selected %s", kind, routes, extra, sel.Addr)
+			}
+			if (kind == NetRouteKindV4 && !addr.Is4()) || (kind == NetRouteKindV6 && !addr.Is6()) {
+				t.Fatalf("%s from %v with %v: selected %s of another family", kind, routes, extra, sel.Addr)
+			}
+
+			// Shuffling routes and addresses yields the same selection.
+			shuffled := slices.Clone(routes)
+			rng.Shuffle(len(shuffled), func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+			shuffledExtra := map[string][]netip.Addr{}
+			for k, v := range extra {
+				v = slices.Clone(v)
+				rng.Shuffle(len(v), func(i, j int) { v[i], v[j] = v[j], v[i] })
+				shuffledExtra[k] = v
+			}
+			again, err := r.selectFrom(kind, shuffled, time.Now(), shuffledExtra)
+			if err != nil || again.Addr != sel.Addr {
+				t.Fatalf("%s from %v with %v: selected %s, then %v (%v) once shuffled", kind, routes, extra, sel.Addr, again, err)
+			}
+		}
+	}
+}
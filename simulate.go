@@ -0,0 +1,73 @@
+package defip
+
+import (
+	"context"
+	"net/netip"
+	"slices"
+	"time"
+)
+
+// RouteChangeOp indicates the operation performed by a RouteChange.
+type RouteChangeOp uint8
+
+const (
+	// RouteChangeAdd adds a route to the table.
+	RouteChangeAdd RouteChangeOp = iota + 1
+
+	// RouteChangeRemove removes all routes matching the provided one (same
+	// kind, destination, gateway, and interface) from the table.
+	RouteChangeRemove
+)
+
+// RouteChange describes a hypothetical modification to a routing table, used
+// by Resolver.Simulate.
+type RouteChange struct {
+	// Op indicates whether Route is added or removed.
+	Op RouteChangeOp
+
+	// Route is the route being added or removed.
+	Route NetRoute
+
+	// InterfaceAddrs lists addresses assumed to be configured on
+	// Route.Netif, in addition to those actually present. This allows
+	// simulating routes through interfaces that do not exist yet, such as
+	// the tunnel a VPN client would create.
+	InterfaceAddrs []netip.Addr
+}
+
+// Snapshot collects the routing table, returning it as a RouteSnapshot.
+func (r *Resolver) Snapshot(ctx context.Context) (RouteSnapshot, error) {
+	routes, warnings, err := r.findRoutes(ctx)
+	if err != nil {
+		return RouteSnapshot{}, err
+	}
+
+	return RouteSnapshot{Routes: routes, CollectedAt: time.Now(), warnings: warnings}, nil
+}
+
+// Simulate runs selection against snapshot modified by changes, answering
+// which address would be selected if the changes were applied to the system.
+// The snapshot itself is not modified. Addresses are obtained from interfaces
+// currently present on the system, along with those provided through
+// RouteChange.InterfaceAddrs.
+func (r *Resolver) Simulate(snapshot RouteSnapshot, kind NetRouteKind, changes []RouteChange) (*Selection, error) {
+	routes := slices.Clone(snapshot.Routes)
+	extra := map[string][]netip.Addr{}
+
+	for _, c := range changes {
+		switch c.Op {
+		case RouteChangeAdd:
+			routes = append(routes, c.Route)
+		case RouteChangeRemove:
+			routes = slices.DeleteFunc(routes, func(v NetRoute) bool {
+				return v.Kind == c.Route.Kind &&
+					v.Destination == c.Route.Destination &&
+					v.Gateway == c.Route.Gateway &&
+					v.Netif == c.Route.Netif
+			})
+		}
+		extra[c.Route.Netif] = append(extra[c.Route.Netif], c.InterfaceAddrs...)
+	}
+
+	return r.selectFrom(kind, routes, snapshot.CollectedAt, extra)
+}
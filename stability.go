@@ -0,0 +1,74 @@
+package defip
+
+import (
+	"net/netip"
+	"sync"
+	"time"
+)
+
+// stabilityTracker records when candidate addresses were first observed by
+// a Resolver, so addresses present for long enough may be favoured. See
+// WithStabilityBonus.
+type stabilityTracker struct {
+	after time.Duration
+	bonus int
+
+	mu        sync.Mutex
+	firstSeen map[netip.Addr]time.Time
+}
+
+// observe records candidates of a given kind as present at at, and forgets
+// addresses of that kind no longer among them, so an address going away and
+// coming back has to become stable again.
+func (t *stabilityTracker) observe(kind NetRouteKind, candidates []netip.Addr, at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	present := make(map[netip.Addr]bool, len(candidates))
+	for _, addr := range candidates {
+		present[addr] = true
+		if _, ok := t.firstSeen[addr]; !ok {
+			t.firstSeen[addr] = at
+		}
+	}
+	for addr := range t.firstSeen {
+		if kind.MatchesAddr(addr) && !present[addr] {
+			delete(t.firstSeen, addr)
+		}
+	}
+}
+
+// stable returns whether addr has been present for at least the configured
+// duration at at.
+func (t *stabilityTracker) stable(addr netip.Addr, at time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	seen, ok := t.firstSeen[addr]
+	return ok && at.Sub(seen) >= t.after
+}
+
+// WithStabilityBonus adds bonus to the score of candidate addresses (see
+// WithWeights) observed by the Resolver for at least after, so an address
+// that just appeared, such as one assigned by a VPN client, does not
+// instantly displace a long-standing one. Addresses are observed by every
+// selection made through the Resolver, and forgotten once absent from one,
+// so the bonus is only granted to addresses that were present all along.
+// As observations are kept for the lifetime of the Resolver, the same
+// Resolver must be reused across selections. Disabled by default.
+func WithStabilityBonus(after time.Duration, bonus int) Option {
+	return func(r *Resolver) {
+		r.stability = &stabilityTracker{after: after, bonus: bonus, firstSeen: map[netip.Addr]time.Time{}}
+	}
+}
+
+// scorer returns the function scoring candidate addresses for a selection
+// made at at: their weight, plus the stability bonus when applicable.
+func (r *Resolver) scorer(at time.Time) func(netip.Addr) int {
+	return func(addr netip.Addr) int {
+		score := r.weights.score(addr)
+		if r.stability != nil && r.stability.stable(addr, at) {
+			score += r.stability.bonus
+		}
+		return score
+	}
+}
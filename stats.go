@@ -0,0 +1,62 @@
+package defip
+
+import "net/netip"
+
+// RouteStats summarises a NetRouteList.
+type RouteStats struct {
+	// Total indicates how many routes the list contains.
+	Total int
+
+	// ByKind counts routes per address family.
+	ByKind map[NetRouteKind]int
+
+	// ByInterface counts routes per interface name.
+	ByInterface map[string]int
+
+	// Defaults counts routes accepted by DefaultRouteFilter.
+	Defaults int
+
+	// DuplicateDefaults counts routes accepted by DefaultRouteFilter that
+	// duplicate another one (same kind, destination, gateway, and
+	// interface), which usually indicates conflicting DHCP and static
+	// configurations.
+	DuplicateDefaults int
+
+	// HostRoutes counts routes flagged as host routes (H).
+	HostRoutes int
+
+	// Gateways counts distinct gateways, ignoring unspecified ones.
+	Gateways int
+}
+
+// Stats computes summary statistics for the list.
+func (n NetRouteList) Stats() RouteStats {
+	stats := RouteStats{
+		Total:       len(n),
+		ByKind:      map[NetRouteKind]int{},
+		ByInterface: map[string]int{},
+	}
+
+	gateways := map[netip.Addr]bool{}
+	defaults := map[dedupeKey]bool{}
+	for _, v := range n {
+		stats.ByKind[v.Kind]++
+		stats.ByInterface[v.Netif]++
+		if DefaultRouteFilter(v) {
+			stats.Defaults++
+			if defaults[dedupeKeyOf(v)] {
+				stats.DuplicateDefaults++
+			}
+			defaults[dedupeKeyOf(v)] = true
+		}
+		if v.HasAnyFlags(RouteFlagHost) {
+			stats.HostRoutes++
+		}
+		if v.Gateway.IsValid() && !v.Gateway.IsUnspecified() {
+			gateways[v.Gateway] = true
+		}
+	}
+	stats.Gateways = len(gateways)
+
+	return stats
+}
@@ -0,0 +1,89 @@
+package defip
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+)
+
+// DefaultSubnet returns the subnet the address selected by Select is
+// configured on, with host bits cleared, such as 192.168.0.0/24. Use
+// HostIterator to enumerate the addresses it contains.
+func (r *Resolver) DefaultSubnet(ctx context.Context, kind NetRouteKind) (netip.Prefix, error) {
+	sel, err := r.Select(ctx, kind)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+
+	prefixes, err := interfacePrefixes(&sel.Interface)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	addr := sel.Addr.WithZone("")
+	for _, p := range prefixes {
+		if p.Addr() == addr {
+			return p.Masked(), nil
+		}
+	}
+
+	return netip.Prefix{}, fmt.Errorf("could not find `%s' on interface `%s'", addr, sel.Interface.Name)
+}
+
+// HostIterator enumerates the host addresses of a subnet in ascending order.
+// The network address is skipped, as is the broadcast address of IPv4
+// subnets, except for /31 and /32 (and IPv6 /127 and /128) subnets, whose
+// every address is a host address.
+type HostIterator struct {
+	next netip.Addr
+	last netip.Addr
+	done bool
+}
+
+// NewHostIterator returns a HostIterator over the host addresses of p.
+func NewHostIterator(p netip.Prefix) *HostIterator {
+	p = p.Masked()
+	if !p.IsValid() {
+		return &HostIterator{done: true}
+	}
+
+	first, last := p.Addr(), lastAddr(p)
+	if p.Bits() < p.Addr().BitLen()-1 {
+		first = first.Next()
+		if first.Is4() {
+			last = last.Prev()
+		}
+	}
+	return &HostIterator{next: first, last: last}
+}
+
+// Next returns the next host address, and whether one was available.
+func (h *HostIterator) Next() (netip.Addr, bool) {
+	if h.done {
+		return netip.Addr{}, false
+	}
+
+	addr := h.next
+	if addr == h.last {
+		h.done = true
+	} else {
+		h.next = addr.Next()
+	}
+	return addr, true
+}
+
+// lastAddr returns the highest address within p.
+func lastAddr(p netip.Prefix) netip.Addr {
+	addr := p.Addr().As16()
+	offset := 0
+	if p.Addr().Is4() {
+		offset = 96
+	}
+	for i := range addr {
+		hostBits := max(0, min(8, (i+1)*8-offset-p.Bits()))
+		addr[i] |= byte(1<<hostBits - 1)
+	}
+	if p.Addr().Is4() {
+		return netip.AddrFrom4([4]byte(addr[12:]))
+	}
+	return netip.AddrFrom16(addr)
+}
@@ -0,0 +1,75 @@
+package defip
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// WithParserTrace makes the Resolver write a trace of the routing table
+// parsers to w: each state transition, header match, and row accepted or
+// rejected (along with why), one per line. Meant for diagnosing parsers
+// producing unexpected or empty tables from user-supplied traces.
+func WithParserTrace(w io.Writer) Option {
+	return func(r *Resolver) {
+		r.trace = w
+	}
+}
+
+// ParseWarning describes a routing table row skipped by a parser, as it could
+// not be parsed, or a diagnostic written by a program the routing table was
+// obtained from.
+type ParseWarning struct {
+	// Source identifies where Row was read from, such as "/proc/net/route"
+	// or "netstat -rn", or "netstat -rn (stderr)" for diagnostics.
+	Source string `json:"source"`
+
+	// Line is the 1-based number of the row within Source.
+	Line int `json:"line"`
+
+	// Row is the raw row, with surrounding whitespace removed.
+	Row string `json:"row"`
+
+	// Reason briefly describes why Row was skipped.
+	Reason string `json:"reason"`
+}
+
+// parseLog collects rows skipped by parsers, and writes parser traces when
+// configured to. A nil *parseLog discards both.
+type parseLog struct {
+	w        io.Writer
+	warnings []ParseWarning
+}
+
+func (l *parseLog) printf(source, format string, args ...any) {
+	if l == nil || l.w == nil {
+		return
+	}
+	fmt.Fprintf(l.w, source+": "+format+"\n", args...)
+}
+
+// skip records row, found at line, as skipped by the parser of source, for
+// the provided reason. Rows that cannot be parsed are skipped instead of
+// failing the whole parse, so a single odd route does not hide all others.
+func (l *parseLog) skip(source string, line int, row, reason string) {
+	l.printf(source, "rejected row %d %q: %s", line, row, reason)
+	if l == nil {
+		return
+	}
+	l.warnings = append(l.warnings, ParseWarning{Source: source, Line: line, Row: row, Reason: reason})
+}
+
+type parseLogKey struct{}
+
+// withParseLog returns a copy of ctx carrying a new parseLog writing traces
+// to w, which may be nil, through which backends obtain it.
+func withParseLog(ctx context.Context, w io.Writer) (context.Context, *parseLog) {
+	l := &parseLog{w: w}
+	return context.WithValue(ctx, parseLogKey{}, l), l
+}
+
+// parseLogFrom returns the parseLog carried by ctx, or nil.
+func parseLogFrom(ctx context.Context) *parseLog {
+	l, _ := ctx.Value(parseLogKey{}).(*parseLog)
+	return l
+}
@@ -0,0 +1,363 @@
+package defip
+
+import (
+	"context"
+	"net/netip"
+	"time"
+)
+
+// RouteSnapshot represents the routing table as observed at a given moment.
+type RouteSnapshot struct {
+	// Routes contains all routes collected at CollectedAt.
+	Routes NetRouteList
+
+	// CollectedAt indicates when Routes was collected.
+	CollectedAt time.Time
+
+	// Changes indicates how many changes were observed within the churn
+	// window preceding CollectedAt.
+	Changes int
+
+	// Unstable indicates that the routing table is changing faster than the
+	// configured churn threshold (e.g. a flapping routing daemon rewriting the
+	// default route). Consumers may want to defer decisions until a stable
+	// snapshot is received.
+	Unstable bool
+
+	warnings []ParseWarning
+}
+
+// Warnings returns rows skipped while parsing the routing table, as they could
+// not be parsed. Callers wanting to treat those as failures may check whether
+// any is returned.
+func (s RouteSnapshot) Warnings() []ParseWarning {
+	return s.warnings
+}
+
+// RouteEvent represents a change in the routing table detected by
+// WatchRoutes.
+type RouteEvent struct {
+	// Added contains routes present in Snapshot that were not present in the
+	// previously emitted one.
+	Added NetRouteList
+
+	// Removed contains routes present in the previously emitted snapshot that
+	// are absent from Snapshot.
+	Removed NetRouteList
+
+	// Snapshot contains the routing table after the change.
+	Snapshot RouteSnapshot
+
+	// Coalesced indicates how many collections of the routing table observed
+	// a change since the previous event, including the one emitting this
+	// event. Values greater than one denote collections merged into this
+	// event while the routing table was unstable, regardless of how many
+	// routes each of them added or removed.
+	Coalesced int
+
+	// Err is set when the routing table could not be collected.
+	Err error
+
+	// Lost indicates how many events were dropped before this one because
+	// the consumer was not keeping up. Consumers observing a non-zero value
+	// should rely on Snapshot rather than on Added and Removed.
+	Lost int
+}
+
+// WatchConfig configures the behaviour of WatchRoutes. Zero values are
+// replaced by sensible defaults.
+type WatchConfig struct {
+	// Interval indicates how often the routing table is collected. Defaults to
+	// five seconds.
+	Interval time.Duration
+
+	// ChurnWindow indicates the period in which changes are counted to
+	// determine whether the routing table is unstable. Defaults to one minute.
+	ChurnWindow time.Duration
+
+	// ChurnThreshold indicates how many changes within ChurnWindow cause the
+	// routing table to be considered unstable. While unstable, changes are
+	// coalesced and emitted at most once per ChurnWindow. Defaults to 5.
+	ChurnThreshold int
+
+	// Buffer indicates how many events may be queued for a slow consumer.
+	// Once full, the oldest queued event is dropped in favour of the newest
+	// one, and accounted for in RouteEvent.Lost of the event queued after it,
+	// so a slow consumer never blocks collection. Defaults to 16.
+	Buffer int
+
+	// Settle indicates how long the routing table must remain unchanged
+	// before WatchSnapshots emits a snapshot. Defaults to Interval.
+	Settle time.Duration
+}
+
+func (w WatchConfig) withDefaults() WatchConfig {
+	if w.Interval <= 0 {
+		w.Interval = 5 * time.Second
+	}
+	if w.ChurnWindow <= 0 {
+		w.ChurnWindow = time.Minute
+	}
+	if w.ChurnThreshold <= 0 {
+		w.ChurnThreshold = 5
+	}
+	if w.Buffer <= 0 {
+		w.Buffer = 16
+	}
+	if w.Settle <= 0 {
+		w.Settle = w.Interval
+	}
+	return w
+}
+
+type routeKey struct {
+	kind        NetRouteKind
+	destination netip.Addr
+	gateway     netip.Addr
+	netif       string
+	flags       string
+}
+
+func keyOf(r NetRoute) routeKey {
+	return routeKey{
+		kind:        r.Kind,
+		destination: r.Destination,
+		gateway:     r.Gateway,
+		netif:       r.Netif,
+		flags:       r.Flags,
+	}
+}
+
+func diffRoutes(prev, next NetRouteList) (added, removed NetRouteList) {
+	prevSet := make(map[routeKey]bool, len(prev))
+	for _, v := range prev {
+		prevSet[keyOf(v)] = true
+	}
+	nextSet := make(map[routeKey]bool, len(next))
+	for _, v := range next {
+		nextSet[keyOf(v)] = true
+		if !prevSet[keyOf(v)] {
+			added = append(added, v)
+		}
+	}
+	for _, v := range prev {
+		if !nextSet[keyOf(v)] {
+			removed = append(removed, v)
+		}
+	}
+	return
+}
+
+// churnGuard counts changes within a sliding window.
+type churnGuard struct {
+	window    time.Duration
+	threshold int
+	changes   []time.Time
+}
+
+func (c *churnGuard) record(at time.Time) {
+	c.changes = append(c.changes, at)
+}
+
+func (c *churnGuard) count(at time.Time) int {
+	cutoff := at.Add(-c.window)
+	i := 0
+	for i < len(c.changes) && c.changes[i].Before(cutoff) {
+		i++
+	}
+	c.changes = c.changes[i:]
+	return len(c.changes)
+}
+
+func (c *churnGuard) unstable(at time.Time) bool {
+	return c.count(at) >= c.threshold
+}
+
+// forwardEvents delivers events received from in through out, queueing up to
+// size of them for a slow consumer. Once the queue is full, its oldest event
+// is discarded, and the amount of discarded events is carried over to the
+// event queued after it, being the next one the consumer receives. out is
+// closed once in is closed or ctx is done.
+func forwardEvents(ctx context.Context, in <-chan RouteEvent, out chan<- RouteEvent, size int) {
+	defer close(out)
+
+	var queue []RouteEvent
+	for {
+		// Sending on a nil channel blocks, disabling its case while the
+		// queue is empty.
+		var send chan<- RouteEvent
+		var next RouteEvent
+		if len(queue) > 0 {
+			send, next = out, queue[0]
+		}
+
+		select {
+		case e, ok := <-in:
+			if !ok {
+				return
+			}
+			queue = append(queue, e)
+			if len(queue) > size {
+				queue[1].Lost += queue[0].Lost + 1
+				queue = queue[1:]
+			}
+		case send <- next:
+			queue = queue[1:]
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// WatchRoutes periodically collects the routing table and emits a RouteEvent
+// whenever it changes. Up to WatchConfig.Buffer events are queued for a slow
+// consumer, and the returned channel is closed once ctx is done.
+//
+// Deprecated: Use Resolver.WatchRoutes.
+func WatchRoutes(ctx context.Context, config WatchConfig) <-chan RouteEvent {
+	return defaultResolver.WatchRoutes(ctx, config)
+}
+
+// WatchRoutes periodically collects the routing table and emits a RouteEvent
+// whenever it changes. Up to WatchConfig.Buffer events are queued for a slow
+// consumer, and the returned channel is closed once ctx is done.
+func (r *Resolver) WatchRoutes(ctx context.Context, config WatchConfig) <-chan RouteEvent {
+	config = config.withDefaults()
+	ch := make(chan RouteEvent)
+	out := make(chan RouteEvent)
+	go forwardEvents(ctx, ch, out, config.Buffer)
+
+	go func() {
+		defer close(ch)
+
+		guard := &churnGuard{window: config.ChurnWindow, threshold: config.ChurnThreshold}
+		ticker := time.NewTicker(config.Interval)
+		defer ticker.Stop()
+
+		// prev holds the last collected table, used to count changes, while
+		// last holds the last emitted one, used to compute deltas.
+		var prev, last NetRouteList
+		var lastEmit time.Time
+		coalesced := 0
+		first := true
+
+		for {
+			routes, warnings, err := r.findRoutes(ctx)
+			if ctx.Err() != nil {
+				return
+			}
+			now := time.Now()
+
+			var event *RouteEvent
+			if err != nil {
+				event = &RouteEvent{Err: err}
+			} else {
+				if a, rm := diffRoutes(prev, routes); !first && (len(a) > 0 || len(rm) > 0) {
+					guard.record(now)
+					coalesced++
+				}
+				prev = routes
+
+				unstable := guard.unstable(now)
+				added, removed := diffRoutes(last, routes)
+				changed := len(added) > 0 || len(removed) > 0
+				if first || (changed && (!unstable || now.Sub(lastEmit) >= config.ChurnWindow)) {
+					event = &RouteEvent{
+						Added:   added,
+						Removed: removed,
+						Snapshot: RouteSnapshot{
+							Routes:      routes,
+							CollectedAt: now,
+							Changes:     guard.count(now),
+							Unstable:    unstable,
+							warnings:    warnings,
+						},
+						Coalesced: coalesced,
+					}
+					last, lastEmit, coalesced, first = routes, now, 0, false
+				}
+			}
+
+			if event != nil {
+				select {
+				case ch <- *event:
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out
+}
+
+// WatchSnapshots works like WatchRoutes, but instead of individual changes,
+// emits the full routing table once changes settle, that is, once no further
+// changes are observed for WatchConfig.Settle. The first snapshot is emitted
+// as soon as it settles. Collection errors are not reported; the next
+// successful collection is emitted instead. The returned channel is closed
+// once ctx is done.
+func (r *Resolver) WatchSnapshots(ctx context.Context, config WatchConfig) <-chan RouteSnapshot {
+	config = config.withDefaults()
+	events := r.WatchRoutes(ctx, config)
+	ch := make(chan RouteSnapshot, 1)
+
+	go func() {
+		defer close(ch)
+
+		timer := time.NewTimer(config.Settle)
+		stopTimer(timer)
+		defer timer.Stop()
+
+		var pending *RouteSnapshot
+		for {
+			select {
+			case e, ok := <-events:
+				if !ok {
+					return
+				}
+				if e.Err != nil {
+					continue
+				}
+				pending = &e.Snapshot
+				stopTimer(timer)
+				timer.Reset(config.Settle)
+
+			case <-timer.C:
+				if pending == nil {
+					continue
+				}
+				// Replace a snapshot the consumer did not pick up yet, as
+				// the newer one supersedes it.
+				select {
+				case <-ch:
+				default:
+				}
+				ch <- *pending
+				pending = nil
+
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ch
+}
+
+// stopTimer stops t and drains its channel, so a later Reset does not
+// observe an expiration from before it.
+func stopTimer(t *time.Timer) {
+	if !t.Stop() {
+		select {
+		case <-t.C:
+		default:
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package defip
+
+import (
+	"context"
+	"net/netip"
+	"time"
+)
+
+// DefaultIPEvent represents a change of the default IP detected by
+// WatchDefaultIP.
+type DefaultIPEvent struct {
+	// Kind is the kind of address watched.
+	Kind NetRouteKind
+
+	// Previous holds the address selected before the change. Invalid for the
+	// first event, or when no address could be selected before.
+	Previous netip.Addr
+
+	// Current holds the address selected after the change. Invalid when no
+	// address could be selected, in which case Err holds why.
+	Current netip.Addr
+
+	// PreviousPrefix and CurrentPrefix hold the prefixes Previous and
+	// Current belong to; see DefaultIPEvent.PrefixChanged.
+	PreviousPrefix netip.Prefix
+	CurrentPrefix  netip.Prefix
+
+	// Selection holds the selection Current was obtained from, when valid.
+	Selection *Selection
+
+	// Err holds why no address could be selected.
+	Err error
+
+	// At indicates when the change was observed.
+	At time.Time
+}
+
+// PrefixChanged returns whether the prefix of the default IP changed, as
+// when an ISP renumbers the prefix delegated to a network. Hosts deriving
+// their interface identifiers from stable sources keep them across such
+// changes, so the address merely changes its leading bits; consumers such as
+// firewalls tracking the prefix rather than the address should act on this
+// instead of on address changes. Returns false for the first event, and
+// when no address is selected before or after the change.
+func (e DefaultIPEvent) PrefixChanged() bool {
+	return e.PreviousPrefix.IsValid() && e.CurrentPrefix.IsValid() && e.PreviousPrefix != e.CurrentPrefix
+}
+
+// selectionPrefix returns the prefix sel.Addr belongs to, with host bits
+// cleared: the subnet it is configured on, widened to a /64 for IPv6
+// addresses configured with longer prefixes, such as /128 addresses assigned
+// through DHCPv6, as /64 is the smallest prefix assigned to IPv6 links.
+func selectionPrefix(sel *Selection) netip.Prefix {
+	addr := sel.Addr.WithZone("")
+	bits := -1
+	if prefixes, err := interfacePrefixes(&sel.Interface); err == nil {
+		for _, p := range prefixes {
+			if p.Addr() == addr {
+				bits = p.Bits()
+				break
+			}
+		}
+	}
+	if addr.Is6() && (bits == -1 || bits > 64) {
+		bits = 64
+	}
+	if bits == -1 {
+		return netip.Prefix{}
+	}
+	return netip.PrefixFrom(addr, bits).Masked()
+}
+
+// WatchDefaultIP periodically selects the default IP of a given kind, as
+// Select does, and emits a DefaultIPEvent whenever the selected address or
+// its prefix changes, starting with the first selection. Addresses are selected anew on
+// every WatchConfig.Interval, as they may change without the routing table
+// changing, such as when a DHCP lease is renewed with a different address.
+//
+// The returned channel is buffered according to WatchConfig.Buffer, and is
+// closed once ctx is done. Once full, queued events are discarded in favour of
+// the newest one, whose Previous and PreviousPrefix then hold the address
+// and prefix preceding them.
+func (r *Resolver) WatchDefaultIP(ctx context.Context, kind NetRouteKind, config WatchConfig) <-chan DefaultIPEvent {
+	config = config.withDefaults()
+	ch := make(chan DefaultIPEvent, config.Buffer)
+
+	go func() {
+		defer close(ch)
+
+		ticker := time.NewTicker(config.Interval)
+		defer ticker.Stop()
+
+		var last netip.Addr
+		var lastPrefix netip.Prefix
+		var lastErr string
+		first := true
+		for {
+			sel, err := r.Select(ctx, kind)
+			if ctx.Err() != nil {
+				return
+			}
+
+			event := DefaultIPEvent{Kind: kind, Previous: last, PreviousPrefix: lastPrefix, Selection: sel, Err: err, At: time.Now()}
+			errText := ""
+			if err != nil {
+				event.Selection = nil
+				errText = err.Error()
+			} else {
+				event.Current = sel.Addr
+				event.CurrentPrefix = selectionPrefix(sel)
+			}
+
+			if first || event.Current != last || event.CurrentPrefix != lastPrefix || (err != nil && errText != lastErr) {
+				mergeOldestSend(ch, event)
+				last, lastPrefix, lastErr, first = event.Current, event.CurrentPrefix, errText, false
+			}
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ch
+}
+
+// mergeOldestSend sends e through ch without blocking. In case ch is full,
+// the events it holds are discarded, and e inherits the Previous address and
+// prefix of the oldest of them.
+func mergeOldestSend(ch chan DefaultIPEvent, e DefaultIPEvent) {
+	merged := false
+	for {
+		select {
+		case ch <- e:
+			return
+		default:
+		}
+
+		for len(ch) > 0 {
+			select {
+			case old := <-ch:
+				if !merged {
+					e.Previous, e.PreviousPrefix, merged = old.Previous, old.PreviousPrefix, true
+				}
+			default:
+			}
+		}
+	}
+}
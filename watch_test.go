@@ -0,0 +1,124 @@
+package defip
+
+import (
+	"context"
+	"net/netip"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestForwardEventsLostOnNextDelivered(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	in, out := make(chan RouteEvent), make(chan RouteEvent)
+	go forwardEvents(ctx, in, out, 2)
+	for i := 1; i <= 5; i++ {
+		in <- RouteEvent{Coalesced: i}
+	}
+
+	first, second := <-out, <-out
+	if first.Coalesced != 4 || first.Lost != 3 {
+		t.Errorf("first delivered event: got #%d with Lost %d, want #4 with Lost 3", first.Coalesced, first.Lost)
+	}
+	if second.Coalesced != 5 || second.Lost != 0 {
+		t.Errorf("second delivered event: got #%d with Lost %d, want #5 with Lost 0", second.Coalesced, second.Lost)
+	}
+}
+
+func TestForwardEventsUnderLoad(t *testing.T) {
+	const total = 10000
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	in, out := make(chan RouteEvent), make(chan RouteEvent)
+	done := make(chan struct{})
+	go forwardEvents(ctx, in, out, 4)
+
+	received, lost, last := 0, 0, 0
+	go func() {
+		defer close(done)
+		for last < total {
+			e := <-out
+			if e.Coalesced <= last {
+				t.Errorf("event #%d delivered after #%d", e.Coalesced, last)
+			}
+			last = e.Coalesced
+			received++
+			lost += e.Lost
+			if received%7 == 0 {
+				time.Sleep(time.Microsecond)
+			}
+		}
+	}()
+
+	for i := 1; i <= total; i++ {
+		in <- RouteEvent{Coalesced: i}
+	}
+	<-done
+
+	if received+lost != total {
+		t.Errorf("received %d and lost %d events, want %d in total", received, lost, total)
+	}
+	if last != total {
+		t.Errorf("last delivered event is #%d, want #%d", last, total)
+	}
+}
+
+// watchChurnCalls counts collections by the test-watch-churn backend.
+var watchChurnCalls atomic.Int32
+
+func TestWatchRoutesBackpressure(t *testing.T) {
+	watchChurnCalls.Store(0)
+	registerTestBackend(t, "test-watch-churn", func(ctx context.Context) (NetRouteList, error) {
+		n := watchChurnCalls.Add(1)
+		return NetRouteList{{
+			Kind:        NetRouteKindV4,
+			Destination: netip.IPv4Unspecified(),
+			Gateway:     netip.AddrFrom4([4]byte{192, 0, 2, byte(n)}),
+			Netif:       "sim0",
+		}}, nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := NewResolver(WithBackends("test-watch-churn"))
+	events := r.WatchRoutes(ctx, WatchConfig{
+		Interval:       time.Millisecond,
+		ChurnThreshold: 1 << 20,
+		Buffer:         2,
+	})
+
+	// Not consuming must not stall collection.
+	deadline := time.Now().Add(5 * time.Second)
+	for watchChurnCalls.Load() < 50 {
+		if time.Now().After(deadline) {
+			t.Fatalf("collection stalled after %d calls", watchChurnCalls.Load())
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	e := <-events
+	if e.Err != nil {
+		t.Fatal(e.Err)
+	}
+	if e.Lost == 0 {
+		t.Error("first event received after falling behind reports no lost events")
+	}
+	cancel()
+	for range events {
+	}
+}
+
+func TestStopTimerDrainsExpiration(t *testing.T) {
+	timer := time.NewTimer(time.Millisecond)
+	defer timer.Stop()
+	time.Sleep(10 * time.Millisecond)
+
+	stopTimer(timer)
+	timer.Reset(time.Hour)
+	select {
+	case <-timer.C:
+		t.Fatal("expiration from before Reset was observed")
+	case <-time.After(10 * time.Millisecond):
+	}
+}
@@ -0,0 +1,73 @@
+package defip
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+)
+
+// wolPort is the port magic packets are sent to. Network cards inspect every
+// frame regardless of port, so the choice is conventional.
+const wolPort = 9
+
+// SendWOL sends a Wake-on-LAN magic packet for mac to the broadcast address
+// of the named interface. When netif is empty, the interface selected for
+// IPv4 by Select is used. Interfaces without a subnet to broadcast to, such as
+// those configured with a /32 address, use the limited broadcast address
+// (255.255.255.255) instead.
+func (r *Resolver) SendWOL(ctx context.Context, mac net.HardwareAddr, netif string) error {
+	if len(mac) != 6 {
+		return fmt.Errorf("invalid MAC address `%s': Wake-on-LAN requires a 48-bit address", mac)
+	}
+
+	if netif == "" {
+		sel, err := r.Select(ctx, NetRouteKindV4)
+		if err != nil {
+			return err
+		}
+		netif = sel.Interface.Name
+	}
+
+	iface, err := net.InterfaceByName(netif)
+	if err != nil {
+		return fmt.Errorf("could not find interface `%s': %w", netif, err)
+	}
+	prefixes, err := interfacePrefixes(iface)
+	if err != nil {
+		return err
+	}
+
+	var local netip.Addr
+	broadcast := netip.AddrFrom4([4]byte{255, 255, 255, 255})
+	for _, p := range prefixes {
+		if !p.Addr().Is4() {
+			continue
+		}
+		local = p.Addr()
+		if p.Bits() < 31 {
+			broadcast = lastAddr(p)
+			break
+		}
+	}
+	if !local.IsValid() {
+		return fmt.Errorf("interface `%s' has no IPv4 address", netif)
+	}
+
+	d := net.Dialer{LocalAddr: net.UDPAddrFromAddrPort(netip.AddrPortFrom(local, 0))}
+	conn, err := d.DialContext(ctx, "udp4", netip.AddrPortFrom(broadcast, wolPort).String())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Write(magicPacket(mac))
+	return err
+}
+
+// magicPacket returns a Wake-on-LAN magic packet for mac: six 0xFF bytes
+// followed by sixteen repetitions of the address.
+func magicPacket(mac net.HardwareAddr) []byte {
+	return append(bytes.Repeat([]byte{0xff}, 6), bytes.Repeat(mac, 16)...)
+}